@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/openshift/rbac-permissions-operator/pkg/lint"
+	"github.com/spf13/pflag"
+)
+
+// runLint checks SubjectPermission manifests the way the admission webhook does and simulates their bindings,
+// offline. It exits with 1 when an error is found, or a warning with --strict, and with 2 on bad usage or
+// manifests that cannot be read
+func runLint(args []string) int {
+	flags := pflag.NewFlagSet("lint", pflag.ContinueOnError)
+	flags.SetOutput(stderr)
+	filenames := flags.StringSliceP("filename", "f", nil, "Manifests of the SubjectPermissions and ClusterSubjectPermissions, files or directories")
+	clusterRoles := flags.StringSlice("clusterroles", nil, "Manifests of the ClusterRoles existing on the cluster, files or directories")
+	namespacesFile := flags.String("namespaces", "", "Namespaces to simulate the Permissions in, Namespace manifests or a file with one name per line")
+	configFile := flags.String("config", "", "Manifest of the operator ConfigMap, with the protected namespaces and the policy")
+	output := flags.StringP("output", "o", "table", "Output format, table or json")
+	strict := flags.Bool("strict", false, "Fail on warnings too")
+	if err := flags.Parse(args); err != nil {
+		// the usage is already printed for --help
+		if err != pflag.ErrHelp {
+			fmt.Fprintln(stderr, err)
+		}
+		return 2
+	}
+
+	if len(*filenames) == 0 {
+		fmt.Fprintln(stderr, "--filename is required")
+		return 2
+	}
+	if *output != "table" && *output != "json" {
+		fmt.Fprintf(stderr, "--output: unknown format %q\n", *output)
+		return 2
+	}
+
+	paths := append(append([]string{}, *filenames...), *clusterRoles...)
+	if *configFile != "" {
+		paths = append(paths, *configFile)
+	}
+	var namespaceNames []string
+	if *namespacesFile != "" {
+		if isManifest(*namespacesFile) {
+			paths = append(paths, *namespacesFile)
+		} else {
+			var err error
+			namespaceNames, err = readNames(*namespacesFile)
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+				return 2
+			}
+		}
+	}
+	m, err := fromFiles(paths)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	for _, name := range namespaceNames {
+		m.Namespaces = append(m.Namespaces, m.namespace(name))
+	}
+	if *configFile != "" && m.OperatorConfig == nil {
+		fmt.Fprintf(stderr, "--config: %s does not hold the operator ConfigMap\n", *configFile)
+		return 2
+	}
+	protectedNamespaces, bindingPolicy, err := m.operatorConfig()
+	if err != nil {
+		fmt.Fprintf(stderr, "operator ConfigMap: %v\n", err)
+		return 2
+	}
+
+	report := lint.Run(lint.Input{
+		SubjectPermissions:  m.SubjectPermissions,
+		ClusterRoles:        m.ClusterRoles,
+		Namespaces:          m.Namespaces,
+		Now:                 time.Now(),
+		ProtectedNamespaces: protectedNamespaces,
+		Policy:              bindingPolicy,
+	})
+
+	if *output == "json" {
+		err = printLintJSON(stdout, report)
+	} else {
+		err = printLintTable(stdout, report)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if report.ErrorCount > 0 || (*strict && report.WarningCount > 0) {
+		return 1
+	}
+	return 0
+}
+
+// readNames reads one name per line, blank lines and lines starting with # are skipped
+func readNames(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var names []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			names = append(names, line)
+		}
+	}
+	return names, scanner.Err()
+}
+
+// printLintJSON prints the report as JSON
+func printLintJSON(w io.Writer, report *lint.Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// printLintTable prints one row per ClusterPermission and Permission, followed by the errors and warnings
+func printLintTable(w io.Writer, report *lint.Report) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "SUBJECTPERMISSION\tENTRY\tCLUSTERROLE\tBINDING\tNAMESPACES")
+	var problems []string
+	for _, subjectPermission := range report.SubjectPermissions {
+		name := subjectPermission.Kind + " " + subjectPermission.Name
+		if subjectPermission.Namespace != "" {
+			name = subjectPermission.Kind + " " + subjectPermission.Namespace + "/" + subjectPermission.Name
+		}
+		for i, clusterPermission := range subjectPermission.ClusterPermissions {
+			fmt.Fprintf(table, "%s\tspec.clusterPermissions[%d]\t%s\t%s\t%s\n", name, i, clusterPermission.ClusterRoleName,
+				orNone(clusterPermission.BindingName), notGranted(clusterPermission.Reason, "*"))
+		}
+		for i, permission := range subjectPermission.Permissions {
+			namespaces := strings.Join(permission.MatchedNamespaces, ",")
+			fmt.Fprintf(table, "%s\tspec.permissions[%d]\t%s\t%s\t%s\n", name, i, permission.ClusterRoleName,
+				orNone(permission.BindingName), notGranted(permission.Reason, orNone(namespaces)))
+		}
+		for _, err := range subjectPermission.Errors {
+			problems = append(problems, fmt.Sprintf("ERROR    %s: %s", name, err))
+		}
+		for _, warning := range subjectPermission.Warnings {
+			problems = append(problems, fmt.Sprintf("WARNING  %s: %s", name, warning))
+		}
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	if len(problems) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, strings.Join(problems, "\n"))
+	}
+	_, err := fmt.Fprintf(w, "\n%d SubjectPermissions checked, %d errors, %d warnings\n", len(report.SubjectPermissions), report.ErrorCount, report.WarningCount)
+	return err
+}
+
+// orNone returns s, or - when it is empty
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// notGranted returns the reason an entry is not granted in parentheses, or granted when there is none
+func notGranted(reason, granted string) string {
+	if reason == "" {
+		return granted
+	}
+	return "(" + reason + ")"
+}
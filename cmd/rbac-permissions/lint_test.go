@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openshift/rbac-permissions-operator/pkg/lint"
+)
+
+const (
+	validManifest = `apiVersion: managed.openshift.io/v1alpha1
+kind: SubjectPermission
+metadata:
+  name: dev-view
+  namespace: openshift-rbac-permissions-operator
+spec:
+  subjectKind: Group
+  subjectName: dev
+  permissions:
+  - clusterRoleName: view
+    namespacesAllowedRegex: "^team-"
+`
+	invalidManifest = `apiVersion: managed.openshift.io/v1alpha1
+kind: SubjectPermission
+metadata:
+  name: dev-broken
+  namespace: openshift-rbac-permissions-operator
+spec:
+  subjectKind: Group
+  subjectName: dev
+  permissions:
+  - clusterRoleName: view
+    namespacesAllowedRegex: "("
+`
+	missingClusterRoleManifest = `apiVersion: managed.openshift.io/v1alpha1
+kind: ClusterSubjectPermission
+metadata:
+  name: ops-edit
+spec:
+  subjectKind: Group
+  subjectName: ops
+  permissions:
+  - clusterRoleName: edit
+    namespacesAllowedRegex: "^team-"
+`
+)
+
+// TestLint tests the exit codes and the output of the lint command
+// given: a valid SubjectPermission, an invalid one and one warned about for a missing ClusterRole
+// expected: 0 when nothing is found, 1 on errors or on warnings with --strict, and 2 on bad usage
+// or manifests that cannot be read
+func TestLint(t *testing.T) {
+	dir := writeManifests(t, map[string]string{
+		"valid/subjectpermission.yaml":   validManifest,
+		"invalid/subjectpermission.yaml": invalidManifest,
+		"warning/subjectpermission.yaml": missingClusterRoleManifest,
+		"clusterroles.yaml":              clusterRoleManifest,
+		"namespaces.txt":                 "# tenants\nteam-a\n\nteam-b\n",
+		"config.yaml":                    operatorConfigManifest,
+	})
+	defer os.RemoveAll(dir)
+	path := func(name string) string { return filepath.Join(dir, name) }
+	inputs := []string{"--clusterroles", path("clusterroles.yaml"), "--namespaces", path("namespaces.txt")}
+
+	var tests = []struct {
+		label  string
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{"no filename", nil, 2, "", "--filename is required"},
+		{"bad output", append([]string{"-f", path("valid"), "-o", "yaml"}, inputs...), 2, "", `unknown format "yaml"`},
+		{"unknown flag", []string{"--file", path("valid")}, 2, "", "unknown flag"},
+		{"missing file", []string{"-f", path("missing.yaml")}, 2, "", "missing.yaml"},
+		{"missing namespaces", []string{"-f", path("valid"), "--namespaces", path("missing.txt")}, 2, "", "missing.txt"},
+		{"config without the operator ConfigMap", []string{"-f", path("valid"), "--config", path("clusterroles.yaml")}, 2, "", "does not hold the operator ConfigMap"},
+		{"valid", append([]string{"-f", path("valid")}, inputs...), 0, "SubjectPermission openshift-rbac-permissions-operator/dev-view", ""},
+		{"valid with config", append([]string{"-f", path("valid"), "--config", path("config.yaml"), "--strict"}, inputs...), 0, "team-a", ""},
+		{"errors", append([]string{"-f", path("invalid")}, inputs...), 1, "namespacesAllowedRegex", ""},
+		{"warnings", append([]string{"-f", path("warning")}, inputs...), 0, "ClusterRole edit does not exist", ""},
+		{"warnings with strict", append([]string{"-f", path("warning"), "--strict"}, inputs...), 1, "ClusterRole edit does not exist", ""},
+	}
+
+	for _, test := range tests {
+		code, out, errOut := runCommand(runLint, test.args...)
+		if code != test.code {
+			t.Errorf("%s: got exit code %d, want %d, stderr %q", test.label, code, test.code, errOut)
+		}
+		if !strings.Contains(out, test.stdout) {
+			t.Errorf("%s: got stdout %q, want it to contain %q", test.label, out, test.stdout)
+		}
+		if !strings.Contains(errOut, test.stderr) {
+			t.Errorf("%s: got stderr %q, want it to contain %q", test.label, errOut, test.stderr)
+		}
+	}
+}
+
+// TestLintJSON tests the JSON report of the lint command
+// given: the valid, invalid and warned about SubjectPermissions together
+// expected: exit code 1 and a report counting the error and the warning
+func TestLintJSON(t *testing.T) {
+	dir := writeManifests(t, map[string]string{
+		"subjectpermissions.yaml": validManifest + "---\n" + invalidManifest + "---\n" + missingClusterRoleManifest,
+		"clusterroles.yaml":       clusterRoleManifest,
+	})
+	defer os.RemoveAll(dir)
+
+	code, out, errOut := runCommand(runLint, "-f", filepath.Join(dir, "subjectpermissions.yaml"), "--clusterroles", filepath.Join(dir, "clusterroles.yaml"), "-o", "json")
+	if code != 1 {
+		t.Errorf("got exit code %d, want 1, stderr %q", code, errOut)
+	}
+	report := &lint.Report{}
+	if err := json.Unmarshal([]byte(out), report); err != nil {
+		t.Fatalf("Couldn't parse %q: %s", out, err)
+	}
+	if len(report.SubjectPermissions) != 3 || report.ErrorCount != 1 || report.WarningCount != 1 {
+		t.Errorf("got %d SubjectPermissions with %d errors and %d warnings, want 3 with 1 and 1", len(report.SubjectPermissions), report.ErrorCount, report.WarningCount)
+	}
+	if kind := report.SubjectPermissions[2].Kind; kind != "ClusterSubjectPermission" {
+		t.Errorf("got kind %s for the ClusterSubjectPermission", kind)
+	}
+}
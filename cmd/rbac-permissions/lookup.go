@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/openshift/rbac-permissions-operator/pkg/lookup"
+	"github.com/openshift/rbac-permissions-operator/pkg/policy"
+	"github.com/spf13/pflag"
+)
+
+// runLookup lists the SubjectPermissions giving a subject access to a namespace, read from the cluster
+// or, with --filename, from manifests
+func runLookup(args []string) int {
+	flags := pflag.NewFlagSet("lookup", pflag.ContinueOnError)
+	flags.SetOutput(stderr)
+	subjectFlag := flags.String("subject", "", "Subject to look up, as Kind:name, or ServiceAccount:namespace/name")
+	namespaceFlag := flags.String("namespace", "", "Namespace the access is looked up in")
+	filenames := flags.StringSliceP("filename", "f", nil, "Manifests of the SubjectPermissions, ClusterRoles, Namespaces and operator ConfigMap, instead of the cluster")
+	output := flags.StringP("output", "o", "table", "Output format, table or json")
+	all := flags.Bool("all", false, "Also list the entries naming the subject that do not grant it access, with the reason")
+	// --kubeconfig is registered by controller-runtime
+	flags.AddGoFlagSet(flag.CommandLine)
+	if err := flags.Parse(args); err != nil {
+		// the usage is already printed for --help
+		if err != pflag.ErrHelp {
+			fmt.Fprintln(stderr, err)
+		}
+		return 2
+	}
+
+	if *subjectFlag == "" || *namespaceFlag == "" {
+		fmt.Fprintln(stderr, "--subject and --namespace are required")
+		return 2
+	}
+	subject, err := policy.ParseSubject(*subjectFlag)
+	if err != nil {
+		fmt.Fprintf(stderr, "--subject: %v\n", err)
+		return 2
+	}
+	if *output != "table" && *output != "json" {
+		fmt.Fprintf(stderr, "--output: unknown format %q\n", *output)
+		return 2
+	}
+
+	var m *manifests
+	if len(*filenames) > 0 {
+		m, err = fromFiles(*filenames)
+	} else {
+		m, err = fromCluster(context.TODO())
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	protectedNamespaces, bindingPolicy, err := m.operatorConfig()
+	if err != nil {
+		fmt.Fprintf(stderr, "operator ConfigMap: %v\n", err)
+		return 1
+	}
+
+	// ClusterRoles are only reported missing when the manifests hold some
+	entries := lookup.Lookup(lookup.Input{
+		Subject:             subject,
+		Namespace:           m.namespace(*namespaceFlag),
+		SubjectPermissions:  m.SubjectPermissions,
+		ClusterRoles:        m.ClusterRoles,
+		Now:                 time.Now(),
+		ProtectedNamespaces: protectedNamespaces,
+		Policy:              bindingPolicy,
+	})
+	if !*all {
+		entries = lookup.Granted(entries)
+	}
+
+	if *output == "json" {
+		err = printLookupJSON(stdout, entries)
+	} else {
+		err = printLookupTable(stdout, entries, *subjectFlag, *namespaceFlag)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// printLookupJSON prints the entries as a JSON array
+func printLookupJSON(w io.Writer, entries []lookup.Entry) error {
+	if entries == nil {
+		entries = []lookup.Entry{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(entries)
+}
+
+// printLookupTable prints one row per entry
+func printLookupTable(w io.Writer, entries []lookup.Entry, subject, namespace string) error {
+	if len(entries) == 0 {
+		_, err := fmt.Fprintf(w, "No SubjectPermission gives %s access to namespace %s\n", subject, namespace)
+		return err
+	}
+
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "SUBJECTPERMISSION\tENTRY\tCLUSTERROLE\tGRANTED\tBINDING\tEXPLANATION")
+	for _, entry := range entries {
+		name := entry.Kind + " " + entry.Name
+		if entry.Namespace != "" {
+			name = entry.Kind + " " + entry.Namespace + "/" + entry.Name
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%t\t%s\t%s\n", name, entry.Field, entry.ClusterRoleName, entry.Granted, entry.BindingName, entry.Explanation)
+	}
+	return table.Flush()
+}
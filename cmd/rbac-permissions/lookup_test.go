@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openshift/rbac-permissions-operator/pkg/lookup"
+)
+
+const lookupManifests = `apiVersion: managed.openshift.io/v1alpha1
+kind: SubjectPermission
+metadata:
+  name: dev-view
+  namespace: openshift-rbac-permissions-operator
+spec:
+  subjectKind: Group
+  subjectName: dev
+  permissions:
+  - clusterRoleName: view
+    namespacesAllowedRegex: "^team-"
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: view
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: team-a
+`
+
+// writeManifests writes each of files, by relative path, to a new directory and returns it
+func writeManifests(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "rbac-permissions")
+	if err != nil {
+		t.Fatalf("Couldn't create directory: %s", err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatalf("Couldn't create directory of %s: %s", name, err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("Couldn't write %s: %s", name, err)
+		}
+	}
+	return dir
+}
+
+// runCommand runs a command with args and returns its exit code and what it wrote
+func runCommand(run func(args []string) int, args ...string) (int, string, string) {
+	var out, errOut bytes.Buffer
+	stdout, stderr = &out, &errOut
+	defer func() { stdout, stderr = os.Stdout, os.Stderr }()
+	code := run(args)
+	return code, out.String(), errOut.String()
+}
+
+// TestLookup tests the exit codes and the output of the lookup command
+// given: a SubjectPermission granting view to Group dev in the team- namespaces
+// expected: usage errors exit with 2, unreadable manifests with 1, the granted entry is printed
+// as a table or as JSON, and --all also prints the entries that are not granted
+func TestLookup(t *testing.T) {
+	dir := writeManifests(t, map[string]string{"manifests.yaml": lookupManifests})
+	defer os.RemoveAll(dir)
+
+	var tests = []struct {
+		label    string
+		args     []string
+		code     int
+		stdout   []string
+		stderr   string
+		expected []lookup.Entry
+	}{
+		{"no flags", nil, 2, nil, "--subject and --namespace are required", nil},
+		{"no namespace", []string{"--subject", "Group:dev", "-f", dir}, 2, nil, "--subject and --namespace are required", nil},
+		{"bad subject", []string{"--subject", "dev", "--namespace", "team-a", "-f", dir}, 2, nil, "--subject:", nil},
+		{"bad output", []string{"--subject", "Group:dev", "--namespace", "team-a", "-f", dir, "-o", "yaml"}, 2, nil, `unknown format "yaml"`, nil},
+		{"unknown flag", []string{"--subjects", "Group:dev"}, 2, nil, "unknown flag", nil},
+		{"missing file", []string{"--subject", "Group:dev", "--namespace", "team-a", "-f", filepath.Join(dir, "missing.yaml")}, 1, nil, "missing.yaml", nil},
+		{"table", []string{"--subject", "Group:dev", "--namespace", "team-a", "-f", dir}, 0, []string{"SUBJECTPERMISSION", "SubjectPermission openshift-rbac-permissions-operator/dev-view", "spec.permissions[0]", "view", "true"}, "", nil},
+		{"table without access", []string{"--subject", "Group:dev", "--namespace", "other", "-f", dir}, 0, []string{"No SubjectPermission gives Group:dev access to namespace other"}, "", nil},
+		{"table of another subject", []string{"--subject", "User:alice", "--namespace", "team-a", "-f", dir}, 0, []string{"No SubjectPermission gives User:alice access to namespace team-a"}, "", nil},
+		{"json", []string{"--subject", "Group:dev", "--namespace", "team-a", "-f", dir, "-o", "json"}, 0, nil, "", []lookup.Entry{
+			{Kind: "SubjectPermission", Namespace: "openshift-rbac-permissions-operator", Name: "dev-view", Field: "spec.permissions[0]", ClusterRoleName: "view", Granted: true},
+		}},
+		{"json without access", []string{"--subject", "Group:dev", "--namespace", "other", "-f", dir, "-o", "json"}, 0, nil, "", []lookup.Entry{}},
+		{"json of all entries", []string{"--subject", "Group:dev", "--namespace", "other", "-f", dir, "-o", "json", "--all"}, 0, nil, "", []lookup.Entry{
+			{Kind: "SubjectPermission", Namespace: "openshift-rbac-permissions-operator", Name: "dev-view", Field: "spec.permissions[0]", ClusterRoleName: "view", Granted: false},
+		}},
+	}
+
+	for _, test := range tests {
+		code, out, errOut := runCommand(runLookup, test.args...)
+		if code != test.code {
+			t.Errorf("%s: got exit code %d, want %d, stderr %q", test.label, code, test.code, errOut)
+		}
+		if !strings.Contains(errOut, test.stderr) {
+			t.Errorf("%s: got stderr %q, want it to contain %q", test.label, errOut, test.stderr)
+		}
+		for _, expected := range test.stdout {
+			if !strings.Contains(out, expected) {
+				t.Errorf("%s: got stdout %q, want it to contain %q", test.label, out, expected)
+			}
+		}
+		if test.expected == nil {
+			continue
+		}
+
+		var entries []lookup.Entry
+		if err := json.Unmarshal([]byte(out), &entries); err != nil {
+			t.Fatalf("%s: Couldn't parse %q: %s", test.label, out, err)
+		}
+		if len(entries) != len(test.expected) {
+			t.Errorf("%s: got %d entries, want %d", test.label, len(entries), len(test.expected))
+			continue
+		}
+		for i, entry := range entries {
+			// the binding name and the explanation are covered by the lookup package
+			entry.BindingName, entry.Explanation = "", ""
+			if entry != test.expected[i] {
+				t.Errorf("%s: got entry %+v, want %+v", test.label, entry, test.expected[i])
+			}
+		}
+	}
+}
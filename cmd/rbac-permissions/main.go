@@ -0,0 +1,53 @@
+// Command rbac-permissions inspects SubjectPermissions and ClusterSubjectPermissions, either on a live
+// cluster or from manifests.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// command is a subcommand, run with the arguments following its name. It returns the exit code
+type command struct {
+	summary string
+	run     func(args []string) int
+}
+
+// stdout and stderr are where the commands write, tests replace them
+var stdout, stderr io.Writer = os.Stdout, os.Stderr
+
+var commands = map[string]command{
+	"lint":   {"Check SubjectPermission manifests like the admission webhook and simulate their bindings, offline", runLint},
+	"lookup": {"List the SubjectPermissions giving a subject access to a namespace, and why", runLookup},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	os.Exit(cmd.run(os.Args[2:]))
+}
+
+func usage() {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := []string{"Usage: rbac-permissions <command> [flags]", "", "Commands:"}
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("  %-10s %s", name, commands[name].summary))
+	}
+	fmt.Fprintln(stderr, strings.Join(lines, "\n"))
+}
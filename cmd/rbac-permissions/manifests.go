@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/openshift/rbac-permissions-operator/pkg/apis"
+	managedv1alpha1 "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	controllerutil "github.com/openshift/rbac-permissions-operator/pkg/controller/utils"
+	"github.com/openshift/rbac-permissions-operator/pkg/dedicatedadmin"
+	"github.com/openshift/rbac-permissions-operator/pkg/policy"
+	"github.com/openshift/rbac-permissions-operator/pkg/utility"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+// manifests holds the objects the commands work on, read from a live cluster or from files
+type manifests struct {
+	// SubjectPermissions, ClusterSubjectPermissions are converted by utility.SubjectPermissionForCluster
+	SubjectPermissions []*managedv1alpha1.SubjectPermission
+	ClusterRoles       []rbacv1.ClusterRole
+	Namespaces         []corev1.Namespace
+	// OperatorConfig is the operator ConfigMap, nil when it was not found
+	OperatorConfig *corev1.ConfigMap
+}
+
+// newScheme returns a Scheme knowing the Kubernetes and the operator types
+func newScheme() (*runtime.Scheme, error) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := apis.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	return scheme, nil
+}
+
+// fromCluster reads the manifests from the cluster of the kubeconfig
+func fromCluster(ctx context.Context) (*manifests, error) {
+	scheme, err := newScheme()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, err
+	}
+
+	m := &manifests{}
+	m.SubjectPermissions, err = controllerutil.ListSubjectPermissions(ctx, c, &client.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	clusterRoleList := &rbacv1.ClusterRoleList{}
+	if err = c.List(ctx, &client.ListOptions{}, clusterRoleList); err != nil {
+		return nil, err
+	}
+	m.ClusterRoles = clusterRoleList.Items
+	namespaceList := &corev1.NamespaceList{}
+	if err = c.List(ctx, &client.ListOptions{}, namespaceList); err != nil {
+		return nil, err
+	}
+	m.Namespaces = namespaceList.Items
+
+	m.OperatorConfig, err = dedicatedadmin.GetOperatorConfig(ctx, c)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, err
+		}
+		m.OperatorConfig = nil
+	}
+	return m, nil
+}
+
+// fromFiles reads the manifests from YAML or JSON files, directories are read recursively.
+// A file can hold several documents and Lists, the kinds the commands do not use are ignored
+func fromFiles(paths []string) (*manifests, error) {
+	scheme, err := newScheme()
+	if err != nil {
+		return nil, err
+	}
+	decoder := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+
+	m := &manifests{}
+	for _, root := range paths {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || (path != root && !isManifest(path)) {
+				return nil
+			}
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if err = m.decode(decoder, data); err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// isManifest checks if path names a YAML or JSON file
+func isManifest(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// decode adds the objects of every document of data
+func (m *manifests) decode(decoder runtime.Decoder, data []byte) error {
+	documents := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		raw := runtime.RawExtension{}
+		err := documents.Decode(&raw)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(bytes.TrimSpace(raw.Raw)) == 0 || bytes.Equal(bytes.TrimSpace(raw.Raw), []byte("null")) {
+			continue
+		}
+		object, _, err := decoder.Decode(raw.Raw, nil, nil)
+		if err != nil {
+			if runtime.IsNotRegisteredError(err) {
+				continue
+			}
+			return err
+		}
+		if err = m.add(decoder, object); err != nil {
+			return err
+		}
+	}
+}
+
+// add adds an object, the items of a List are added one by one
+func (m *manifests) add(decoder runtime.Decoder, object runtime.Object) error {
+	switch o := object.(type) {
+	case *managedv1alpha1.SubjectPermission:
+		m.SubjectPermissions = append(m.SubjectPermissions, o)
+	case *managedv1alpha1.ClusterSubjectPermission:
+		m.SubjectPermissions = append(m.SubjectPermissions, utility.SubjectPermissionForCluster(o))
+	case *rbacv1.ClusterRole:
+		m.ClusterRoles = append(m.ClusterRoles, *o)
+	case *corev1.Namespace:
+		m.Namespaces = append(m.Namespaces, *o)
+	case *corev1.ConfigMap:
+		if dedicatedadmin.IsOperatorConfig(o) {
+			m.OperatorConfig = o
+		}
+	case *corev1.List:
+		for _, item := range o.Items {
+			itemObject, _, err := decoder.Decode(item.Raw, nil, nil)
+			if err != nil {
+				if runtime.IsNotRegisteredError(err) {
+					continue
+				}
+				return err
+			}
+			if err = m.add(decoder, itemObject); err != nil {
+				return err
+			}
+		}
+	case *managedv1alpha1.SubjectPermissionList:
+		for i := range o.Items {
+			m.SubjectPermissions = append(m.SubjectPermissions, &o.Items[i])
+		}
+	case *managedv1alpha1.ClusterSubjectPermissionList:
+		for i := range o.Items {
+			m.SubjectPermissions = append(m.SubjectPermissions, utility.SubjectPermissionForCluster(&o.Items[i]))
+		}
+	case *rbacv1.ClusterRoleList:
+		m.ClusterRoles = append(m.ClusterRoles, o.Items...)
+	case *corev1.NamespaceList:
+		m.Namespaces = append(m.Namespaces, o.Items...)
+	}
+	return nil
+}
+
+// namespace returns the Namespace called name, or one without labels when it was not read
+func (m *manifests) namespace(name string) corev1.Namespace {
+	for _, namespace := range m.Namespaces {
+		if namespace.Name == name {
+			return namespace
+		}
+	}
+	namespace := corev1.Namespace{}
+	namespace.Name = name
+	return namespace
+}
+
+// operatorConfig returns the protected namespaces and the Policy of the operator ConfigMap,
+// nothing is protected or restricted without it
+func (m *manifests) operatorConfig() (string, *policy.Policy, error) {
+	if m.OperatorConfig == nil {
+		return "", nil, nil
+	}
+	bindingPolicy, err := policy.FromConfigMap(m.OperatorConfig)
+	if err != nil {
+		return "", nil, err
+	}
+	return dedicatedadmin.ProtectedNamespaces(m.OperatorConfig), bindingPolicy, nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+)
+
+const (
+	subjectPermissionManifest = `apiVersion: managed.openshift.io/v1alpha1
+kind: SubjectPermission
+metadata:
+  name: dev
+  namespace: openshift-rbac-permissions-operator
+spec:
+  subjectKind: Group
+  subjectName: dev
+`
+	clusterSubjectPermissionManifest = `apiVersion: managed.openshift.io/v1alpha1
+kind: ClusterSubjectPermission
+metadata:
+  name: ops
+spec:
+  subjectKind: Group
+  subjectName: ops
+`
+	clusterRoleManifest = `apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: view
+`
+	namespaceManifest = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: team-a
+`
+	unknownManifest = `apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: widget
+`
+	operatorConfigManifest = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: rbac-permissions-operator
+  namespace: openshift-rbac-permissions-operator
+data:
+  protected_namespaces: "^kube-.*"
+`
+	otherConfigMapManifest = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: other
+  namespace: openshift-rbac-permissions-operator
+`
+)
+
+// names lists the SubjectPermissions as namespace/name, followed by the ClusterRoles and the Namespaces
+func names(m *manifests) []string {
+	var names []string
+	for _, subjectPermission := range m.SubjectPermissions {
+		names = append(names, subjectPermission.Namespace+"/"+subjectPermission.Name)
+	}
+	for _, clusterRole := range m.ClusterRoles {
+		names = append(names, "ClusterRole "+clusterRole.Name)
+	}
+	for _, namespace := range m.Namespaces {
+		names = append(names, "Namespace "+namespace.Name)
+	}
+	return names
+}
+
+// TestDecode tests decoding the documents of a manifest
+// given: single and multi-document YAML, JSON, Lists, ClusterSubjectPermissions, ConfigMaps and unknown kinds
+// expected: the objects the commands use are added, ClusterSubjectPermissions as SubjectPermissions without
+// namespace, only the operator ConfigMap is kept, unknown kinds are ignored and malformed documents fail
+func TestDecode(t *testing.T) {
+	scheme, err := newScheme()
+	if err != nil {
+		t.Fatalf("Couldn't create scheme: %s", err)
+	}
+	decoder := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+
+	var tests = []struct {
+		label          string
+		data           string
+		expected       []string
+		operatorConfig bool
+		fails          bool
+	}{
+		{"empty", "", nil, false, false},
+		{"single document", subjectPermissionManifest, []string{"openshift-rbac-permissions-operator/dev"}, false, false},
+		{"multiple documents", "---\n" + subjectPermissionManifest + "---\n" + clusterRoleManifest + "---\n---\n" + namespaceManifest,
+			[]string{"openshift-rbac-permissions-operator/dev", "ClusterRole view", "Namespace team-a"}, false, false},
+		{"json", `{"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": "team-a"}}`, []string{"Namespace team-a"}, false, false},
+		{"cluster subject permission", clusterSubjectPermissionManifest, []string{"/ops"}, false, false},
+		{"list", `apiVersion: v1
+kind: List
+items:
+- apiVersion: rbac.authorization.k8s.io/v1
+  kind: ClusterRole
+  metadata:
+    name: view
+- apiVersion: example.com/v1
+  kind: Widget
+  metadata:
+    name: widget
+- apiVersion: managed.openshift.io/v1alpha1
+  kind: ClusterSubjectPermission
+  metadata:
+    name: ops
+`, []string{"/ops", "ClusterRole view"}, false, false},
+		{"typed lists", `apiVersion: managed.openshift.io/v1alpha1
+kind: SubjectPermissionList
+items:
+- metadata:
+    name: dev
+    namespace: openshift-rbac-permissions-operator
+---
+apiVersion: managed.openshift.io/v1alpha1
+kind: ClusterSubjectPermissionList
+items:
+- metadata:
+    name: ops
+---
+apiVersion: v1
+kind: NamespaceList
+items:
+- metadata:
+    name: team-a
+`, []string{"openshift-rbac-permissions-operator/dev", "/ops", "Namespace team-a"}, false, false},
+		{"unknown kind", unknownManifest + "---\n" + namespaceManifest, []string{"Namespace team-a"}, false, false},
+		{"operator ConfigMap", operatorConfigManifest, nil, true, false},
+		{"other ConfigMap", otherConfigMapManifest, nil, false, false},
+		{"malformed", "kind: [", nil, false, true},
+		{"no kind", "metadata:\n  name: nothing\n", nil, false, true},
+	}
+
+	for _, test := range tests {
+		m := &manifests{}
+		err := m.decode(decoder, []byte(test.data))
+		if (err != nil) != test.fails {
+			t.Errorf("%s: got error %v, want failure %t", test.label, err, test.fails)
+			continue
+		}
+		if test.fails {
+			continue
+		}
+		if got := names(m); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: got %v, want %v", test.label, got, test.expected)
+		}
+		if (m.OperatorConfig != nil) != test.operatorConfig {
+			t.Errorf("%s: got operator ConfigMap %v, want %t", test.label, m.OperatorConfig, test.operatorConfig)
+		}
+	}
+}
+
+// TestFromFiles tests reading the manifests of files and directories
+// given: a directory with manifests, a nested directory, and files that are not manifests
+// expected: directories are read recursively, skipping the files that are not manifests, unless named
+// explicitly, and a file that cannot be read or decoded fails with its path
+func TestFromFiles(t *testing.T) {
+	dir := writeManifests(t, map[string]string{
+		"subjectpermission.yaml":        subjectPermissionManifest,
+		"clusterroles.yml":              clusterRoleManifest,
+		"nested/namespaces/team-a.json": `{"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": "team-a"}}`,
+		"notes.txt":                     "not a manifest",
+		"broken.txt":                    "kind: [",
+	})
+	defer os.RemoveAll(dir)
+	other := writeManifests(t, map[string]string{"ops.yaml": clusterSubjectPermissionManifest})
+	defer os.RemoveAll(other)
+
+	var tests = []struct {
+		label    string
+		paths    []string
+		expected []string
+		fails    bool
+	}{
+		{"directory", []string{dir}, []string{"openshift-rbac-permissions-operator/dev", "ClusterRole view", "Namespace team-a"}, false},
+		{"directories", []string{dir, other}, []string{"openshift-rbac-permissions-operator/dev", "/ops", "ClusterRole view", "Namespace team-a"}, false},
+		{"file", []string{filepath.Join(dir, "clusterroles.yml")}, []string{"ClusterRole view"}, false},
+		{"file that is not a manifest", []string{filepath.Join(dir, "notes.txt")}, nil, true},
+		{"malformed file", []string{filepath.Join(dir, "broken.txt")}, nil, true},
+		{"missing file", []string{filepath.Join(dir, "missing.yaml")}, nil, true},
+	}
+
+	for _, test := range tests {
+		m, err := fromFiles(test.paths)
+		if (err != nil) != test.fails {
+			t.Errorf("%s: got error %v, want failure %t", test.label, err, test.fails)
+			continue
+		}
+		if test.fails {
+			continue
+		}
+		if got := names(m); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: got %v, want %v", test.label, got, test.expected)
+		}
+	}
+}
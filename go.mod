@@ -1,5 +1,7 @@
 module github.com/openshift/rbac-permissions-operator
 
+go 1.12
+
 require (
 	contrib.go.opencensus.io/exporter/ocagent v0.4.9 // indirect
 	github.com/Azure/go-autorest v11.5.2+incompatible // indirect
@@ -8,7 +10,6 @@ require (
 	github.com/emicklei/go-restful v2.8.1+incompatible // indirect
 	github.com/go-openapi/spec v0.18.0
 	github.com/golang/groupcache v0.0.0-20180924190550-6f2cf27854a4 // indirect
-	github.com/golang/mock v1.2.0 // indirect
 	github.com/google/btree v0.0.0-20180813153112-4030bb1f1f0c // indirect
 	github.com/google/go-cmp v0.3.1 // indirect
 	github.com/google/uuid v1.0.0 // indirect
@@ -17,7 +18,7 @@ require (
 	github.com/gregjones/httpcache v0.0.0-20180305231024-9cad4c3443a7 // indirect
 	github.com/grpc-ecosystem/grpc-gateway v1.8.5 // indirect
 	github.com/imdario/mergo v0.3.6 // indirect
-	github.com/openshift/dedicated-admin-operator v0.0.0-20190712141448-4ec9a766214e
+	github.com/openshift/api v3.9.0+incompatible
 	github.com/openshift/operator-custom-metrics v0.2.1
 	github.com/operator-framework/operator-sdk v0.10.0
 	github.com/pborman/uuid v0.0.0-20180906182336-adf5a7427709 // indirect
@@ -0,0 +1,40 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterSubjectPermission is the Schema for the clustersubjectpermissions API.
+// It is cluster scoped and grants ClusterPermissions and Permissions in any Namespace,
+// while a SubjectPermission outside of the operator Namespace only binds inside its own Namespace.
+// +k8s:openapi-gen=true
+// +genclient:nonNamespaced
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state"
+// +kubebuilder:printcolumn:name="Mode",type="string",JSONPath=".spec.mode"
+// +kubebuilder:printcolumn:name="ClusterRoleBindings",type="integer",JSONPath=".status.clusterRoleBindingCount"
+// +kubebuilder:printcolumn:name="RoleBindings",type="integer",JSONPath=".status.roleBindingCount"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+type ClusterSubjectPermission struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SubjectPermissionSpec   `json:"spec,omitempty"`
+	Status SubjectPermissionStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterSubjectPermissionList contains a list of ClusterSubjectPermission.
+type ClusterSubjectPermissionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterSubjectPermission `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterSubjectPermission{}, &ClusterSubjectPermissionList{})
+}
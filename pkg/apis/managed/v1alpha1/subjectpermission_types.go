@@ -1,80 +1,292 @@
 package v1alpha1
 
 import (
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
-// SubjectPermissionSpec defines the desired state of SubjectPermission
+// SubjectPermissionSpec defines the desired state of SubjectPermission.
 // +k8s:openapi-gen=true
 type SubjectPermissionSpec struct {
-	// Kind of the Subject that is being granted permissions by the operator
-	SubjectKind string `json:"subjectKind"`
-	// Name of the Subject granted permissions by the operator
-	SubjectName string `json:"subjectName"`
-	// List of permissions applied at Cluster scope
+	// Kind of the Subject that is being granted permissions by the operator.
+	// Kept for compatibility, use Subjects instead.
+	// +optional
+	SubjectKind string `json:"subjectKind,omitempty"`
+	// Name of the Subject granted permissions by the operator.
+	// Kept for compatibility, use Subjects instead.
+	// +optional
+	SubjectName string `json:"subjectName,omitempty"`
+	// Namespace of the Subject, required when SubjectKind is ServiceAccount.
+	// Kept for compatibility, use Subjects instead.
+	// +optional
+	SubjectNamespace string `json:"subjectNamespace,omitempty"`
+	// Subjects granted permissions by the operator, all of them are bound together.
+	// APIGroup defaults to rbac.authorization.k8s.io for User and Group, and to "" for ServiceAccount.
+	// +optional
+	Subjects []rbacv1.Subject `json:"subjects,omitempty"`
+	// List of permissions applied at Cluster scope.
 	// +optional
 	ClusterPermissions []string `json:"clusterPermissions,omitempty"`
-	// List of permissions applied at Namespace scope
+	// List of permissions applied at Namespace scope.
 	// +optional
 	Permissions []Permission `json:"permissions,omitempty"`
+	// DeletionPolicy controls what happens to the generated bindings when the SubjectPermission is deleted.
+	// Defaults to Delete.
+	// +kubebuilder:validation:Enum=Delete,Retain
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// Mode controls whether the operator manages the bindings or only reports the changes it would make.
+	// Defaults to Enforce.
+	// +kubebuilder:validation:Enum=Enforce,Audit
+	// +optional
+	Mode Mode `json:"mode,omitempty"`
+	// Suspend freezes the bindings of the SubjectPermission while it is true, nothing is created, repaired
+	// or revoked. Deleting the SubjectPermission still revokes them, unless DeletionPolicy is Retain.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+	// VerifySubjects holds back the bindings until every Subject exists, Groups and Users are looked up in
+	// user.openshift.io when the cluster serves it, ServiceAccounts in their namespace.
+	// +optional
+	VerifySubjects bool `json:"verifySubjects,omitempty"`
+	// Validity limits the time all the permissions are granted for.
+	Validity `json:",inline"`
+}
+
+// Mode defines whether the operator writes the bindings of a SubjectPermission.
+type Mode string
+
+const (
+	// ModeEnforce creates, updates and deletes the bindings of the SubjectPermission.
+	ModeEnforce Mode = "Enforce"
+	// ModeAudit plans the bindings of the SubjectPermission and reports them, with the changes it would make,
+	// in its status without writing anything. The bindings in place are left alone, but the ones it owns
+	// are revoked when the SubjectPermission is deleted, unless DeletionPolicy is Retain.
+	ModeAudit Mode = "Audit"
+)
+
+// Validity defines the time window permissions are granted in, it is unbounded when no field is set.
+type Validity struct {
+	// NotBefore is the time the permissions start being granted at.
+	// +optional
+	NotBefore *metav1.Time `json:"notBefore,omitempty"`
+	// NotAfter is the time the permissions are revoked at.
+	// +optional
+	NotAfter *metav1.Time `json:"notAfter,omitempty"`
+	// Duration the permissions are granted for, from NotBefore or, when it is unset, from the creation of the SubjectPermission.
+	// Cannot be set together with NotAfter.
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
 }
 
-// Permission defines a Role that is bound to the Subject
-// Allowed in specific Namespaces
+// DeletionPolicy defines what happens to the bindings of a SubjectPermission when it is deleted.
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete revokes all bindings created for the SubjectPermission.
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyRetain leaves the bindings created for the SubjectPermission in place.
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+)
+
+// Permission defines a Role that is bound to the Subject.
+// Allowed in specific Namespaces.
 type Permission struct {
-	// ClusterRoleName to bind to the Subject as a RoleBindings in allowed Namespaces
-	ClusterRoleName string `json:"clusterRoleName"`
-	// NamespacesAllowedRegex representing allowed Namespaces
+	// ClusterRoleName to bind to the Subject as a RoleBindings in allowed Namespaces.
+	// Required unless Rules are set.
+	// +optional
+	ClusterRoleName string `json:"clusterRoleName,omitempty"`
+	// Rules of a ClusterRole created and owned by the operator, which is bound instead of ClusterRoleName.
+	// The ClusterRole gets a generated name and is deleted with the SubjectPermission.
+	// +optional
+	Rules []rbacv1.PolicyRule `json:"rules,omitempty"`
+	// NamespacesAllowedRegex representing allowed Namespaces.
+	// When empty, every Namespace is allowed.
 	NamespacesAllowedRegex string `json:"namespacesAllowedRegex,omitempty"`
-	// NamespacesDeniedRegex representing denied Namespaces
+	// NamespacesDeniedRegex representing denied Namespaces.
 	NamespacesDeniedRegex string `json:"namespacesDeniedRegex,omitempty"`
-	// Flag to indicate if "allow" regex is applied first
-	// If 'true' order is Allow then Deny, Else order is Deny then Allow
+	// NamespaceSelector restricts the Namespaces to the ones with matching labels, on top of the regexes.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// Flag to indicate if "allow" regex is applied first.
+	// If 'true' order is Allow then Deny, Else order is Deny then Allow.
 	AllowFirst bool `json:"allowFirst"`
+	// Validity limits the time this permission is granted for, within the Validity of the SubjectPermission.
+	Validity `json:",inline"`
 }
 
-// SubjectPermissionStatus defines the observed state of SubjectPermission
+// SubjectPermissionStatus defines the observed state of SubjectPermission.
 // +k8s:openapi-gen=true
 type SubjectPermissionStatus struct {
-	// List of conditions for the CR
+	// List of conditions for the CR, at most one per type.
 	Conditions []Condition `json:"conditions,omitempty"`
-	// State that this condition represents
+	// State summarizing the conditions.
 	State string `json:"state"`
+	// ObservedGeneration is the generation of the spec the status was computed for.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// ExpiresAt is the time the next granted permission expires at, or the time all of them expired at.
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+	// RemainingTime until ExpiresAt, rounded to the minute, as of the last reconcile.
+	// +optional
+	RemainingTime string `json:"remainingTime,omitempty"`
+	// ExpiryWarnings are the Warning Events emitted for the permissions expiring within the warning window,
+	// so that each of them is emitted once.
+	// +optional
+	ExpiryWarnings []string `json:"expiryWarnings,omitempty"`
+	// ClusterRoleBindingCount is the number of ClusterRoleBindings granted.
+	// +optional
+	ClusterRoleBindingCount int `json:"clusterRoleBindingCount,omitempty"`
+	// RoleBindingCount is the number of RoleBindings granted across all Namespaces.
+	// +optional
+	RoleBindingCount int `json:"roleBindingCount,omitempty"`
+	// PlannedChangeCount is the number of changes Audit mode would make.
+	// +optional
+	PlannedChangeCount int `json:"plannedChangeCount,omitempty"`
+	// PlannedChanges Audit mode would make, at most the first 10.
+	// +optional
+	PlannedChanges []PlannedChange `json:"plannedChanges,omitempty"`
+	// ClusterPermissions lists the binding of each entry of the ClusterPermissions of the spec, in order.
+	// +optional
+	ClusterPermissions []ClusterPermissionStatus `json:"clusterPermissions,omitempty"`
+	// Permissions lists the bindings of each entry of the Permissions of the spec, in order.
+	// +optional
+	Permissions []PermissionStatus `json:"permissions,omitempty"`
+}
+
+// PlannedChange is a change of a binding or a generated ClusterRole that Audit mode would make.
+type PlannedChange struct {
+	// Action is Create, Update or Delete.
+	Action string `json:"action"`
+	// Kind of the object, ClusterRole, ClusterRoleBinding or RoleBinding.
+	Kind string `json:"kind"`
+	// Namespace of a RoleBinding.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Name of the object.
+	Name string `json:"name"`
+}
+
+// ClusterPermissionStatus is the ClusterRoleBinding granted for an entry of the ClusterPermissions.
+type ClusterPermissionStatus struct {
+	// ClusterRoleName that is bound.
+	ClusterRoleName string `json:"clusterRoleName"`
+	// BindingName of the ClusterRoleBinding, empty when it is not granted.
+	// +optional
+	BindingName string `json:"bindingName,omitempty"`
+	// Reason the ClusterRoleBinding is not granted.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// LastVerifiedTime is the last time the ClusterRoleBinding was checked against the cluster.
+	// +optional
+	LastVerifiedTime *metav1.Time `json:"lastVerifiedTime,omitempty"`
+}
+
+// PermissionStatus is the RoleBindings granted for a Permission, large lists of Namespaces only keep a sample.
+type PermissionStatus struct {
+	// ClusterRoleName that is bound.
+	ClusterRoleName string `json:"clusterRoleName"`
+	// BindingName of the RoleBindings, the same in every matched Namespace.
+	// +optional
+	BindingName string `json:"bindingName,omitempty"`
+	// Reason none of the RoleBindings is granted.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// MatchedNamespaceCount is the number of Namespaces the RoleBinding is granted in.
+	MatchedNamespaceCount int `json:"matchedNamespaceCount"`
+	// MatchedNamespaces the RoleBinding is granted in, at most the first 10.
+	// +optional
+	MatchedNamespaces []string `json:"matchedNamespaces,omitempty"`
+	// SkippedNamespaceCount is the number of Namespaces the RoleBinding is not granted in.
+	SkippedNamespaceCount int `json:"skippedNamespaceCount"`
+	// SkippedNamespaces the RoleBinding is not granted in and why, at most the first 10.
+	// +optional
+	SkippedNamespaces []SkippedNamespace `json:"skippedNamespaces,omitempty"`
+	// LastVerifiedTime is the last time the RoleBindings were checked against the cluster.
+	// +optional
+	LastVerifiedTime *metav1.Time `json:"lastVerifiedTime,omitempty"`
 }
 
-// Condition defines a single condition of running the operator against an instance of the SubjectPermission CR
+// SkippedNamespace is a Namespace a Permission is not granted in.
+type SkippedNamespace struct {
+	// Name of the Namespace.
+	Name string `json:"name"`
+	// Reason the Permission is not granted in the Namespace.
+	Reason string `json:"reason"`
+}
+
+// Condition defines a single condition of running the operator against an instance of the SubjectPermission CR.
 type Condition struct {
-	// LastTransitionTime is the last time this condition was active for the CR
+	// Type of the condition.
+	Type SubjectPermissionConditionType `json:"type"`
+	// LastTransitionTime is the last time Status changed.
 	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
-	// Message related to the condition
+	// Reason is a CamelCase word for the cause of the last update.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Message related to the condition.
 	// +optional
 	Message string `json:"message,omitempty"`
-	// ClusterRoleName in which this condition is true
-	ClusterRoleNames []string `json:"clusterRoleName"`
-	// Flag to indicate if condition status is currently active
+	// ClusterRoleName in which this condition is true.
+	// +optional
+	ClusterRoleNames []string `json:"clusterRoleName,omitempty"`
+	// Flag to indicate if condition status is currently active.
 	Status bool `json:"status"`
-	// State that this condition represents
-	State SubjectPermissionState `json:"state"`
 }
 
-// SubjectPermissionState defines various states a SubjectPermission CR can be in
+// SubjectPermissionConditionType defines the conditions a SubjectPermission CR reports.
+type SubjectPermissionConditionType string
+
+const (
+	// SubjectPermissionConditionReady is true when the bindings match the spec.
+	SubjectPermissionConditionReady SubjectPermissionConditionType = "Ready"
+	// SubjectPermissionConditionClusterRoleMissing is true while a referenced ClusterRole does not exist.
+	SubjectPermissionConditionClusterRoleMissing SubjectPermissionConditionType = "ClusterRoleMissing"
+	// SubjectPermissionConditionBindingFailed is true when writing the bindings failed.
+	SubjectPermissionConditionBindingFailed SubjectPermissionConditionType = "BindingFailed"
+	// SubjectPermissionConditionDegraded is true when the spec is invalid or part of it cannot be granted.
+	SubjectPermissionConditionDegraded SubjectPermissionConditionType = "Degraded"
+	// SubjectPermissionConditionSuspended is true while Suspend is set and the bindings are left alone.
+	SubjectPermissionConditionSuspended SubjectPermissionConditionType = "Suspended"
+	// SubjectPermissionConditionSubjectNotFound is true while VerifySubjects is set and a Subject does not exist.
+	SubjectPermissionConditionSubjectNotFound SubjectPermissionConditionType = "SubjectNotFound"
+)
+
+// SubjectPermissionState defines various states a SubjectPermission CR can be in.
 type SubjectPermissionState string
 
 const (
-	// SubjectPermissionCreated const for Created status
-	SubjectPermissionCreated SubjectPermissionState = "Created"
-	// SubjectPermissionFailed const for Failed status
+	// SubjectPermissionFailed const for Failed status.
 	SubjectPermissionFailed SubjectPermissionState = "Failed"
+	// SubjectPermissionClusterRoleMissing const for ClusterRoleMissing status.
+	SubjectPermissionClusterRoleMissing SubjectPermissionState = "ClusterRoleMissing"
+	// SubjectPermissionReady const for Ready status.
+	SubjectPermissionReady SubjectPermissionState = "Ready"
+	// SubjectPermissionPending const for Pending status, before NotBefore.
+	SubjectPermissionPending SubjectPermissionState = "Pending"
+	// SubjectPermissionExpired const for Expired status, after NotAfter or Duration.
+	SubjectPermissionExpired SubjectPermissionState = "Expired"
+	// SubjectPermissionAudit const for Audit status, in Audit mode.
+	SubjectPermissionAudit SubjectPermissionState = "Audit"
+	// SubjectPermissionSuspended const for Suspended status, while Suspend is set.
+	SubjectPermissionSuspended SubjectPermissionState = "Suspended"
+	// SubjectPermissionSubjectNotFound const for SubjectNotFound status, while a verified Subject does not exist.
+	SubjectPermissionSubjectNotFound SubjectPermissionState = "SubjectNotFound"
 )
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
-// SubjectPermission is the Schema for the subjectpermissions API
+// SubjectPermission is the Schema for the subjectpermissions API.
 // +k8s:openapi-gen=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state"
+// +kubebuilder:printcolumn:name="Mode",type="string",JSONPath=".spec.mode"
+// +kubebuilder:printcolumn:name="ClusterRoleBindings",type="integer",JSONPath=".status.clusterRoleBindingCount"
+// +kubebuilder:printcolumn:name="RoleBindings",type="integer",JSONPath=".status.roleBindingCount"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 type SubjectPermission struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -85,7 +297,7 @@ type SubjectPermission struct {
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
-// SubjectPermissionList contains a list of SubjectPermission
+// SubjectPermissionList contains a list of SubjectPermission.
 type SubjectPermissionList struct {
 	metav1.TypeMeta `json:",inline"`
 	metav1.ListMeta `json:"metadata,omitempty"`
@@ -13,9 +13,53 @@ import (
 
 func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenAPIDefinition {
 	return map[string]common.OpenAPIDefinition{
-		"github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1.SubjectPermission":       schema_pkg_apis_managed_v1alpha1_SubjectPermission(ref),
-		"github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1.SubjectPermissionSpec":   schema_pkg_apis_managed_v1alpha1_SubjectPermissionSpec(ref),
-		"github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1.SubjectPermissionStatus": schema_pkg_apis_managed_v1alpha1_SubjectPermissionStatus(ref),
+		"github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1.ClusterSubjectPermission": schema_pkg_apis_managed_v1alpha1_ClusterSubjectPermission(ref),
+		"github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1.SubjectPermission":        schema_pkg_apis_managed_v1alpha1_SubjectPermission(ref),
+		"github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1.SubjectPermissionSpec":    schema_pkg_apis_managed_v1alpha1_SubjectPermissionSpec(ref),
+		"github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1.SubjectPermissionStatus":  schema_pkg_apis_managed_v1alpha1_SubjectPermissionStatus(ref),
+	}
+}
+
+func schema_pkg_apis_managed_v1alpha1_ClusterSubjectPermission(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "ClusterSubjectPermission is the Schema for the clustersubjectpermissions API. It is cluster scoped and grants ClusterPermissions and Permissions in any Namespace, while a SubjectPermission outside of the operator Namespace only binds inside its own Namespace.",
+				Properties: map[string]spec.Schema{
+					"kind": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Kind is a string value representing the REST resource this object represents. Servers may infer this from the endpoint the client submits requests to. Cannot be updated. In CamelCase. More info: https://git.k8s.io/community/contributors/devel/api-conventions.md#types-kinds",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"apiVersion": {
+						SchemaProps: spec.SchemaProps{
+							Description: "APIVersion defines the versioned schema of this representation of an object. Servers should convert recognized schemas to the latest internal value, and may reject unrecognized values. More info: https://git.k8s.io/community/contributors/devel/api-conventions.md#resources",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"metadata": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"),
+						},
+					},
+					"spec": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1.SubjectPermissionSpec"),
+						},
+					},
+					"status": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1.SubjectPermissionStatus"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1.SubjectPermissionSpec", "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1.SubjectPermissionStatus", "k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"},
 	}
 }
 
@@ -23,7 +67,7 @@ func schema_pkg_apis_managed_v1alpha1_SubjectPermission(ref common.ReferenceCall
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "SubjectPermission is the Schema for the subjectpermissions API",
+				Description: "SubjectPermission is the Schema for the subjectpermissions API.",
 				Properties: map[string]spec.Schema{
 					"kind": {
 						SchemaProps: spec.SchemaProps{
@@ -66,25 +110,45 @@ func schema_pkg_apis_managed_v1alpha1_SubjectPermissionSpec(ref common.Reference
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "SubjectPermissionSpec defines the desired state of SubjectPermission",
+				Description: "SubjectPermissionSpec defines the desired state of SubjectPermission.",
 				Properties: map[string]spec.Schema{
 					"subjectKind": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Kind of the Subject that is being granted permissions by the operator",
+							Description: "Kind of the Subject that is being granted permissions by the operator. Kept for compatibility, use Subjects instead.",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
 					"subjectName": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Name of the Subject granted permissions by the operator",
+							Description: "Name of the Subject granted permissions by the operator. Kept for compatibility, use Subjects instead.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"subjectNamespace": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Namespace of the Subject, required when SubjectKind is ServiceAccount. Kept for compatibility, use Subjects instead.",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
+					"subjects": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Subjects granted permissions by the operator, all of them are bound together. APIGroup defaults to rbac.authorization.k8s.io for User and Group, and to \"\" for ServiceAccount.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/api/rbac/v1.Subject"),
+									},
+								},
+							},
+						},
+					},
 					"clusterPermissions": {
 						SchemaProps: spec.SchemaProps{
-							Description: "List of permissions applied at Cluster scope",
+							Description: "List of permissions applied at Cluster scope.",
 							Type:        []string{"array"},
 							Items: &spec.SchemaOrArray{
 								Schema: &spec.Schema{
@@ -98,7 +162,7 @@ func schema_pkg_apis_managed_v1alpha1_SubjectPermissionSpec(ref common.Reference
 					},
 					"permissions": {
 						SchemaProps: spec.SchemaProps{
-							Description: "List of permissions applied at Namespace scope",
+							Description: "List of permissions applied at Namespace scope.",
 							Type:        []string{"array"},
 							Items: &spec.SchemaOrArray{
 								Schema: &spec.Schema{
@@ -109,12 +173,57 @@ func schema_pkg_apis_managed_v1alpha1_SubjectPermissionSpec(ref common.Reference
 							},
 						},
 					},
+					"deletionPolicy": {
+						SchemaProps: spec.SchemaProps{
+							Description: "DeletionPolicy controls what happens to the generated bindings when the SubjectPermission is deleted. Defaults to Delete.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"mode": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Mode controls whether the operator manages the bindings or only reports the changes it would make. Defaults to Enforce.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"suspend": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Suspend freezes the bindings of the SubjectPermission while it is true, nothing is created, repaired or revoked. Deleting the SubjectPermission still revokes them, unless DeletionPolicy is Retain.",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+					"verifySubjects": {
+						SchemaProps: spec.SchemaProps{
+							Description: "VerifySubjects holds back the bindings until every Subject exists, Groups and Users are looked up in user.openshift.io when the cluster serves it, ServiceAccounts in their namespace.",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+					"notBefore": {
+						SchemaProps: spec.SchemaProps{
+							Description: "NotBefore is the time the permissions start being granted at.",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+					"notAfter": {
+						SchemaProps: spec.SchemaProps{
+							Description: "NotAfter is the time the permissions are revoked at.",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+					"duration": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Duration the permissions are granted for, from NotBefore or, when it is unset, from the creation of the SubjectPermission. Cannot be set together with NotAfter.",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
 				},
-				Required: []string{"subjectKind", "subjectName"},
 			},
 		},
 		Dependencies: []string{
-			"github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1.Permission"},
+			"github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1.Permission", "k8s.io/api/rbac/v1.Subject", "k8s.io/apimachinery/pkg/apis/meta/v1.Duration", "k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
 	}
 }
 
@@ -122,11 +231,11 @@ func schema_pkg_apis_managed_v1alpha1_SubjectPermissionStatus(ref common.Referen
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "SubjectPermissionStatus defines the observed state of SubjectPermission",
+				Description: "SubjectPermissionStatus defines the observed state of SubjectPermission.",
 				Properties: map[string]spec.Schema{
 					"conditions": {
 						SchemaProps: spec.SchemaProps{
-							Description: "List of conditions for the CR",
+							Description: "List of conditions for the CR, at most one per type.",
 							Type:        []string{"array"},
 							Items: &spec.SchemaOrArray{
 								Schema: &spec.Schema{
@@ -139,16 +248,110 @@ func schema_pkg_apis_managed_v1alpha1_SubjectPermissionStatus(ref common.Referen
 					},
 					"state": {
 						SchemaProps: spec.SchemaProps{
-							Description: "State that this condition represents",
+							Description: "State summarizing the conditions.",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
+					"observedGeneration": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ObservedGeneration is the generation of the spec the status was computed for.",
+							Type:        []string{"integer"},
+							Format:      "int64",
+						},
+					},
+					"expiresAt": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ExpiresAt is the time the next granted permission expires at, or the time all of them expired at.",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+					"remainingTime": {
+						SchemaProps: spec.SchemaProps{
+							Description: "RemainingTime until ExpiresAt, rounded to the minute, as of the last reconcile.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"expiryWarnings": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ExpiryWarnings are the Warning Events emitted for the permissions expiring within the warning window, so that each of them is emitted once.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+					"clusterRoleBindingCount": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ClusterRoleBindingCount is the number of ClusterRoleBindings granted.",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"roleBindingCount": {
+						SchemaProps: spec.SchemaProps{
+							Description: "RoleBindingCount is the number of RoleBindings granted across all Namespaces.",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"plannedChangeCount": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PlannedChangeCount is the number of changes Audit mode would make.",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"plannedChanges": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PlannedChanges Audit mode would make, at most the first 10.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1.PlannedChange"),
+									},
+								},
+							},
+						},
+					},
+					"clusterPermissions": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ClusterPermissions lists the binding of each entry of the ClusterPermissions of the spec, in order.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1.ClusterPermissionStatus"),
+									},
+								},
+							},
+						},
+					},
+					"permissions": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Permissions lists the bindings of each entry of the Permissions of the spec, in order.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1.PermissionStatus"),
+									},
+								},
+							},
+						},
+					},
 				},
 				Required: []string{"state"},
 			},
 		},
 		Dependencies: []string{
-			"github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1.Condition"},
+			"github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1.ClusterPermissionStatus", "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1.Condition", "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1.PermissionStatus", "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1.PlannedChange", "k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
 	}
 }
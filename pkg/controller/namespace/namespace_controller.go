@@ -3,17 +3,28 @@ package namespace
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"time"
 
 	managedv1alpha1 "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
 	controllerutil "github.com/openshift/rbac-permissions-operator/pkg/controller/utils"
+	"github.com/openshift/rbac-permissions-operator/pkg/dedicatedadmin"
+	"github.com/openshift/rbac-permissions-operator/pkg/planner"
+	"github.com/openshift/rbac-permissions-operator/pkg/policy"
+	"github.com/openshift/rbac-permissions-operator/pkg/utility"
+	"github.com/openshift/rbac-permissions-operator/pkg/validation"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 	"sigs.k8s.io/controller-runtime/pkg/source"
@@ -34,7 +45,12 @@ func Add(mgr manager.Manager) error {
 
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileNamespace{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+	return &ReconcileNamespace{
+		client:      mgr.GetClient(),
+		scheme:      mgr.GetScheme(),
+		recorder:    mgr.GetRecorder("namespace-controller"),
+		verifyUsers: controllerutil.UserAPIAvailable(mgr.GetRESTMapper()),
+	}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
@@ -45,8 +61,16 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
-	// Watch for changes to primary resource Namespace
-	err = c.Watch(&source.Kind{Type: &corev1.Namespace{}}, &handler.EnqueueRequestForObject{})
+	// Watch for changes to primary resource Namespace, updates only matter when they change the labels
+	// a Permission NamespaceSelector matches against
+	err = c.Watch(&source.Kind{Type: &corev1.Namespace{}}, &handler.EnqueueRequestForObject{}, namespacePredicate)
+	if err != nil {
+		return err
+	}
+
+	// Index the generated RoleBindings by their SubjectPermission, so that looking up
+	// the RoleBindings of one SubjectPermission in one namespace is served by the cache
+	err = mgr.GetFieldIndexer().IndexField(&v1.RoleBinding{}, controllerutil.OwnerUIDIndex, controllerutil.OwnerUIDIndexFunc)
 	if err != nil {
 		return err
 	}
@@ -54,6 +78,13 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 	return nil
 }
 
+// namespacePredicate filters out Namespace updates that cannot change which Permissions match the namespace
+var namespacePredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return !reflect.DeepEqual(e.MetaOld.GetLabels(), e.MetaNew.GetLabels())
+	},
+}
+
 // blank assignment to verify that ReconcileNamespace implements reconcile.Reconciler
 var _ reconcile.Reconciler = &ReconcileNamespace{}
 
@@ -61,8 +92,11 @@ var _ reconcile.Reconciler = &ReconcileNamespace{}
 type ReconcileNamespace struct {
 	// This client, initialized using mgr.Client() above, is a split client
 	// that reads objects from the cache and writes to the apiserver
-	client client.Client
-	scheme *runtime.Scheme
+	client   client.Client
+	scheme   *runtime.Scheme
+	recorder record.EventRecorder
+	// verifyUsers is set when the Groups and Users of the Subjects can be verified, see controllerutil.UserAPIAvailable
+	verifyUsers bool
 }
 
 // Reconcile reads that state of the cluster for a Namespace object and makes changes based on the state read
@@ -90,110 +124,133 @@ func (r *ReconcileNamespace) Reconcile(request reconcile.Request) (reconcile.Res
 		return reconcile.Result{}, err
 	}
 
-	namespaceList := &corev1.NamespaceList{}
-	opts := client.ListOptions{Namespace: request.Namespace}
-	err = r.client.List(context.TODO(), &opts, namespaceList)
-	if err != nil {
-		reqLogger.Error(err, "Failed to get namespaceList")
-		return reconcile.Result{}, err
+	// nothing can be created in a namespace that is going away
+	if instance.DeletionTimestamp != nil {
+		return reconcile.Result{}, nil
 	}
 
-	subjectPermissionList := &managedv1alpha1.SubjectPermissionList{}
-	opts = client.ListOptions{Namespace: request.Namespace}
-	err = r.client.List(context.TODO(), &opts, subjectPermissionList)
+	// ClusterSubjectPermissions are listed along with the SubjectPermissions
+	subjectPermissions, err := controllerutil.ListSubjectPermissions(context.TODO(), r.client, &client.ListOptions{})
 	if err != nil {
-		reqLogger.Error(err, "Failed to get clusterRoleBindingList")
+		reqLogger.Error(err, "Failed to get subjectPermissionList")
 		return reconcile.Result{}, err
 	}
 
+	// ClusterRoles are cluster scoped, they are needed to recognise the ones generated for Rules
 	clusterRoleList := &v1.ClusterRoleList{}
-	opts = client.ListOptions{Namespace: request.Namespace}
-	err = r.client.List(context.TODO(), &opts, clusterRoleList)
+	err = r.client.List(context.TODO(), &client.ListOptions{}, clusterRoleList)
 	if err != nil {
 		reqLogger.Error(err, "Failed to get clusterRoleList")
 		return reconcile.Result{}, err
 	}
 
-	roleBindingList := &v1.RoleBindingList{}
-	opts = client.ListOptions{Namespace: request.Namespace}
-	err = r.client.List(context.TODO(), &opts, roleBindingList)
+	// nothing is bound into the namespaces protected by the operator ConfigMap, the SubjectPermission
+	// controller re-evaluates every namespace when it changes
+	protectedNamespaces, err := dedicatedadmin.GetProtectedNamespaces(context.TODO(), r.client)
 	if err != nil {
-		reqLogger.Error(err, "Failed to get rolebindingList")
+		reqLogger.Error(err, "Failed to get the protected namespaces")
 		return reconcile.Result{}, err
 	}
 
-	// loop through all subject permissions
-	// get namespaces allowed in each permission
-	// if our namespace instance is in the safeList, create rolebinding and update condition
-	for _, subjectPermission := range subjectPermissionList.Items {
-		// loop through all permissions in each
-		for _, permission := range subjectPermission.Spec.Permissions {
-			var successfulClusterRoleNames []string
-			successfulClusterRoleNames = append(successfulClusterRoleNames, permission.ClusterRoleName)
-			// list of all namespaces in safelist
-			safeList := controllerutil.GenerateSafeList(permission.NamespacesAllowedRegex, permission.NamespacesDeniedRegex, namespaceList)
-
-			// if namespace is in safeList, create RoleBinding
-			if namespaceInSlice(instance.Name, safeList) {
-
-				roleBinding := controllerutil.NewRoleBindingForClusterRole(permission.ClusterRoleName, subjectPermission.Spec.SubjectName, subjectPermission.Spec.SubjectKind, instance.Name)
-
-				// if rolebinding is already created in the namespace, there's nothing to do
-				if rolebindingInNamespace(roleBinding, roleBindingList) {
-					return reconcile.Result{}, nil
-				}
+	// the ClusterRoles restricted by the policy of the operator ConfigMap are only bound to allowlisted subjects
+	bindingPolicy, err := policy.Get(context.TODO(), r.client)
+	if err != nil {
+		reqLogger.Error(err, "Failed to get the policy")
+		return reconcile.Result{}, err
+	}
 
-				err := r.client.Create(context.TODO(), roleBinding)
-				if err != nil {
-					var permissionClusterRoleNames []string
-					permissionClusterRoleNames = append(permissionClusterRoleNames, permission.ClusterRoleName)
-					// update the condition
-					unableToCreateRoleBindingMsg := fmt.Sprintf("Unable to create RoleBinding: %s", err.Error())
-					permissionUpdatedCondition := controllerutil.UpdateCondition(&subjectPermission, unableToCreateRoleBindingMsg, permissionClusterRoleNames, true, managedv1alpha1.SubjectPermissionFailed)
-					err = r.client.Status().Update(context.TODO(), permissionUpdatedCondition)
-					if err != nil {
-						reqLogger.Error(err, "Failed to update condition.")
-						return reconcile.Result{}, err
-					}
-					failedToCreateRoleBindingMsg := fmt.Sprintf("Failed to create rolebinding %s", roleBinding.Name)
-					reqLogger.Error(err, failedToCreateRoleBindingMsg)
-					return reconcile.Result{}, err
-				}
-				reqLogger.Error(err, "Failed to create clusterRoleBinding")
-				return reconcile.Result{}, err
-			}
-			// update condition of SubjectPermission object if all rolebindings created succesfully
-			permissionUpdatedCondition := controllerutil.UpdateCondition(&subjectPermission, "successfully created RoleBinding", successfulClusterRoleNames, true, managedv1alpha1.SubjectPermissionCreated)
-			err = r.client.Status().Update(context.TODO(), permissionUpdatedCondition)
+	// evaluate only this namespace against every Permission, the SubjectPermission
+	// controller takes care of the ClusterRoleBindings and of the other namespaces.
+	// A SubjectPermission failing to apply does not hold back the others
+	var applyErrs []error
+	for _, subjectPermission := range subjectPermissions {
+		// invalid SubjectPermissions are reported by the SubjectPermission controller
+		if subjectPermission.DeletionTimestamp != nil || len(validation.ValidateSubjectPermission(subjectPermission)) > 0 {
+			continue
+		}
+		// the SubjectPermission controller adds the finalizer before binding anything, a RoleBinding created
+		// before then would not be revoked when the SubjectPermission is deleted
+		if !controllerutil.ContainsString(subjectPermission.GetFinalizers(), controllerutil.SubjectPermissionFinalizer) {
+			continue
+		}
+		// nothing is written in Audit mode, the SubjectPermission controller reports the planned changes
+		if subjectPermission.Spec.Mode == managedv1alpha1.ModeAudit {
+			continue
+		}
+		// the bindings of a suspended SubjectPermission are left alone
+		if subjectPermission.Spec.Suspend {
+			continue
+		}
+
+		// only read the RoleBindings generated for this SubjectPermission in this namespace
+		roleBindingList := &v1.RoleBindingList{}
+		opts := client.MatchingField(controllerutil.OwnerUIDIndex, string(subjectPermission.UID))
+		opts.Namespace = instance.Name
+		err = r.client.List(context.TODO(), opts, roleBindingList)
+		if err != nil {
+			reqLogger.Error(err, "Failed to get rolebindingList")
+			return reconcile.Result{}, err
+		}
+
+		// the bindings are held back until every Subject exists, the SubjectPermission controller reports the missing ones
+		var missingSubjects []v1.Subject
+		if subjectPermission.Spec.VerifySubjects {
+			missingSubjects, err = controllerutil.MissingSubjects(context.TODO(), r.client, utility.SubjectsForSubjectPermission(subjectPermission), r.verifyUsers)
 			if err != nil {
-				reqLogger.Error(err, "Failed to update condition.")
+				reqLogger.Error(err, "Failed to verify the subjects")
 				return reconcile.Result{}, err
 			}
 		}
-	}
 
-	return reconcile.Result{}, nil
-}
+		// missing ClusterRoles are reported by the SubjectPermission controller, the
+		// RoleBindings are planned as if they existed
+		plan := planner.ForRoleBindings(planner.Input{
+			SubjectPermission:   subjectPermission,
+			Namespaces:          []corev1.Namespace{*instance},
+			ClusterRoles:        clusterRoleList.Items,
+			RoleBindings:        roleBindingList.Items,
+			Now:                 time.Now(),
+			ProtectedNamespaces: protectedNamespaces,
+			Policy:              bindingPolicy,
+			MissingSubjects:     missingSubjects,
+		})
 
-// check if namespace is in safeList
-func namespaceInSlice(namespace string, safeList []string) bool {
-	for _, ns := range safeList {
-		if ns == namespace {
-			return true
+		result, err := planner.Apply(context.TODO(), r.client, plan)
+		for _, ref := range result.Created {
+			reqLogger.Info(fmt.Sprintf("Successfully created RoleBinding %s for SubjectPermission %s", ref, subjectPermission.Name))
+			controllerutil.RecordEvent(r.recorder, subjectPermission, ref.Namespace, corev1.EventTypeNormal, controllerutil.EventReasonBindingCreated, fmt.Sprintf("Created RoleBinding %s for ClusterRole %s", ref, ref.ClusterRoleName))
 		}
-	}
-	return false
-}
-
-// check if rolebinding is already created in the namespace
-func rolebindingInNamespace(rolebinding *v1.RoleBinding, roleBindingList *v1.RoleBindingList) bool {
-	list := roleBindingList.Items
-	roleBindingName := rolebinding.Name
-
-	for _, rb := range list {
-		if rb.Name == roleBindingName {
-			return true
+		for _, ref := range result.Updated {
+			reqLogger.Info(fmt.Sprintf("Successfully updated RoleBinding %s for SubjectPermission %s", ref, subjectPermission.Name))
+			controllerutil.RecordEvent(r.recorder, subjectPermission, ref.Namespace, corev1.EventTypeNormal, controllerutil.EventReasonBindingUpdated, fmt.Sprintf("Updated RoleBinding %s for ClusterRole %s", ref, ref.ClusterRoleName))
+		}
+		for _, ref := range result.Deleted {
+			reqLogger.Info(fmt.Sprintf("Successfully deleted RoleBinding %s for SubjectPermission %s", ref, subjectPermission.Name))
+		}
+		for _, ref := range result.Revoked() {
+			controllerutil.RecordEvent(r.recorder, subjectPermission, ref.Namespace, corev1.EventTypeNormal, controllerutil.EventReasonBindingRevoked, fmt.Sprintf("Revoked RoleBinding %s for ClusterRole %s", ref, ref.ClusterRoleName))
+		}
+		if err != nil {
+			controllerutil.RecordEvent(r.recorder, subjectPermission, instance.Name, corev1.EventTypeWarning, controllerutil.EventReasonBindingFailed, err.Error())
+			// update the conditions, the SubjectPermission controller resets them once its bindings are applied
+			changed := controllerutil.SetCondition(subjectPermission, managedv1alpha1.SubjectPermissionConditionBindingFailed, true, controllerutil.ReasonApplyFailed, err.Error(), nil)
+			if controllerutil.SetCondition(subjectPermission, managedv1alpha1.SubjectPermissionConditionReady, false, controllerutil.ReasonApplyFailed, "", nil) {
+				changed = true
+			}
+			if subjectPermission.Status.State != string(managedv1alpha1.SubjectPermissionFailed) {
+				subjectPermission.Status.State = string(managedv1alpha1.SubjectPermissionFailed)
+				changed = true
+			}
+			if changed {
+				updateErr := controllerutil.UpdateSubjectPermissionStatus(context.TODO(), r.client, subjectPermission)
+				if updateErr != nil {
+					reqLogger.Error(updateErr, "Failed to update condition.")
+				}
+			}
+			reqLogger.Error(err, fmt.Sprintf("Failed to apply RoleBindings for SubjectPermission %s", subjectPermission.Name))
+			applyErrs = append(applyErrs, err)
 		}
 	}
-	return false
+
+	return reconcile.Result{}, utilerrors.NewAggregate(applyErrs)
 }
@@ -0,0 +1,225 @@
+package namespace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openshift/rbac-permissions-operator/pkg/apis"
+	"github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	controllerutil "github.com/openshift/rbac-permissions-operator/pkg/controller/utils"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func mockSubjectPermission() *v1alpha1.SubjectPermission {
+	return &v1alpha1.SubjectPermission{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "testSubjectPermission",
+			Namespace:  "openshift-rbac-permissions-operator",
+			UID:        "exampleUID",
+			Finalizers: []string{controllerutil.SubjectPermissionFinalizer},
+		},
+		Spec: v1alpha1.SubjectPermissionSpec{
+			SubjectName:        "exampleSubjectName",
+			SubjectKind:        "Group",
+			ClusterPermissions: []string{"exampleClusterRoleName"},
+			Permissions: []v1alpha1.Permission{
+				{
+					ClusterRoleName:        "exampleClusterRoleName",
+					NamespacesAllowedRegex: "^example-.*",
+					NamespacesDeniedRegex:  "^example-denied$",
+				},
+			},
+		},
+	}
+}
+
+// mockClusterRole returns the ClusterRole of the Permission of mockSubjectPermission, whose rules the default
+// policy checks before it is bound
+func mockClusterRole() *rbacv1.ClusterRole {
+	return &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "exampleClusterRoleName"}}
+}
+
+// TestReconcileEvaluatesOnlyTheNamespace tests that a Namespace event only touches that namespace
+// given: a SubjectPermission, an allowed and a denied namespace, a stale RoleBinding in the denied namespace
+// expected: a RoleBinding is created in the allowed namespace only, the stale one is revoked on the denied namespace event
+func TestReconcileEvaluatesOnlyTheNamespace(t *testing.T) {
+	ctx := context.TODO()
+	if err := apis.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("Unable to add apis scheme: (%v)", err)
+	}
+
+	subjectPermission := mockSubjectPermission()
+	reconciler := &ReconcileNamespace{
+		client: fake.NewFakeClient(
+			subjectPermission,
+			mockClusterRole(),
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "example-one"}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "example-two"}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "example-denied"}},
+			controllerutil.NewRoleBindingForClusterRole(subjectPermission, 0, "example-denied"),
+		),
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(100),
+	}
+
+	var tests = []struct {
+		namespace string
+		expected  map[string]int
+	}{
+		{"example-one", map[string]int{"example-one": 1, "example-two": 0, "example-denied": 1}},
+		{"example-denied", map[string]int{"example-one": 1, "example-two": 0, "example-denied": 0}},
+	}
+
+	for _, test := range tests {
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: test.namespace}})
+		if err != nil {
+			t.Fatalf("Reconcile of namespace %s failed: %s", test.namespace, err)
+		}
+
+		for namespace, expected := range test.expected {
+			rbList := &rbacv1.RoleBindingList{}
+			if err := reconciler.client.List(ctx, &client.ListOptions{Namespace: namespace}, rbList); err != nil {
+				t.Fatalf("Couldn't list RoleBindings: %s", err)
+			}
+			if len(rbList.Items) != expected {
+				t.Errorf("after reconciling %s: got %d RoleBindings in %s, want %d", test.namespace, len(rbList.Items), namespace, expected)
+			}
+		}
+	}
+
+	crbList := &rbacv1.ClusterRoleBindingList{}
+	if err := reconciler.client.List(ctx, &client.ListOptions{}, crbList); err != nil {
+		t.Fatalf("Couldn't list ClusterRoleBindings: %s", err)
+	}
+	if len(crbList.Items) != 0 {
+		t.Errorf("got %d ClusterRoleBindings, want none from the Namespace controller", len(crbList.Items))
+	}
+}
+
+// TestReconcileFollowsNamespaceLabels tests that a Permission with a NamespaceSelector follows the namespace labels
+// given: a Permission selecting namespaces labelled team=example, a namespace that gains then loses the label
+// expected: the RoleBinding is granted when the label is added and revoked when it is removed
+func TestReconcileFollowsNamespaceLabels(t *testing.T) {
+	ctx := context.TODO()
+	if err := apis.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("Unable to add apis scheme: (%v)", err)
+	}
+
+	subjectPermission := mockSubjectPermission()
+	subjectPermission.Spec.Permissions[0].NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"team": "example"}}
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "example-one"}}
+	reconciler := &ReconcileNamespace{
+		client:   fake.NewFakeClient(subjectPermission, mockClusterRole(), namespace),
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(100),
+	}
+
+	var tests = []struct {
+		labels   map[string]string
+		expected int
+	}{
+		{nil, 0},
+		{map[string]string{"team": "example"}, 1},
+		{map[string]string{"team": "other"}, 0},
+	}
+
+	for _, test := range tests {
+		namespace.Labels = test.labels
+		if err := reconciler.client.Update(ctx, namespace); err != nil {
+			t.Fatalf("Couldn't update namespace: %s", err)
+		}
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: namespace.Name}})
+		if err != nil {
+			t.Fatalf("Reconcile of namespace %s failed: %s", namespace.Name, err)
+		}
+
+		rbList := &rbacv1.RoleBindingList{}
+		if err := reconciler.client.List(ctx, &client.ListOptions{Namespace: namespace.Name}, rbList); err != nil {
+			t.Fatalf("Couldn't list RoleBindings: %s", err)
+		}
+		if len(rbList.Items) != test.expected {
+			t.Errorf("with labels %v: got %d RoleBindings, want %d", test.labels, len(rbList.Items), test.expected)
+		}
+	}
+}
+
+// TestReconcileSkipsSuspended tests that a suspended SubjectPermission is left alone
+// given: a suspended SubjectPermission, an allowed namespace and a stale RoleBinding in a denied namespace
+// expected: no RoleBinding is created or revoked
+func TestReconcileSkipsSuspended(t *testing.T) {
+	ctx := context.TODO()
+	if err := apis.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("Unable to add apis scheme: (%v)", err)
+	}
+
+	subjectPermission := mockSubjectPermission()
+	subjectPermission.Spec.Suspend = true
+	reconciler := &ReconcileNamespace{
+		client: fake.NewFakeClient(
+			subjectPermission,
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "example-one"}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "example-denied"}},
+			controllerutil.NewRoleBindingForClusterRole(subjectPermission, 0, "example-denied"),
+		),
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(100),
+	}
+
+	expected := map[string]int{"example-one": 0, "example-denied": 1}
+	for namespace := range expected {
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: namespace}})
+		if err != nil {
+			t.Fatalf("Reconcile of namespace %s failed: %s", namespace, err)
+		}
+	}
+	for namespace, count := range expected {
+		rbList := &rbacv1.RoleBindingList{}
+		if err := reconciler.client.List(ctx, &client.ListOptions{Namespace: namespace}, rbList); err != nil {
+			t.Fatalf("Couldn't list RoleBindings: %s", err)
+		}
+		if len(rbList.Items) != count {
+			t.Errorf("got %d RoleBindings in %s, want %d", len(rbList.Items), namespace, count)
+		}
+	}
+}
+
+// TestReconcileSkipsWithoutFinalizer tests that nothing is bound for a SubjectPermission before it carries the finalizer
+// given: a SubjectPermission the SubjectPermission controller did not add the finalizer to yet, and an allowed namespace
+// expected: no RoleBinding is created
+func TestReconcileSkipsWithoutFinalizer(t *testing.T) {
+	ctx := context.TODO()
+	if err := apis.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("Unable to add apis scheme: (%v)", err)
+	}
+
+	subjectPermission := mockSubjectPermission()
+	subjectPermission.Finalizers = nil
+	reconciler := &ReconcileNamespace{
+		client: fake.NewFakeClient(
+			subjectPermission,
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "example-one"}},
+		),
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(100),
+	}
+
+	_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "example-one"}})
+	if err != nil {
+		t.Fatalf("Reconcile of namespace example-one failed: %s", err)
+	}
+	rbList := &rbacv1.RoleBindingList{}
+	if err := reconciler.client.List(ctx, &client.ListOptions{Namespace: "example-one"}, rbList); err != nil {
+		t.Fatalf("Couldn't list RoleBindings: %s", err)
+	}
+	if len(rbList.Items) != 0 {
+		t.Errorf("got %d RoleBindings, want none before the finalizer is added", len(rbList.Items))
+	}
+}
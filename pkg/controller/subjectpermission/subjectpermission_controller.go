@@ -3,20 +3,32 @@ package subjectpermission
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"strings"
+	"time"
 
+	userv1 "github.com/openshift/api/user/v1"
 	managedv1alpha1 "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
 	controllerutil "github.com/openshift/rbac-permissions-operator/pkg/controller/utils"
+	"github.com/openshift/rbac-permissions-operator/pkg/dedicatedadmin"
 	"github.com/openshift/rbac-permissions-operator/pkg/localmetrics"
+	"github.com/openshift/rbac-permissions-operator/pkg/planner"
+	"github.com/openshift/rbac-permissions-operator/pkg/policy"
+	"github.com/openshift/rbac-permissions-operator/pkg/utility"
+	"github.com/openshift/rbac-permissions-operator/pkg/validation"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 	"sigs.k8s.io/controller-runtime/pkg/source"
@@ -24,6 +36,20 @@ import (
 
 var log = logf.Log.WithName("controller_subjectpermission")
 
+const (
+	// clusterRoleNameIndex indexes SubjectPermissions by the ClusterRoles they reference
+	clusterRoleNameIndex = "spec.clusterRoleNames"
+	// subjectIndex indexes SubjectPermissions by their Subjects, see controllerutil.SubjectKey
+	subjectIndex = "spec.subjects"
+	// expiryWarningWindow is how long before expiring a Warning Event is emitted for a SubjectPermission or a Permission
+	expiryWarningWindow = 15 * time.Minute
+	// eventReasonExpiringSoon is the reason of the Events warning about an upcoming expiry
+	eventReasonExpiringSoon = "ExpiringSoon"
+	// verificationInterval is how often the bindings are verified again when nothing changed, and
+	// LastVerifiedTime is refreshed
+	verificationInterval = 10 * time.Minute
+)
+
 /**
 * USER ACTION REQUIRED: This is a scaffold file intended for the user to modify with their own Controller
 * business logic.  Delete these comments after modifying this file.*
@@ -37,7 +63,12 @@ func Add(mgr manager.Manager) error {
 
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileSubjectPermission{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+	return &ReconcileSubjectPermission{
+		client:      mgr.GetClient(),
+		scheme:      mgr.GetScheme(),
+		recorder:    mgr.GetRecorder("subjectpermission-controller"),
+		verifyUsers: controllerutil.UserAPIAvailable(mgr.GetRESTMapper()),
+	}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
@@ -54,9 +85,215 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
+	// Watch for changes to primary resource ClusterSubjectPermission, its requests have no namespace
+	err = c.Watch(&source.Kind{Type: &managedv1alpha1.ClusterSubjectPermission{}}, &handler.EnqueueRequestForObject{})
+	if err != nil {
+		return err
+	}
+
+	// Index SubjectPermissions and ClusterSubjectPermissions by the ClusterRoles they reference
+	err = mgr.GetFieldIndexer().IndexField(&managedv1alpha1.SubjectPermission{}, clusterRoleNameIndex, clusterRoleNamesIndexFunc)
+	if err != nil {
+		return err
+	}
+	err = mgr.GetFieldIndexer().IndexField(&managedv1alpha1.ClusterSubjectPermission{}, clusterRoleNameIndex, clusterRoleNamesIndexFunc)
+	if err != nil {
+		return err
+	}
+
+	// Watch for ClusterRoles being created or deleted, or their rules changing as the policy checks them,
+	// and requeue the SubjectPermissions referencing them
+	err = c.Watch(&source.Kind{Type: &v1.ClusterRole{}},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: &clusterRoleMapper{client: mgr.GetClient()}},
+		predicate.Funcs{UpdateFunc: func(e event.UpdateEvent) bool {
+			oldClusterRole, okOld := e.ObjectOld.(*v1.ClusterRole)
+			newClusterRole, okNew := e.ObjectNew.(*v1.ClusterRole)
+			return okOld && okNew && !reflect.DeepEqual(oldClusterRole.Rules, newClusterRole.Rules)
+		}})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to the operator ConfigMap, and requeue every SubjectPermission so the protected namespaces
+	// are enforced right away. The other ConfigMaps of the cluster are filtered out
+	operatorConfigPredicate := predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return dedicatedadmin.IsOperatorConfig(e.Meta) },
+		UpdateFunc:  func(e event.UpdateEvent) bool { return dedicatedadmin.IsOperatorConfig(e.MetaNew) },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return dedicatedadmin.IsOperatorConfig(e.Meta) },
+		GenericFunc: func(e event.GenericEvent) bool { return dedicatedadmin.IsOperatorConfig(e.Meta) },
+	}
+	err = c.Watch(&source.Kind{Type: &corev1.ConfigMap{}},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: &allSubjectPermissionsMapper{client: mgr.GetClient()}},
+		operatorConfigPredicate)
+	if err != nil {
+		return err
+	}
+
+	// Index SubjectPermissions and ClusterSubjectPermissions by their Subjects
+	err = mgr.GetFieldIndexer().IndexField(&managedv1alpha1.SubjectPermission{}, subjectIndex, subjectsIndexFunc)
+	if err != nil {
+		return err
+	}
+	err = mgr.GetFieldIndexer().IndexField(&managedv1alpha1.ClusterSubjectPermission{}, subjectIndex, subjectsIndexFunc)
+	if err != nil {
+		return err
+	}
+
+	// Watch for Subjects being created or deleted, and requeue the SubjectPermissions verifying them.
+	// Groups and Users are only served by OpenShift
+	subjectKinds := map[string]runtime.Object{v1.ServiceAccountKind: &corev1.ServiceAccount{}}
+	if controllerutil.UserAPIAvailable(mgr.GetRESTMapper()) {
+		subjectKinds[v1.GroupKind] = &userv1.Group{}
+		subjectKinds[v1.UserKind] = &userv1.User{}
+	}
+	for kind, object := range subjectKinds {
+		err = c.Watch(&source.Kind{Type: object},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: &subjectMapper{client: mgr.GetClient(), kind: kind}},
+			predicate.Funcs{UpdateFunc: func(e event.UpdateEvent) bool { return false }})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Watch for changes to the generated ClusterRoles and bindings, and requeue the owning SubjectPermission so drift gets repaired
+	ownerRequests := &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(object handler.MapObject) []reconcile.Request {
+			return controllerutil.OwnerRequests(object.Meta)
+		}),
+	}
+	err = c.Watch(&source.Kind{Type: &v1.ClusterRole{}}, ownerRequests)
+	if err != nil {
+		return err
+	}
+	err = c.Watch(&source.Kind{Type: &v1.ClusterRoleBinding{}}, ownerRequests)
+	if err != nil {
+		return err
+	}
+	err = c.Watch(&source.Kind{Type: &v1.RoleBinding{}}, ownerRequests)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// clusterRoleNamesIndexFunc returns the names of all ClusterRoles referenced by a SubjectPermission or a ClusterSubjectPermission
+func clusterRoleNamesIndexFunc(object runtime.Object) []string {
+	var subjectPermission *managedv1alpha1.SubjectPermission
+	switch o := object.(type) {
+	case *managedv1alpha1.SubjectPermission:
+		subjectPermission = o
+	case *managedv1alpha1.ClusterSubjectPermission:
+		subjectPermission = utility.SubjectPermissionForCluster(o)
+	default:
+		return nil
+	}
+
+	var clusterRoleNames []string
+	for _, clusterRoleName := range subjectPermission.Spec.ClusterPermissions {
+		clusterRoleNames = appendIfMissing(clusterRoleNames, clusterRoleName)
+	}
+	for _, permission := range subjectPermission.Spec.Permissions {
+		// the ClusterRoles generated for Rules are watched through their ownership labels
+		if len(permission.Rules) == 0 {
+			clusterRoleNames = appendIfMissing(clusterRoleNames, permission.ClusterRoleName)
+		}
+	}
+	return clusterRoleNames
+}
+
+// clusterRoleMapper maps a ClusterRole to the SubjectPermissions and ClusterSubjectPermissions referencing it
+type clusterRoleMapper struct {
+	client client.Client
+}
+
+// Map implements handler.Mapper
+func (m *clusterRoleMapper) Map(object handler.MapObject) []reconcile.Request {
+	subjectPermissions, err := controllerutil.ListSubjectPermissions(context.TODO(), m.client, client.MatchingField(clusterRoleNameIndex, object.Meta.GetName()))
+	if err != nil {
+		log.Error(err, fmt.Sprintf("Failed to list SubjectPermissions referencing ClusterRole %s", object.Meta.GetName()))
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, subjectPermission := range subjectPermissions {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: subjectPermission.Namespace, Name: subjectPermission.Name},
+		})
+	}
+	return requests
+}
+
+// subjectsIndexFunc returns the keys of the Subjects of a SubjectPermission or a ClusterSubjectPermission
+func subjectsIndexFunc(object runtime.Object) []string {
+	var subjectPermission *managedv1alpha1.SubjectPermission
+	switch o := object.(type) {
+	case *managedv1alpha1.SubjectPermission:
+		subjectPermission = o
+	case *managedv1alpha1.ClusterSubjectPermission:
+		subjectPermission = utility.SubjectPermissionForCluster(o)
+	default:
+		return nil
+	}
+
+	var keys []string
+	for _, subject := range utility.SubjectsForSubjectPermission(subjectPermission) {
+		keys = appendIfMissing(keys, controllerutil.SubjectKey(subject))
+	}
+	return keys
+}
+
+// subjectMapper maps a Group, User or ServiceAccount to the SubjectPermissions and ClusterSubjectPermissions
+// verifying it as one of their Subjects
+type subjectMapper struct {
+	client client.Client
+	// kind of the Subject the mapped objects are
+	kind string
+}
+
+// Map implements handler.Mapper
+func (m *subjectMapper) Map(object handler.MapObject) []reconcile.Request {
+	key := controllerutil.SubjectKey(v1.Subject{Kind: m.kind, Namespace: object.Meta.GetNamespace(), Name: object.Meta.GetName()})
+	subjectPermissions, err := controllerutil.ListSubjectPermissions(context.TODO(), m.client, client.MatchingField(subjectIndex, key))
+	if err != nil {
+		log.Error(err, fmt.Sprintf("Failed to list SubjectPermissions for Subject %s", key))
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, subjectPermission := range subjectPermissions {
+		if !subjectPermission.Spec.VerifySubjects {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: subjectPermission.Namespace, Name: subjectPermission.Name},
+		})
+	}
+	return requests
+}
+
+// allSubjectPermissionsMapper maps an object to every SubjectPermission and ClusterSubjectPermission
+type allSubjectPermissionsMapper struct {
+	client client.Client
+}
+
+// Map implements handler.Mapper
+func (m *allSubjectPermissionsMapper) Map(object handler.MapObject) []reconcile.Request {
+	subjectPermissions, err := controllerutil.ListSubjectPermissions(context.TODO(), m.client, &client.ListOptions{})
+	if err != nil {
+		log.Error(err, fmt.Sprintf("Failed to list SubjectPermissions for %s", object.Meta.GetName()))
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, subjectPermission := range subjectPermissions {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: subjectPermission.Namespace, Name: subjectPermission.Name},
+		})
+	}
+	return requests
+}
+
 // blank assignment to verify that ReconcileSubjectPermission implements reconcile.Reconciler
 var _ reconcile.Reconciler = &ReconcileSubjectPermission{}
 
@@ -64,8 +301,11 @@ var _ reconcile.Reconciler = &ReconcileSubjectPermission{}
 type ReconcileSubjectPermission struct {
 	// This client, initialized using mgr.Client() above, is a split client
 	// that reads objects from the cache and writes to the apiserver
-	client client.Client
-	scheme *runtime.Scheme
+	client   client.Client
+	scheme   *runtime.Scheme
+	recorder record.EventRecorder
+	// verifyUsers is set when the Groups and Users of the Subjects can be verified, see controllerutil.UserAPIAvailable
+	verifyUsers bool
 }
 
 // Reconcile reads that state of the cluster for a SubjectPermission object and makes changes based on the state read
@@ -77,9 +317,8 @@ func (r *ReconcileSubjectPermission) Reconcile(request reconcile.Request) (recon
 	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
 	reqLogger.Info("Reconciling SubjectPermission")
 
-	// Fetch the SubjectPermission instance
-	instance := &managedv1alpha1.SubjectPermission{}
-	err := r.client.Get(context.TODO(), request.NamespacedName, instance)
+	// Fetch the SubjectPermission instance, a request without namespace is for a ClusterSubjectPermission
+	instance, err := controllerutil.GetSubjectPermission(context.TODO(), r.client, request.NamespacedName)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			// Request object not found, could have been deleted after reconcile request.
@@ -91,243 +330,740 @@ func (r *ReconcileSubjectPermission) Reconcile(request reconcile.Request) (recon
 		return reconcile.Result{}, err
 	}
 
-	// The SubjectPermission CR is about to be deleted, so we need to clean up the
-	// Prometheus metrics, otherwise there will be stale data exported (for CRs
-	// which no longer exist).
+	// The SubjectPermission CR is about to be deleted, so we need to revoke the
+	// bindings it created and clean up the Prometheus metrics, otherwise there
+	// will be stale data exported (for CRs which no longer exist).
 	if instance.DeletionTimestamp != nil {
+		if !controllerutil.ContainsString(instance.GetFinalizers(), controllerutil.SubjectPermissionFinalizer) {
+			return reconcile.Result{}, nil
+		}
+
+		if instance.Spec.DeletionPolicy == managedv1alpha1.DeletionPolicyRetain {
+			reqLogger.Info(fmt.Sprintf("DeletionPolicy is %s, leaving bindings in place", instance.Spec.DeletionPolicy))
+		} else {
+			err = r.revokeAll(instance)
+			if err != nil {
+				reqLogger.Error(err, "Failed to revoke bindings")
+				return reconcile.Result{}, err
+			}
+		}
+
 		reqLogger.Info(fmt.Sprintf("Removing Prometheus metrics for SubjectPermission name='%s'", instance.ObjectMeta.GetName()))
 		localmetrics.DeletePrometheusMetric(instance)
+
+		instance.SetFinalizers(controllerutil.RemoveString(instance.GetFinalizers(), controllerutil.SubjectPermissionFinalizer))
+		err = controllerutil.UpdateSubjectPermission(context.TODO(), r.client, instance)
+		if err != nil {
+			reqLogger.Error(err, "Failed to remove finalizer")
+			return reconcile.Result{}, err
+		}
 		return reconcile.Result{}, nil
 	}
 
-	// get list of clusterRole on k8s
-	clusterRoleList := &v1.ClusterRoleList{}
-	opts := client.ListOptions{Namespace: request.Namespace}
-	err = r.client.List(context.TODO(), &opts, clusterRoleList)
-	if err != nil {
-		reqLogger.Error(err, "Failed to get clusterRoleList")
-		return reconcile.Result{}, err
+	// make sure the bindings are revoked before the SubjectPermission goes away
+	if !controllerutil.ContainsString(instance.GetFinalizers(), controllerutil.SubjectPermissionFinalizer) {
+		instance.SetFinalizers(append(instance.GetFinalizers(), controllerutil.SubjectPermissionFinalizer))
+		err = controllerutil.UpdateSubjectPermission(context.TODO(), r.client, instance)
+		if err != nil {
+			reqLogger.Error(err, "Failed to add finalizer")
+			return reconcile.Result{}, err
+		}
 	}
 
-	// if crClusterRoleNameList returns list of clusterRoleNames
-	crClusterRoleNameList := populateCrClusterRoleNames(instance, clusterRoleList)
-	for _, crClusterRoleName := range crClusterRoleNameList {
-
-		var clusterRoleNames []string
-		clusterRoleNames = append(clusterRoleNames, crClusterRoleName)
+	// nothing is created, repaired or revoked while suspended, the SubjectPermission is reconciled
+	// again once Suspend is unset, which changes its spec
+	localmetrics.SetSuspendedMetric(instance)
+	if instance.Spec.Suspend {
+		reqLogger.Info("SubjectPermission is suspended, leaving bindings alone")
+		if !setSuspended(instance) {
+			return reconcile.Result{}, nil
+		}
+		err = controllerutil.UpdateSubjectPermissionStatus(context.TODO(), r.client, instance)
+		if err != nil {
+			reqLogger.Error(err, "Failed to update condition.")
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{}, nil
+	}
 
-		// helper func to update the condition of the SubjectPermission object
-		instance := controllerutil.UpdateCondition(instance, crClusterRoleName+" for clusterPermission does not exist", clusterRoleNames, true, "Failed")
-		err = r.client.Status().Update(context.TODO(), instance)
+	// a SubjectPermission that got past the admission webhook, or was created before it, is not acted on
+	if allErrs := validation.ValidateSubjectPermission(instance); len(allErrs) > 0 {
+		message := "Invalid SubjectPermission: " + allErrs.ToAggregate().Error()
+		reqLogger.Info(message)
+		changed := controllerutil.SetCondition(instance, managedv1alpha1.SubjectPermissionConditionDegraded, true, controllerutil.ReasonInvalidSpec, message, nil)
+		if setResumed(instance) {
+			changed = true
+		}
+		if controllerutil.SetCondition(instance, managedv1alpha1.SubjectPermissionConditionReady, false, controllerutil.ReasonInvalidSpec, "", nil) {
+			changed = true
+		}
+		if setSummary(instance, managedv1alpha1.SubjectPermissionFailed) {
+			changed = true
+		}
+		if !changed {
+			return reconcile.Result{}, nil
+		}
+		err = controllerutil.UpdateSubjectPermissionStatus(context.TODO(), r.client, instance)
 		if err != nil {
 			reqLogger.Error(err, "Failed to update condition.")
 			return reconcile.Result{}, err
 		}
+		return reconcile.Result{}, nil
 	}
 
-	// get a list of clusterRoleBinding from k8s cluster list
+	// get list of clusterRole on k8s, ClusterRoles are cluster scoped
+	clusterRoleList := &v1.ClusterRoleList{}
+	err = r.client.List(context.TODO(), &client.ListOptions{}, clusterRoleList)
+	if err != nil {
+		reqLogger.Error(err, "Failed to get clusterRoleList")
+		return reconcile.Result{}, err
+	}
+
+	// get the NamespaceList, Namespaces are cluster scoped so the list is not restricted to a namespace
+	nsList := &corev1.NamespaceList{}
+	err = r.client.List(context.TODO(), &client.ListOptions{}, nsList)
+	if err != nil {
+		reqLogger.Error(err, "Failed to get namespaceList")
+		return reconcile.Result{}, err
+	}
+
+	// get a list of clusterRoleBinding from k8s cluster list, ClusterRoleBindings are cluster scoped
 	clusterRoleBindingList := &v1.ClusterRoleBindingList{}
-	opts = client.ListOptions{Namespace: request.Namespace}
-	err = r.client.List(context.TODO(), &opts, clusterRoleBindingList)
+	err = r.client.List(context.TODO(), &client.ListOptions{}, clusterRoleBindingList)
 	if err != nil {
 		reqLogger.Error(err, "Failed to get clusterRoleBindingList")
 		return reconcile.Result{}, err
 	}
 
-	// build a clusterRoleBindingNameList which consists of clusterRoleName-subjectName
-	crClusterRoleBindingNameList := buildClusterRoleBindingCRList(instance)
-
-	// check ClusterRoleBindingName
-	populateCrClusterRoleBindingNameList := populateClusterRoleBindingNames(crClusterRoleBindingNameList, clusterRoleBindingList)
+	// an empty namespace lists RoleBindings across all namespaces
+	roleBindingList := &v1.RoleBindingList{}
+	err = r.client.List(context.TODO(), &client.ListOptions{}, roleBindingList)
+	if err != nil {
+		reqLogger.Error(err, "Failed to get rolebindingList")
+		return reconcile.Result{}, err
+	}
 
-	// loop through crClusterRoleBindingNameList
-	for _, clusterRoleBindingName := range populateCrClusterRoleBindingNameList {
+	// nothing is bound into the namespaces protected by the operator ConfigMap
+	protectedNamespaces, err := dedicatedadmin.GetProtectedNamespaces(context.TODO(), r.client)
+	if err != nil {
+		reqLogger.Error(err, "Failed to get the protected namespaces")
+		return reconcile.Result{}, err
+	}
 
-		// get the clusterRoleName by spliting the clusterRoleBindng name
-		clusterRBName := strings.Split(clusterRoleBindingName, "-")
-		clusterRoleName := clusterRBName[0]
-		subjectName := clusterRBName[1]
+	// the ClusterRoles restricted by the policy of the operator ConfigMap are only bound to allowlisted subjects
+	bindingPolicy, err := policy.Get(context.TODO(), r.client)
+	if err != nil {
+		reqLogger.Error(err, "Failed to get the policy")
+		return reconcile.Result{}, err
+	}
 
-		// create a new clusterRoleBinding on cluster
-		newCRB := newClusterRoleBinding(clusterRoleName, subjectName, instance.Spec.SubjectKind)
-		err := r.client.Create(context.TODO(), newCRB)
+	// the bindings are held back until every Subject exists, the SubjectPermission is requeued when one is created
+	var missingSubjects []v1.Subject
+	if instance.Spec.VerifySubjects {
+		missingSubjects, err = controllerutil.MissingSubjects(context.TODO(), r.client, utility.SubjectsForSubjectPermission(instance), r.verifyUsers)
 		if err != nil {
-			var clusterRoleNames []string
-			clusterRoleNames = append(clusterRoleNames, clusterRoleName)
+			reqLogger.Error(err, "Failed to verify the subjects")
+			return reconcile.Result{}, err
+		}
+	}
+
+	// compute the bindings to create, update and delete for the whole SubjectPermission,
+	// only the permissions inside their Validity window are granted
+	now := time.Now()
+	plan := planner.New(planner.Input{
+		SubjectPermission:   instance,
+		Namespaces:          nsList.Items,
+		ClusterRoles:        clusterRoleList.Items,
+		ClusterRoleBindings: clusterRoleBindingList.Items,
+		RoleBindings:        roleBindingList.Items,
+		Now:                 now,
+		ProtectedNamespaces: protectedNamespaces,
+		Policy:              bindingPolicy,
+		MissingSubjects:     missingSubjects,
+	})
+
+	// Audit mode only reports the changes Apply would make
+	audit := instance.Spec.Mode == managedv1alpha1.ModeAudit
+	planned := planner.Preview(plan).Changes()
+	result, applyErr := &planner.Result{}, error(nil)
+	if audit {
+		for _, change := range planned {
+			reqLogger.Info(fmt.Sprintf("Audit mode, would %s %s %s", strings.ToLower(change.Action), change.Kind, changeName(change)))
+		}
+	} else {
+		result, applyErr = planner.Apply(context.TODO(), r.client, plan)
+	}
+	for _, name := range result.CreatedClusterRoles {
+		reqLogger.Info(fmt.Sprintf("Successfully created ClusterRole %s", name))
+	}
+	for _, name := range result.UpdatedClusterRoles {
+		reqLogger.Info(fmt.Sprintf("Successfully updated ClusterRole %s", name))
+	}
+	for _, name := range result.DeletedClusterRoles {
+		reqLogger.Info(fmt.Sprintf("Successfully deleted ClusterRole %s", name))
+	}
+	for _, ref := range result.Created {
+		reqLogger.Info(fmt.Sprintf("Successfully created %s %s", ref.Kind, ref))
+	}
+	for _, ref := range result.Updated {
+		reqLogger.Info(fmt.Sprintf("Successfully updated %s %s", ref.Kind, ref))
+	}
+	for _, ref := range result.Deleted {
+		reqLogger.Info(fmt.Sprintf("Successfully deleted %s %s", ref.Kind, ref))
+	}
 
-			// update the condition if creation of a ClusterRoleBinding has failed
-			instance := controllerutil.UpdateCondition(instance, "Unable to create ClusterRoleBinding: "+err.Error(), clusterRoleNames, true, managedv1alpha1.SubjectPermissionFailed)
-			err = r.client.Status().Update(context.TODO(), instance)
+	// the changes Apply failed to make are still pending, in Audit mode all of them are
+	pending := result.Failed
+	if audit {
+		pending = planned
+	}
+	localmetrics.SetPendingChangesMetric(instance, pending)
+
+	for _, ref := range result.Revoked() {
+		reqLogger.Info(fmt.Sprintf("Revoked %s %s for ClusterRole %s", ref.Kind, ref, ref.ClusterRoleName))
+	}
+	r.recordResult(instance, result)
+
+	if applyErr != nil {
+		reqLogger.Error(applyErr, "Failed to apply the bindings plan")
+		controllerutil.RecordEvent(r.recorder, instance, "", corev1.EventTypeWarning, controllerutil.EventReasonBindingFailed, applyErr.Error())
+		if setStatus(instance, plan, applyErr, now) {
+			err = controllerutil.UpdateSubjectPermissionStatus(context.TODO(), r.client, instance)
 			if err != nil {
 				reqLogger.Error(err, "Failed to update condition.")
-				return reconcile.Result{}, err
 			}
-			reqLogger.Error(err, "Failed to create clusterRoleBinding")
-			return reconcile.Result{}, err
 		}
-
-		// instead of updating the condition just log each successfully created ClusterRoleBinding
-		reqLogger.Info(fmt.Sprintf("Successfully created ClusterRoleBinding %s", clusterRoleName))
-
-		// Add Prometheus metrics for this CR
-		localmetrics.AddPrometheusMetric(instance)
-		return reconcile.Result{}, nil
+		return reconcile.Result{}, applyErr
 	}
 
-	// update condition if all ClusterRoleBindings added succesfully
-	instance = controllerutil.UpdateCondition(instance, "Successfully created all ClusterRoleBindings", populateCrClusterRoleBindingNameList, true, managedv1alpha1.SubjectPermissionCreated)
+	// the drift was repaired, count it
+	for _, drift := range plan.Drift {
+		if audit {
+			continue
+		}
+		reqLogger.Info(fmt.Sprintf("%s of %s %s drifted, restored them", drift.Reason, drift.Kind, drift.Name))
+		localmetrics.AddBindingDriftMetric(instance, drift.Kind, drift.Reason)
+	}
 
-	// get the NamespaceList
-	nsList := &corev1.NamespaceList{}
-	opts = client.ListOptions{Namespace: request.Namespace}
-	err = r.client.List(context.TODO(), &opts, nsList)
-	if err != nil {
-		reqLogger.Error(err, "Failed to get clusterRoleBindingList")
-		return reconcile.Result{}, err
+	for _, planErr := range plan.Errors {
+		if _, ok := planErr.(*planner.MissingClusterRoleError); !ok {
+			reqLogger.Info(planErr.Error())
+		}
 	}
 
-	// slice of clusterRoleName that does not exists as a clusterRole
-	permissionClusterRoleNameList := controllerutil.PopulateCrPermissionClusterRoleNames(instance, clusterRoleList)
-	for _, permissionClusterRoleName := range permissionClusterRoleNameList {
-		var permissionsClusterRoleNames []string
-		permissionsClusterRoleNames = append(permissionsClusterRoleNames, permissionClusterRoleName)
+	// the SubjectPermission is requeued once the missing ClusterRoles get created.
+	// Conditions are updated in place, so an unchanged status is not written and does not trigger another reconcile
+	statusChanged := false
+	if setPlannedChanges(instance, planned, audit) {
+		statusChanged = true
+		if audit {
+			r.recordPlannedChanges(instance, planned)
+		}
+	}
+	missing := missingClusterRoleNames(instance)
+	missingSubjectsBefore := missingSubjectsMessage(instance)
+	degradedBefore := degradedMessage(instance)
+	if setStatus(instance, plan, nil, now) {
+		statusChanged = true
+		r.warnClusterRolesMissing(instance, missing)
+		r.warnSubjectsMissing(instance, missingSubjectsBefore)
+		r.warnClusterPermissionsIgnored(instance, plan, degradedBefore)
+	}
+	if setInventory(instance, plan, now) {
+		statusChanged = true
+	}
+	if setExpiry(instance, now) {
+		statusChanged = true
+	}
+	if r.warnExpiringSoon(instance, now) {
+		statusChanged = true
+	}
 
-		// update condition
-		updatedSubjectPermission := controllerutil.UpdateCondition(instance, permissionClusterRoleName+" for clusterPermission does not exist", permissionsClusterRoleNames, true, managedv1alpha1.SubjectPermissionFailed)
-		err = r.client.Status().Update(context.TODO(), updatedSubjectPermission)
+	if statusChanged {
+		err = controllerutil.UpdateSubjectPermissionStatus(context.TODO(), r.client, instance)
 		if err != nil {
 			reqLogger.Error(err, "Failed to update condition.")
 			return reconcile.Result{}, err
 		}
 	}
 
-	// compile list of allowed namespaces only for this subject permission. NOT a list of subject permissions
-	for _, permission := range instance.Spec.Permissions {
-		var successfullRoleBindingNames []string
-		// list of all namespaces in safelist
-		safeList := controllerutil.GenerateSafeList(permission.NamespacesAllowedRegex, permission.NamespacesDeniedRegex, nsList)
+	// Add Prometheus metrics for this CR
+	localmetrics.AddPrometheusMetric(instance)
+	localmetrics.SetNamespaceMatchesMetric(instance, nsList.Items)
 
-		// for each safelisted namespace
-		for _, ns := range safeList {
-			// get a list of all rolebindings in namespace
-			rbList := &v1.RoleBindingList{}
-			opts := client.ListOptions{Namespace: ns}
-			err = r.client.List(context.TODO(), &opts, rbList)
+	// come back exactly when a permission starts, is about to expire or expires, and to verify the bindings
+	return reconcile.Result{RequeueAfter: requeueAfter(instance, now)}, nil
+}
 
-			// create roleBinding
-			roleBinding := controllerutil.NewRoleBindingForClusterRole(permission.ClusterRoleName, instance.Spec.SubjectName, instance.Spec.SubjectKind, ns)
+// validityState returns Pending before the Validity window of a SubjectPermission, Expired after it and Ready inside it
+func validityState(subjectPermission *managedv1alpha1.SubjectPermission, now time.Time) managedv1alpha1.SubjectPermissionState {
+	start, end := utility.ValidityWindow(subjectPermission.Spec.Validity, subjectPermission.CreationTimestamp.Time)
+	switch {
+	case start != nil && now.Before(*start):
+		return managedv1alpha1.SubjectPermissionPending
+	case end != nil && !now.Before(*end):
+		return managedv1alpha1.SubjectPermissionExpired
+	}
+	return managedv1alpha1.SubjectPermissionReady
+}
 
-			// if the rolebinding already exists then break
-			roleBindingExists := controllerutil.RoleBindingExists(roleBinding, rbList)
-			if roleBindingExists {
-				reqLogger.Info(fmt.Sprintf("rolebinding %s already exists", roleBinding.Name))
-				break
-			}
+// setStatus sets the conditions and the summary state of a SubjectPermission from its plan and the error
+// applying it. Returns true when the status changed
+func setStatus(subjectPermission *managedv1alpha1.SubjectPermission, plan *planner.Plan, applyErr error, now time.Time) bool {
+	var missingMessages, missingClusterRoleNames, subjectMessages, degradedMessages []string
+	for _, planErr := range plan.Errors {
+		switch e := planErr.(type) {
+		case *planner.MissingClusterRoleError:
+			missingMessages = append(missingMessages, planErr.Error())
+			missingClusterRoleNames = appendIfMissing(missingClusterRoleNames, e.ClusterRoleName)
+		case *planner.SubjectNotFoundError:
+			subjectMessages = append(subjectMessages, planErr.Error())
+		default:
+			degradedMessages = append(degradedMessages, planErr.Error())
+		}
+	}
 
-			err := r.client.Create(context.TODO(), roleBinding)
-			if err != nil {
-				var permissionsClusterRoleNames []string
-				permissionsClusterRoleNames = append(permissionsClusterRoleNames, permission.ClusterRoleName)
-				// update the condition
-				unableToCreateRoleBindingMsg := fmt.Sprintf("Unable to create RoleBinding: %s", err.Error())
-				permissionUpdatedCondition := controllerutil.UpdateCondition(instance, unableToCreateRoleBindingMsg, permissionsClusterRoleNames, true, managedv1alpha1.SubjectPermissionFailed)
-				err = r.client.Status().Update(context.TODO(), permissionUpdatedCondition)
-				if err != nil {
-					reqLogger.Error(err, "Failed to update condition.")
-					return reconcile.Result{}, err
-				}
-
-				// log Failed to create clusterRoleBinding error
-				reqLogger.Error(err, "Failed to create clusterRoleBinding")
-				return reconcile.Result{}, err
+	changed := false
+	set := func(conditionType managedv1alpha1.SubjectPermissionConditionType, status bool, reason, message string, clusterRoleNames []string) {
+		if controllerutil.SetCondition(subjectPermission, conditionType, status, reason, message, clusterRoleNames) {
+			changed = true
+		}
+	}
+	setIf := func(conditionType managedv1alpha1.SubjectPermissionConditionType, status bool, reason, message string, clusterRoleNames []string) {
+		if status {
+			set(conditionType, true, reason, message, clusterRoleNames)
+		} else {
+			set(conditionType, false, "", "", nil)
+		}
+	}
+
+	setIf(managedv1alpha1.SubjectPermissionConditionClusterRoleMissing, len(missingMessages) > 0, controllerutil.ReasonClusterRoleMissing, strings.Join(missingMessages, "; "), missingClusterRoleNames)
+	setIf(managedv1alpha1.SubjectPermissionConditionDegraded, len(degradedMessages) > 0, controllerutil.ReasonNotGranted, strings.Join(degradedMessages, "; "), nil)
+	applyMessage := ""
+	if applyErr != nil {
+		applyMessage = applyErr.Error()
+	}
+	setIf(managedv1alpha1.SubjectPermissionConditionBindingFailed, applyErr != nil, controllerutil.ReasonApplyFailed, applyMessage, nil)
+	if setResumed(subjectPermission) {
+		changed = true
+	}
+	// the SubjectNotFound condition is only reported once a Subject was found missing
+	if len(subjectMessages) > 0 {
+		set(managedv1alpha1.SubjectPermissionConditionSubjectNotFound, true, controllerutil.ReasonSubjectNotFound, strings.Join(subjectMessages, "; "), nil)
+	} else if controllerutil.FindCondition(subjectPermission, managedv1alpha1.SubjectPermissionConditionSubjectNotFound) != nil {
+		set(managedv1alpha1.SubjectPermissionConditionSubjectNotFound, false, controllerutil.ReasonSubjectsFound, "", nil)
+	}
+
+	var state managedv1alpha1.SubjectPermissionState
+	switch {
+	case applyErr != nil:
+		state = managedv1alpha1.SubjectPermissionFailed
+		set(managedv1alpha1.SubjectPermissionConditionReady, false, controllerutil.ReasonApplyFailed, "", nil)
+	case len(subjectMessages) > 0:
+		state = managedv1alpha1.SubjectPermissionSubjectNotFound
+		set(managedv1alpha1.SubjectPermissionConditionReady, false, controllerutil.ReasonSubjectNotFound, "", nil)
+	case len(degradedMessages) > 0:
+		state = managedv1alpha1.SubjectPermissionFailed
+		set(managedv1alpha1.SubjectPermissionConditionReady, false, controllerutil.ReasonNotGranted, "", nil)
+	case len(missingMessages) > 0:
+		state = managedv1alpha1.SubjectPermissionClusterRoleMissing
+		set(managedv1alpha1.SubjectPermissionConditionReady, false, controllerutil.ReasonClusterRoleMissing, "", nil)
+	case subjectPermission.Spec.Mode == managedv1alpha1.ModeAudit:
+		// the bindings are only ready when Audit mode has nothing left to change
+		state = managedv1alpha1.SubjectPermissionAudit
+		count := subjectPermission.Status.PlannedChangeCount
+		set(managedv1alpha1.SubjectPermissionConditionReady, count == 0, controllerutil.ReasonAuditMode, fmt.Sprintf("Audit mode, %d changes planned", count), nil)
+	default:
+		// outside of its Validity window nothing is granted, which is not a problem
+		state = validityState(subjectPermission, now)
+		message := fmt.Sprintf("%d bindings granted", len(plan.DesiredClusterRoleBindings)+len(plan.DesiredRoleBindings))
+		set(managedv1alpha1.SubjectPermissionConditionReady, true, readyReasons[state], message, nil)
+	}
+
+	if setSummary(subjectPermission, state) {
+		changed = true
+	}
+	return changed
+}
+
+// readyReasons are the reasons of the Ready condition of a SubjectPermission for each state it is ready in
+var readyReasons = map[managedv1alpha1.SubjectPermissionState]string{
+	managedv1alpha1.SubjectPermissionReady:   controllerutil.ReasonBindingsApplied,
+	managedv1alpha1.SubjectPermissionPending: controllerutil.ReasonNotYetValid,
+	managedv1alpha1.SubjectPermissionExpired: controllerutil.ReasonExpired,
+}
+
+// setSummary sets the state of a SubjectPermission and the generation it was computed for.
+// Returns true when the status changed
+func setSummary(subjectPermission *managedv1alpha1.SubjectPermission, state managedv1alpha1.SubjectPermissionState) bool {
+	status := &subjectPermission.Status
+	if status.State == string(state) && status.ObservedGeneration == subjectPermission.Generation {
+		return false
+	}
+	status.State, status.ObservedGeneration = string(state), subjectPermission.Generation
+	return true
+}
+
+// setSuspended reports a suspended SubjectPermission in its status, the other conditions are
+// kept as they were. Returns true when the status changed
+func setSuspended(subjectPermission *managedv1alpha1.SubjectPermission) bool {
+	changed := controllerutil.SetCondition(subjectPermission, managedv1alpha1.SubjectPermissionConditionSuspended, true, controllerutil.ReasonSuspended, "Bindings are not created, repaired or revoked while suspended", nil)
+	if setSummary(subjectPermission, managedv1alpha1.SubjectPermissionSuspended) {
+		changed = true
+	}
+	return changed
+}
+
+// setResumed clears the Suspended condition of a SubjectPermission that was suspended before,
+// it is only reported once Suspend was set. Returns true when the status changed
+func setResumed(subjectPermission *managedv1alpha1.SubjectPermission) bool {
+	if controllerutil.FindCondition(subjectPermission, managedv1alpha1.SubjectPermissionConditionSuspended) == nil {
+		return false
+	}
+	return controllerutil.SetCondition(subjectPermission, managedv1alpha1.SubjectPermissionConditionSuspended, false, controllerutil.ReasonResumed, "", nil)
+}
+
+// setPlannedChanges publishes the changes Audit mode would make in the status of a SubjectPermission,
+// they are cleared in Enforce mode. Returns true when the status changed
+func setPlannedChanges(subjectPermission *managedv1alpha1.SubjectPermission, changes []managedv1alpha1.PlannedChange, audit bool) bool {
+	if !audit {
+		changes = nil
+	}
+	sample := changes
+	if len(sample) > planner.InventorySampleSize {
+		sample = sample[:planner.InventorySampleSize]
+	}
+
+	status := &subjectPermission.Status
+	if status.PlannedChangeCount == len(changes) && reflect.DeepEqual(status.PlannedChanges, sample) {
+		return false
+	}
+	status.PlannedChangeCount, status.PlannedChanges = len(changes), sample
+	return true
+}
+
+// recordPlannedChanges emits a Normal Event for each action Audit mode would take, listing the objects
+// it would take it on
+func (r *ReconcileSubjectPermission) recordPlannedChanges(subjectPermission *managedv1alpha1.SubjectPermission, changes []managedv1alpha1.PlannedChange) {
+	for _, action := range []string{planner.ActionCreate, planner.ActionUpdate, planner.ActionDelete} {
+		var objects []string
+		for _, change := range changes {
+			if change.Action == action {
+				objects = append(objects, change.Kind+" "+changeName(change))
 			}
+		}
+		if len(objects) == 0 {
+			continue
+		}
+		message := strings.Join(objects, ", ")
+		if len(objects) > planner.InventorySampleSize {
+			message = fmt.Sprintf("%s and %d more", strings.Join(objects[:planner.InventorySampleSize], ", "), len(objects)-planner.InventorySampleSize)
+		}
+		r.recorder.Eventf(controllerutil.EventObject(subjectPermission), corev1.EventTypeNormal, "Would"+action, "Audit mode, would %s %s", strings.ToLower(action), message)
+	}
+}
+
+// recordResult emits a Normal Event for each binding and generated ClusterRole changed while applying a Plan,
+// the Events of RoleBindings are also recorded on their Namespace
+func (r *ReconcileSubjectPermission) recordResult(subjectPermission *managedv1alpha1.SubjectPermission, result *planner.Result) {
+	for _, name := range result.CreatedClusterRoles {
+		controllerutil.RecordEvent(r.recorder, subjectPermission, "", corev1.EventTypeNormal, controllerutil.EventReasonClusterRoleCreated, fmt.Sprintf("Created ClusterRole %s", name))
+	}
+	for _, name := range result.UpdatedClusterRoles {
+		controllerutil.RecordEvent(r.recorder, subjectPermission, "", corev1.EventTypeNormal, controllerutil.EventReasonClusterRoleUpdated, fmt.Sprintf("Updated ClusterRole %s", name))
+	}
+	for _, name := range result.DeletedClusterRoles {
+		controllerutil.RecordEvent(r.recorder, subjectPermission, "", corev1.EventTypeNormal, controllerutil.EventReasonClusterRoleDeleted, fmt.Sprintf("Deleted ClusterRole %s", name))
+	}
+	for _, ref := range result.Created {
+		controllerutil.RecordEvent(r.recorder, subjectPermission, ref.Namespace, corev1.EventTypeNormal, controllerutil.EventReasonBindingCreated, fmt.Sprintf("Created %s %s for ClusterRole %s", ref.Kind, ref, ref.ClusterRoleName))
+	}
+	for _, ref := range result.Updated {
+		controllerutil.RecordEvent(r.recorder, subjectPermission, ref.Namespace, corev1.EventTypeNormal, controllerutil.EventReasonBindingUpdated, fmt.Sprintf("Updated %s %s for ClusterRole %s", ref.Kind, ref, ref.ClusterRoleName))
+	}
+	for _, ref := range result.Revoked() {
+		controllerutil.RecordEvent(r.recorder, subjectPermission, ref.Namespace, corev1.EventTypeNormal, controllerutil.EventReasonBindingRevoked, fmt.Sprintf("Revoked %s %s for ClusterRole %s", ref.Kind, ref, ref.ClusterRoleName))
+	}
+}
 
-			successfullRoleBindingNames = append(successfullRoleBindingNames, permission.ClusterRoleName)
+// missingClusterRoleNames returns the ClusterRoles the status of a SubjectPermission reports as missing
+func missingClusterRoleNames(subjectPermission *managedv1alpha1.SubjectPermission) []string {
+	condition := controllerutil.FindCondition(subjectPermission, managedv1alpha1.SubjectPermissionConditionClusterRoleMissing)
+	if condition == nil || !condition.Status {
+		return nil
+	}
+	return condition.ClusterRoleNames
+}
 
-			// instead of updating the condition just log each successfully created ClusterRoleBinding
-			reqLogger.Info(fmt.Sprintf("Successfully created RoleBinding %s", roleBinding.Name))
-			return reconcile.Result{}, nil
+// warnClusterRolesMissing emits a Warning Event for each ClusterRole the status of a SubjectPermission reports
+// as missing that was not missing before, so that the Event is not repeated on every reconcile
+func (r *ReconcileSubjectPermission) warnClusterRolesMissing(subjectPermission *managedv1alpha1.SubjectPermission, missingBefore []string) {
+	for _, clusterRoleName := range missingClusterRoleNames(subjectPermission) {
+		if !controllerutil.ContainsString(missingBefore, clusterRoleName) {
+			controllerutil.RecordEvent(r.recorder, subjectPermission, "", corev1.EventTypeWarning, controllerutil.EventReasonClusterRoleMissing, fmt.Sprintf("ClusterRole %s does not exist", clusterRoleName))
 		}
+	}
+}
 
-		// update conditions with all successful rolebindings
-		instance = controllerutil.UpdateCondition(instance, "sucessfully created all rolebindings", successfullRoleBindingNames, true, managedv1alpha1.SubjectPermissionCreated)
+// missingSubjectsMessage returns the message of the SubjectNotFound condition of a SubjectPermission,
+// or "" when no Subject is reported missing
+func missingSubjectsMessage(subjectPermission *managedv1alpha1.SubjectPermission) string {
+	condition := controllerutil.FindCondition(subjectPermission, managedv1alpha1.SubjectPermissionConditionSubjectNotFound)
+	if condition == nil || !condition.Status {
+		return ""
 	}
+	return condition.Message
+}
 
-	return reconcile.Result{}, nil
+// warnSubjectsMissing emits a Warning Event when the status of a SubjectPermission starts reporting missing Subjects,
+// or reports other ones, so that the Event is not repeated on every reconcile
+func (r *ReconcileSubjectPermission) warnSubjectsMissing(subjectPermission *managedv1alpha1.SubjectPermission, messageBefore string) {
+	message := missingSubjectsMessage(subjectPermission)
+	if message == "" || message == messageBefore {
+		return
+	}
+	controllerutil.RecordEvent(r.recorder, subjectPermission, "", corev1.EventTypeWarning, controllerutil.EventReasonSubjectNotFound, "Bindings are held back: "+message)
 }
 
-// newClusterRoleBinding creates and returns ClusterRoleBinding
-func newClusterRoleBinding(clusterRoleName, subjectName string, subjectKind string) *v1.ClusterRoleBinding {
-	return &v1.ClusterRoleBinding{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: clusterRoleName + "-" + subjectName,
-		},
-		Subjects: []v1.Subject{
-			{
-				Kind: subjectKind,
-				Name: subjectName,
-			},
-		},
-		RoleRef: v1.RoleRef{
-			Kind: "ClusterRole",
-			Name: clusterRoleName,
-		},
+// degradedMessage returns the message of the Degraded condition of a SubjectPermission, or "" when it is not degraded
+func degradedMessage(subjectPermission *managedv1alpha1.SubjectPermission) string {
+	condition := controllerutil.FindCondition(subjectPermission, managedv1alpha1.SubjectPermissionConditionDegraded)
+	if condition == nil || !condition.Status {
+		return ""
 	}
+	return condition.Message
 }
 
-// populateCrClusterRoleNames to see if ClusterRoleName exists as a ClusterRole
-// returns list of ClusterRoleNames that do not exist
-func populateCrClusterRoleNames(subjectPermission *managedv1alpha1.SubjectPermission, clusterRoleList *v1.ClusterRoleList) []string {
-	// we get clusterRoleName by managedv1alpha1.ClusterPermission{}
-	crClusterRoleNames := subjectPermission.Spec.ClusterPermissions
+// warnClusterPermissionsIgnored emits a Warning Event when the plan of a SubjectPermission starts ignoring its
+// ClusterPermissions, which the Degraded condition did not report before, so that the Event is not repeated on every reconcile
+func (r *ReconcileSubjectPermission) warnClusterPermissionsIgnored(subjectPermission *managedv1alpha1.SubjectPermission, plan *planner.Plan, degradedBefore string) {
+	for _, planErr := range plan.Errors {
+		if _, ok := planErr.(*planner.ClusterPermissionsOutOfScopeError); ok && !strings.Contains(degradedBefore, planErr.Error()) {
+			controllerutil.RecordEvent(r.recorder, subjectPermission, "", corev1.EventTypeWarning, controllerutil.EventReasonClusterPermissionsIgnored, planErr.Error())
+		}
+	}
+}
 
-	// items is list of clusterRole on k8s
-	onClusterItems := clusterRoleList.Items
+// changeName returns namespace/name for a change of a RoleBinding and name otherwise
+func changeName(change managedv1alpha1.PlannedChange) string {
+	if change.Namespace == "" {
+		return change.Name
+	}
+	return change.Namespace + "/" + change.Name
+}
 
-	var crClusterRoleNameList []string
+// setInventory publishes the bindings of each entry of the ClusterPermissions and Permissions in the status of
+// a SubjectPermission. LastVerifiedTime is refreshed when an entry changed, otherwise at most every
+// verificationInterval so that unchanged bindings do not update the status on every reconcile.
+// Returns true when the status changed
+func setInventory(subjectPermission *managedv1alpha1.SubjectPermission, plan *planner.Plan, now time.Time) bool {
+	status := &subjectPermission.Status
 
-	// for every cluster role names on cluster, loop through all crClusterRoleNames, if it doesn't exist then append
-	for _, i := range onClusterItems {
-		//name := i.Name
-		for _, a := range crClusterRoleNames {
-			if i.Name != a {
-				crClusterRoleNameList = append(crClusterRoleNameList, a)
-			}
+	clusterRoleBindingCount, roleBindingCount := 0, 0
+	for _, inventory := range plan.ClusterPermissions {
+		if inventory.BindingName != "" {
+			clusterRoleBindingCount++
+		}
+	}
+	for _, inventory := range plan.Permissions {
+		roleBindingCount += inventory.MatchedNamespaceCount
+	}
+
+	// compare the entries without the time they were verified at
+	var verified *metav1.Time
+	clusterPermissions := make([]managedv1alpha1.ClusterPermissionStatus, len(status.ClusterPermissions))
+	for i, inventory := range status.ClusterPermissions {
+		verified = earliest(verified, inventory.LastVerifiedTime)
+		inventory.LastVerifiedTime = nil
+		clusterPermissions[i] = inventory
+	}
+	permissions := make([]managedv1alpha1.PermissionStatus, len(status.Permissions))
+	for i, inventory := range status.Permissions {
+		verified = earliest(verified, inventory.LastVerifiedTime)
+		inventory.LastVerifiedTime = nil
+		permissions[i] = inventory
+	}
+	unchanged := status.ClusterRoleBindingCount == clusterRoleBindingCount && status.RoleBindingCount == roleBindingCount &&
+		reflect.DeepEqual(clusterPermissions, append([]managedv1alpha1.ClusterPermissionStatus{}, plan.ClusterPermissions...)) &&
+		reflect.DeepEqual(permissions, append([]managedv1alpha1.PermissionStatus{}, plan.Permissions...))
+	if unchanged && (len(clusterPermissions)+len(permissions) == 0 || (verified != nil && now.Sub(verified.Time) < verificationInterval)) {
+		return false
+	}
+
+	// the status only keeps seconds
+	verifiedAt := metav1.NewTime(now).Rfc3339Copy()
+	status.ClusterPermissions, status.Permissions = nil, nil
+	for _, inventory := range plan.ClusterPermissions {
+		inventory.LastVerifiedTime = &verifiedAt
+		status.ClusterPermissions = append(status.ClusterPermissions, inventory)
+	}
+	for _, inventory := range plan.Permissions {
+		inventory.LastVerifiedTime = &verifiedAt
+		status.Permissions = append(status.Permissions, inventory)
+	}
+	status.ClusterRoleBindingCount, status.RoleBindingCount = clusterRoleBindingCount, roleBindingCount
+	return true
+}
+
+// earliest returns the earlier of two times, a nil time is ignored unless both are nil
+func earliest(a, b *metav1.Time) *metav1.Time {
+	if a == nil || (b != nil && b.Before(a)) {
+		return b
+	}
+	return a
+}
+
+// setExpiry publishes the next expiry and the time remaining until then in the status of a SubjectPermission.
+// Returns true when the status changed
+func setExpiry(subjectPermission *managedv1alpha1.SubjectPermission, now time.Time) bool {
+	var expiresAt *metav1.Time
+	remainingTime := ""
+	if expiry := utility.ValidityExpiry(subjectPermission, now); expiry != nil {
+		// the status only keeps seconds
+		rfc3339 := metav1.NewTime(*expiry).Rfc3339Copy()
+		expiresAt = &rfc3339
+		remaining := time.Duration(0)
+		if expiry.After(now) {
+			remaining = expiry.Sub(now).Round(time.Minute)
 		}
+		remainingTime = remaining.String()
 	}
 
-	return crClusterRoleNameList
+	status := &subjectPermission.Status
+	if status.ExpiresAt.Equal(expiresAt) && status.RemainingTime == remainingTime {
+		return false
+	}
+	status.ExpiresAt, status.RemainingTime = expiresAt, remainingTime
+	return true
 }
 
-// populateClusterRoleBindingNames to see if ClusterRoleBinding exists in k8s ClusterRoleBindlingList
-// returns a slice of clusterRoleBindingNames that exists in CR but not in clusterRoleBindingList
-func populateClusterRoleBindingNames(clusterRoleBindingNames []string, clusterRoleBindingList *v1.ClusterRoleBindingList) []string {
-	var crClusterRoleBindingList []string
-	var found bool
+// warnExpiringSoon emits a Warning Event for the SubjectPermission, and each of its granted Permissions,
+// expiring within expiryWarningWindow. The Events are recorded in the status so that they are emitted once
+// per window. Returns true when the status changed
+func (r *ReconcileSubjectPermission) warnExpiringSoon(subjectPermission *managedv1alpha1.SubjectPermission, now time.Time) bool {
+	created := subjectPermission.CreationTimestamp.Time
 
-	for _, crbName := range clusterRoleBindingNames {
-		for _, crBinding := range clusterRoleBindingList.Items {
-			if crbName == crBinding.Name {
-				found = true
-			}
+	var warnings []string
+	_, end := utility.ValidityWindow(subjectPermission.Spec.Validity, created)
+	if expiresSoon(end, now) {
+		warnings = append(warnings, fmt.Sprintf("All permissions expire at %s", end.UTC().Format(time.RFC3339)))
+	}
+	for i, permission := range subjectPermission.Spec.Permissions {
+		_, end := utility.ValidityWindow(permission.Validity, created)
+		if utility.IsPermissionActive(subjectPermission, i, now) && expiresSoon(end, now) {
+			warnings = append(warnings, fmt.Sprintf("Permission for ClusterRole %s expires at %s", controllerutil.PermissionClusterRoleName(subjectPermission, i), end.UTC().Format(time.RFC3339)))
 		}
-		if !found {
-			crClusterRoleBindingList = append(crClusterRoleBindingList, crbName)
+	}
+
+	status := &subjectPermission.Status
+	object := controllerutil.EventObject(subjectPermission)
+	for _, warning := range warnings {
+		if !controllerutil.ContainsString(status.ExpiryWarnings, warning) {
+			r.recorder.Event(object, corev1.EventTypeWarning, eventReasonExpiringSoon, warning)
 		}
-		found = false
 	}
-	return crClusterRoleBindingList
+	if reflect.DeepEqual(status.ExpiryWarnings, warnings) {
+		return false
+	}
+	status.ExpiryWarnings = warnings
+	return true
+}
+
+// expiresSoon checks if end is after now and within expiryWarningWindow
+func expiresSoon(end *time.Time, now time.Time) bool {
+	return end != nil && end.After(now) && !end.After(now.Add(expiryWarningWindow))
 }
 
-// buildClusterRoleBindingCRList which consists of clusterRoleName and subjectName
-func buildClusterRoleBindingCRList(clusterPermission *managedv1alpha1.SubjectPermission) []string {
-	var clusterRoleBindingNameList []string
+// requeueAfter returns the time until the next Validity boundary of a SubjectPermission, or until the
+// expiry warning before an end, at most verificationInterval
+func requeueAfter(subjectPermission *managedv1alpha1.SubjectPermission, now time.Time) time.Duration {
+	boundaries := []time.Time{now.Add(verificationInterval)}
+	for _, boundary := range utility.ValidityBoundaries(subjectPermission) {
+		boundaries = append(boundaries, boundary, boundary.Add(-expiryWarningWindow))
+	}
+	return utility.NextTime(boundaries, now).Sub(now)
+}
 
-	// get instance of SubjectPermission
-	for _, a := range clusterPermission.Spec.ClusterPermissions {
+// deleteGeneratedClusterRoles deletes the ClusterRoles generated for the Rules of the SubjectPermission
+func (r *ReconcileSubjectPermission) deleteGeneratedClusterRoles(subjectPermission *managedv1alpha1.SubjectPermission) error {
+	reqLogger := log.WithValues("Request.Namespace", subjectPermission.Namespace, "Request.Name", subjectPermission.Name)
 
-		clusterRoleBindingNameList = append(clusterRoleBindingNameList, a+"-"+clusterPermission.Spec.SubjectName)
+	clusterRoleList := &v1.ClusterRoleList{}
+	err := r.client.List(context.TODO(), client.MatchingLabels(controllerutil.OwnerSelector(subjectPermission)), clusterRoleList)
+	if err != nil {
+		return err
 	}
+	for i := range clusterRoleList.Items {
+		clusterRole := &clusterRoleList.Items[i]
+		if !controllerutil.IsOwnedBy(clusterRole, subjectPermission) {
+			continue
+		}
+		err = r.client.Delete(context.TODO(), clusterRole)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		reqLogger.Info(fmt.Sprintf("Successfully deleted ClusterRole %s", clusterRole.Name))
+		controllerutil.RecordEvent(r.recorder, subjectPermission, "", corev1.EventTypeNormal, controllerutil.EventReasonClusterRoleDeleted, fmt.Sprintf("Deleted ClusterRole %s", clusterRole.Name))
+	}
+
+	return nil
+}
+
+// revokeAll deletes every ClusterRoleBinding and RoleBinding, in all namespaces, that was generated
+// for the SubjectPermission, including the ones legacy versions generated without labels, then the
+// ClusterRoles generated for its Rules
+func (r *ReconcileSubjectPermission) revokeAll(subjectPermission *managedv1alpha1.SubjectPermission) error {
+	reqLogger := log.WithValues("Request.Namespace", subjectPermission.Namespace, "Request.Name", subjectPermission.Name)
+
+	// the legacy bindings carry no label, so every binding is listed
+	legacyClusterPermissions, legacyPermissions := controllerutil.LegacyClusterRoleNames(subjectPermission)
 
-	return clusterRoleBindingNameList
+	clusterRoleBindingList := &v1.ClusterRoleBindingList{}
+	err := r.client.List(context.TODO(), &client.ListOptions{}, clusterRoleBindingList)
+	if err != nil {
+		return err
+	}
+	for i := range clusterRoleBindingList.Items {
+		clusterRoleBinding := &clusterRoleBindingList.Items[i]
+		if !controllerutil.IsOwnedBy(clusterRoleBinding, subjectPermission) &&
+			!controllerutil.IsLegacyBinding(subjectPermission, clusterRoleBinding, clusterRoleBinding.Subjects, clusterRoleBinding.RoleRef, legacyClusterPermissions) {
+			continue
+		}
+		err = r.client.Delete(context.TODO(), clusterRoleBinding)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		reqLogger.Info(fmt.Sprintf("Successfully deleted ClusterRoleBinding %s", clusterRoleBinding.Name))
+		controllerutil.RecordEvent(r.recorder, subjectPermission, "", corev1.EventTypeNormal, controllerutil.EventReasonBindingRevoked, fmt.Sprintf("Revoked ClusterRoleBinding %s for ClusterRole %s", clusterRoleBinding.Name, clusterRoleBinding.RoleRef.Name))
+	}
+
+	// an empty namespace lists RoleBindings across all namespaces
+	roleBindingList := &v1.RoleBindingList{}
+	err = r.client.List(context.TODO(), &client.ListOptions{}, roleBindingList)
+	if err != nil {
+		return err
+	}
+	for i := range roleBindingList.Items {
+		roleBinding := &roleBindingList.Items[i]
+		if !controllerutil.IsOwnedBy(roleBinding, subjectPermission) &&
+			!controllerutil.IsLegacyBinding(subjectPermission, roleBinding, roleBinding.Subjects, roleBinding.RoleRef, legacyPermissions) {
+			continue
+		}
+		err = r.client.Delete(context.TODO(), roleBinding)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		reqLogger.Info(fmt.Sprintf("Successfully deleted RoleBinding %s in namespace %s", roleBinding.Name, roleBinding.Namespace))
+		controllerutil.RecordEvent(r.recorder, subjectPermission, roleBinding.Namespace, corev1.EventTypeNormal, controllerutil.EventReasonBindingRevoked, fmt.Sprintf("Revoked RoleBinding %s/%s for ClusterRole %s", roleBinding.Namespace, roleBinding.Name, roleBinding.RoleRef.Name))
+	}
+
+	return r.deleteGeneratedClusterRoles(subjectPermission)
+}
+
+// appendIfMissing appends s to slice unless it is already present
+func appendIfMissing(slice []string, s string) []string {
+	if controllerutil.ContainsString(slice, s) {
+		return slice
+	}
+	return append(slice, s)
 }
@@ -3,24 +3,35 @@ package subjectpermission
 import (
 	"context"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	userv1 "github.com/openshift/api/user/v1"
+	operatorconfig "github.com/openshift/rbac-permissions-operator/config"
 	"github.com/openshift/rbac-permissions-operator/pkg/apis"
 	"github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
 	controllerutil "github.com/openshift/rbac-permissions-operator/pkg/controller/utils"
+	"github.com/openshift/rbac-permissions-operator/pkg/planner"
+	"github.com/openshift/rbac-permissions-operator/version"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 // create fake client to mock API calls
 func newTestReconciler() *ReconcileSubjectPermission {
 	return &ReconcileSubjectPermission{
-		client: fake.NewFakeClient(),
-		scheme: scheme.Scheme,
+		client:   fake.NewFakeClient(),
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(100),
 	}
 }
 
@@ -29,7 +40,8 @@ func mockSubjectPermission() *v1alpha1.SubjectPermission {
 	return &v1alpha1.SubjectPermission{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "testSubjectPermission",
-			Namespace: "rbac-permissions-operator",
+			Namespace: "openshift-rbac-permissions-operator",
+			UID:       "exampleUID",
 		},
 		Spec: v1alpha1.SubjectPermissionSpec{
 			SubjectName:        "exampleSubjectName",
@@ -44,11 +56,11 @@ func mockSubjectPermission() *v1alpha1.SubjectPermission {
 		Status: v1alpha1.SubjectPermissionStatus{
 			Conditions: []v1alpha1.Condition{
 				{
+					Type:               v1alpha1.SubjectPermissionConditionReady,
 					LastTransitionTime: metav1.Now(),
 					ClusterRoleNames:   []string{"exampleClusterRoleName"},
 					Message:            "exampleMessage",
 					Status:             true,
-					State:              "exampleState",
 				},
 			},
 		},
@@ -73,17 +85,30 @@ func mockClusterRole() *rbacv1.ClusterRole {
 func mockClusterRoleBinding() *rbacv1.ClusterRoleBinding {
 	return &rbacv1.ClusterRoleBinding{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "exampleClusterRoleName-exampleSubjectName",
+			Name: controllerutil.BindingName(mockSubjectPermission(), "exampleClusterRoleName", rbacv1.Subject{Kind: "Group", Name: "exampleSubjectName"}),
+			Labels: map[string]string{
+				controllerutil.ManagedByLabel:       "rbac-permissions-operator",
+				controllerutil.OwnerUIDLabel:        "exampleUID",
+				controllerutil.PermissionScopeLabel: controllerutil.PermissionScopeCluster,
+				controllerutil.PermissionIndexLabel: "0",
+			},
+			Annotations: map[string]string{
+				controllerutil.OwnerNamespaceAnnotation:  "openshift-rbac-permissions-operator",
+				controllerutil.OwnerNameAnnotation:       "testSubjectPermission",
+				controllerutil.OperatorVersionAnnotation: version.Version,
+			},
 		},
 		Subjects: []rbacv1.Subject{
 			{
-				Kind: "Group",
-				Name: "exampleSubjectName",
+				APIGroup: "rbac.authorization.k8s.io",
+				Kind:     "Group",
+				Name:     "exampleSubjectName",
 			},
 		},
 		RoleRef: rbacv1.RoleRef{
-			Kind: "ClusterRole",
-			Name: "exampleClusterRoleName",
+			APIGroup: "rbac.authorization.k8s.io",
+			Kind:     "ClusterRole",
+			Name:     "exampleClusterRoleName",
 		},
 	}
 }
@@ -91,18 +116,31 @@ func mockClusterRoleBinding() *rbacv1.ClusterRoleBinding {
 func expectedRoleBinding() *rbacv1.RoleBinding {
 	return &rbacv1.RoleBinding{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "examplePermissionClusterRoleName-exampleGroupName",
+			Name:      controllerutil.BindingName(mockSubjectPermission(), "exampleClusterRoleName", rbacv1.Subject{Kind: "Group", Name: "exampleSubjectName"}),
 			Namespace: "examplenamespace",
+			Labels: map[string]string{
+				controllerutil.ManagedByLabel:       "rbac-permissions-operator",
+				controllerutil.OwnerUIDLabel:        "exampleUID",
+				controllerutil.PermissionScopeLabel: controllerutil.PermissionScopeNamespace,
+				controllerutil.PermissionIndexLabel: "0",
+			},
+			Annotations: map[string]string{
+				controllerutil.OwnerNamespaceAnnotation:  "openshift-rbac-permissions-operator",
+				controllerutil.OwnerNameAnnotation:       "testSubjectPermission",
+				controllerutil.OperatorVersionAnnotation: version.Version,
+			},
 		},
 		Subjects: []rbacv1.Subject{
 			{
-				Kind: "Group",
-				Name: "exampleGroupName",
+				APIGroup: "rbac.authorization.k8s.io",
+				Kind:     "Group",
+				Name:     "exampleSubjectName",
 			},
 		},
 		RoleRef: rbacv1.RoleRef{
-			Kind: "ClusterRole",
-			Name: "examplePermissionClusterRoleName",
+			APIGroup: "rbac.authorization.k8s.io",
+			Kind:     "ClusterRole",
+			Name:     "exampleClusterRoleName",
 		},
 	}
 }
@@ -132,7 +170,7 @@ func TestValidSafeList(t *testing.T) {
 	namespacesAllowedRegex := "openshift.*"
 	namespacesDeniedRegex := "default.*"
 
-	safeList := controllerutil.GenerateSafeList(namespacesAllowedRegex, namespacesDeniedRegex, namespaceList)
+	safeList := controllerutil.GenerateSafeList(namespacesAllowedRegex, namespacesDeniedRegex, false, namespaceList)
 
 	expectedSafeList := []string{"openshift.admin-stuff", "openshift.readers"}
 
@@ -193,7 +231,7 @@ func TestPopulateCrPermissionClusterRoleNames(t *testing.T) {
 
 }
 
-// TestClusterRoleNamesAvailableInCrButNotInCluster tests the populateCrClusterRoleNames function
+// TestClusterRoleNamesAvailableInCrButNotInCluster tests the missing ClusterRoles found by the planner
 // given: a SubjectPermissionSpec, an empty k8s ClusterRoleList
 // expected: []string with results from SubjectPermissionSpec that is NOT on ClusterRoleList
 func TestClusterRoleNamesAvailableInCrButNotInCluster(t *testing.T) {
@@ -226,7 +264,7 @@ func TestClusterRoleNamesAvailableInCrButNotInCluster(t *testing.T) {
 	// here is the function we are testing
 	// since our mockSubjectPermission() contains 2 ClusterRoleNames
 	// that are not on the k8s ClusterRoleList, we expect those to be populated
-	tmpList := populateCrClusterRoleNames(mockSubjectPermission(), list)
+	tmpList := planner.New(planner.Input{SubjectPermission: mockSubjectPermission(), ClusterRoles: list.Items}).MissingClusterRoles()
 
 	// this is the desired result
 	resultList := []string{"exampleClusterRoleName", "exampleClusterRoleNameTwo"}
@@ -244,18 +282,17 @@ func TestClusterRoleNamesAvailableInCrButNotInCluster(t *testing.T) {
 	}
 }
 
-// TestClusterRoleBindingsAvailableInCrButNotInCluster tests the populateClusterRoleBindingNames function
-// given: slice of ClusterRoleBindingNames, k8s ClusterRoleBindingList
+// TestClusterRoleBindingsAvailableInCrButNotInCluster tests the ClusterRoleBindings the planner creates
+// given: SubjectPermission Spec, k8s ClusterRoleBindingList
 // expected: slice of clusterRoleBindings that are available in our CR but NOT in k8s ClusterRoleBindingList
 func TestClusterRoleBindingsAvailableInCrButNotInCluster(t *testing.T) {
+	subjectPermission := mockSubjectPermission()
+	subjectPermission.Spec.SubjectKind = "Group"
+
 	// get and populate the k8s ClusterRoleBindingList
 	list := &rbacv1.ClusterRoleBindingList{
 		Items: []rbacv1.ClusterRoleBinding{
-			{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: "test-name-one",
-				},
-			},
+			*mockClusterRoleBinding(),
 			{
 				ObjectMeta: metav1.ObjectMeta{
 					Name: "test-name-two",
@@ -264,16 +301,13 @@ func TestClusterRoleBindingsAvailableInCrButNotInCluster(t *testing.T) {
 		},
 	}
 
-	// sample CR clusterRoleBindingNames
-	clusterRoleBindingNames := []string{"test-name-one", "test-name-three"}
-
-	// since ClusterRoleBindingName contains "test-name-one" and "test-name-three"
-	// compare with k8s ClusterRoleBindingList that contains "test-name-one" and "test-name-two"
-	// it should return only "test-name-three", which only exists in sample CR clusterRoleBindingNames and NOT on k8s cluster
-	tmpList := populateClusterRoleBindingNames(clusterRoleBindingNames, list)
+	// since the ClusterPermissions contain "exampleClusterRoleName" and "exampleClusterRoleNameTwo"
+	// compare with k8s ClusterRoleBindingList that already binds "exampleClusterRoleName"
+	// it should return only the binding for "exampleClusterRoleNameTwo"
+	tmpList := planner.New(planner.Input{SubjectPermission: subjectPermission, ClusterRoleBindings: list.Items}).CreateClusterRoleBindings
 
 	// desired result
-	resultList := []string{"test-name-three"}
+	resultList := []string{"exampleClusterRoleNameTwo"}
 
 	if len(tmpList) != len(resultList) {
 		t.Errorf("the length does not match")
@@ -282,13 +316,13 @@ func TestClusterRoleBindingsAvailableInCrButNotInCluster(t *testing.T) {
 	// checks resultList against tmpList, if they are not the same
 	// our test fails
 	for i, v := range resultList {
-		if v != tmpList[i] {
-			t.Errorf("got %s, want %s", tmpList, resultList)
+		if v != tmpList[i].RoleRef.Name {
+			t.Errorf("got %v, want %s", tmpList, resultList)
 		}
 	}
 }
 
-// TestCreateValidClusterRoleBinding tests the newClusterRoleBinding funtion
+// TestCreateValidClusterRoleBinding tests the NewClusterRoleBinding funtion
 // given: clusterRoleName, subjectName
 // expected: a ClusterRoleBinding that contains the new clusterRoleName and subjectName
 func TestCreateValidClusterRoleBinding(t *testing.T) {
@@ -309,9 +343,12 @@ func TestCreateValidClusterRoleBinding(t *testing.T) {
 		t.Errorf("Couldn't create required SubjectPermission object for test: %s", nerr)
 	}
 
+	subjectPermission := mockSubjectPermission()
+	subjectPermission.Spec.SubjectKind = "Group"
+
 	// this is the function we are testing
 	// it should return mockClusterRoleBinding() which contains the same clusterRoleName and SubjectName
-	newClusterRoleBinding := newClusterRoleBinding("exampleClusterRoleName", "exampleSubjectName", "Group")
+	newClusterRoleBinding := controllerutil.NewClusterRoleBinding(subjectPermission, 0)
 	t.Log(newClusterRoleBinding)
 	t.Log(mockClusterRoleBinding())
 
@@ -324,11 +361,13 @@ func TestCreateValidClusterRoleBinding(t *testing.T) {
 }
 
 // TestCreateValidRoleBinding tests the newRoleBinding function
-// given: clusterRoleName, groupName, namespace
-// expected: a RoleBinding that contains the clusterRoleName, groupName, and namespace given
+// given: SubjectPermission, permission index, namespace
+// expected: a RoleBinding that contains the clusterRoleName, subject, namespace and ownership labels
 func TestCreateValidRoleBinding(t *testing.T) {
+	subjectPermission := mockSubjectPermission()
+	subjectPermission.Spec.SubjectKind = "Group"
 
-	newRoleBinding := controllerutil.NewRoleBindingForClusterRole("examplePermissionClusterRoleName", "exampleGroupName", "Group", "examplenamespace")
+	newRoleBinding := controllerutil.NewRoleBindingForClusterRole(subjectPermission, 0, "examplenamespace")
 
 	diff := reflect.DeepEqual(*newRoleBinding, *expectedRoleBinding())
 	if !diff {
@@ -336,24 +375,374 @@ func TestCreateValidRoleBinding(t *testing.T) {
 	}
 }
 
-// TestValidClusterRoleBindingListCreation tests buildClusterRoleBindingCrList function
+// TestValidClusterRoleBindingListCreation tests the ClusterRoleBindings desired by the planner
 // given: SubjectPermission Spec
-// expected: slice of ClusterRoleBindingNames which consist of clusterrolename-groupname
+// expected: slice of ClusterRoleBindings, one for each ClusterPermission
 func TestValidClusterRoleBindingListCreation(t *testing.T) {
 
 	// this is the function we are testing by using a mock
-	buildList := buildClusterRoleBindingCRList(mockSubjectPermission())
+	buildList := planner.New(planner.Input{SubjectPermission: mockSubjectPermission()}).DesiredClusterRoleBindings
 
 	// this is the expected outcome
-	result := []string{"exampleClusterRoleName-exampleSubjectName", "exampleClusterRoleNameTwo-exampleSubjectName"}
+	result := []string{"exampleClusterRoleName", "exampleClusterRoleNameTwo"}
 
 	// check to see if given is equal to expected
 	if len(buildList) != len(result) {
 		t.Errorf("the length does not match")
 	}
 	for i, v := range result {
-		if v != buildList[i] {
-			t.Errorf("got %s, want %s", buildList, result)
+		if v != buildList[i].RoleRef.Name {
+			t.Errorf("got %v, want %s", buildList, result)
+		}
+	}
+}
+
+// deletedSubjectPermission returns a SubjectPermission that is being deleted and still holds the finalizer
+func deletedSubjectPermission(deletionPolicy v1alpha1.DeletionPolicy) *v1alpha1.SubjectPermission {
+	subjectPermission := mockSubjectPermission()
+	subjectPermission.Spec.SubjectKind = "Group"
+	subjectPermission.Spec.DeletionPolicy = deletionPolicy
+	now := metav1.Now()
+	subjectPermission.DeletionTimestamp = &now
+	subjectPermission.Finalizers = []string{controllerutil.SubjectPermissionFinalizer}
+	return subjectPermission
+}
+
+// TestDeletionRevokesBindings tests that the finalizer revokes bindings based on the DeletionPolicy
+// given: a SubjectPermission being deleted, its generated bindings, the legacy ones generated without labels
+// and an unrelated RoleBinding
+// expected: generated and legacy bindings are deleted unless the policy is Retain, the unrelated one is kept
+func TestDeletionRevokesBindings(t *testing.T) {
+	if err := apis.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("Unable to add apis scheme: (%v)", err)
+	}
+
+	var tests = []struct {
+		deletionPolicy v1alpha1.DeletionPolicy
+		retained       bool
+	}{
+		{"", false},
+		{v1alpha1.DeletionPolicyDelete, false},
+		{v1alpha1.DeletionPolicyRetain, true},
+	}
+
+	for _, test := range tests {
+		ctx := context.TODO()
+		subjectPermission := deletedSubjectPermission(test.deletionPolicy)
+		// a hand-made RoleBinding with the same name but without ownership labels
+		unrelatedRoleBinding := controllerutil.NewRoleBindingForClusterRole(subjectPermission, 0, "othernamespace")
+		unrelatedRoleBinding.Labels = nil
+		// the bindings generated by legacy versions, named clusterRoleName-subjectName
+		legacyRoleBinding := controllerutil.NewRoleBindingForClusterRole(subjectPermission, 0, "legacynamespace")
+		legacyRoleBinding.Name = legacyRoleBinding.RoleRef.Name + "-" + subjectPermission.Spec.SubjectName
+		legacyRoleBinding.Labels, legacyRoleBinding.Annotations = nil, nil
+		legacyClusterRoleBinding := controllerutil.NewClusterRoleBinding(subjectPermission, 0)
+		legacyClusterRoleBinding.Name = legacyClusterRoleBinding.RoleRef.Name + "-" + subjectPermission.Spec.SubjectName
+		legacyClusterRoleBinding.Labels, legacyClusterRoleBinding.Annotations = nil, nil
+		reconciler := &ReconcileSubjectPermission{
+			client: fake.NewFakeClient(
+				subjectPermission,
+				mockClusterRoleBinding(),
+				controllerutil.NewRoleBindingForClusterRole(subjectPermission, 0, "examplenamespace"),
+				unrelatedRoleBinding,
+				legacyRoleBinding,
+				legacyClusterRoleBinding,
+			),
+			scheme:   scheme.Scheme,
+			recorder: record.NewFakeRecorder(100),
+		}
+
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: subjectPermission.Name, Namespace: subjectPermission.Namespace}})
+		if err != nil {
+			t.Fatalf("Reconcile with DeletionPolicy '%s' failed: %s", test.deletionPolicy, err)
+		}
+
+		crbList := &rbacv1.ClusterRoleBindingList{}
+		rbList := &rbacv1.RoleBindingList{}
+		if err := reconciler.client.List(ctx, &client.ListOptions{}, crbList); err != nil {
+			t.Fatalf("Couldn't list ClusterRoleBindings: %s", err)
+		}
+		if err := reconciler.client.List(ctx, &client.ListOptions{}, rbList); err != nil {
+			t.Fatalf("Couldn't list RoleBindings: %s", err)
+		}
+
+		expectedClusterRoleBindings, expectedRoleBindings := 0, 1
+		if test.retained {
+			expectedClusterRoleBindings, expectedRoleBindings = 2, 3
+		}
+		if len(crbList.Items) != expectedClusterRoleBindings {
+			t.Errorf("DeletionPolicy '%s': got %d ClusterRoleBindings, want %d", test.deletionPolicy, len(crbList.Items), expectedClusterRoleBindings)
+		}
+		if len(rbList.Items) != expectedRoleBindings {
+			t.Errorf("DeletionPolicy '%s': got %d RoleBindings, want %d", test.deletionPolicy, len(rbList.Items), expectedRoleBindings)
+		}
+
+		updated := &v1alpha1.SubjectPermission{}
+		if err := reconciler.client.Get(ctx, types.NamespacedName{Name: subjectPermission.Name, Namespace: subjectPermission.Namespace}, updated); err != nil {
+			t.Fatalf("Couldn't get SubjectPermission: %s", err)
+		}
+		if controllerutil.ContainsString(updated.Finalizers, controllerutil.SubjectPermissionFinalizer) {
+			t.Errorf("DeletionPolicy '%s': finalizer was not removed", test.deletionPolicy)
+		}
+	}
+}
+
+// TestDeletionRevokesBindingsInOtherNamespaces tests that the finalizer revokes RoleBindings outside the
+// namespace of the SubjectPermission
+// given: a SubjectPermission in the operator namespace being deleted, with RoleBindings in two tenant namespaces
+// expected: the RoleBindings of every namespace are deleted before the finalizer is removed
+func TestDeletionRevokesBindingsInOtherNamespaces(t *testing.T) {
+	ctx := context.TODO()
+	if err := apis.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("Unable to add apis scheme: (%v)", err)
+	}
+
+	subjectPermission := deletedSubjectPermission("")
+	reconciler := &ReconcileSubjectPermission{
+		client: fake.NewFakeClient(
+			subjectPermission,
+			controllerutil.NewRoleBindingForClusterRole(subjectPermission, 0, "tenant-a"),
+			controllerutil.NewRoleBindingForClusterRole(subjectPermission, 0, "tenant-b"),
+		),
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(100),
+	}
+
+	key := types.NamespacedName{Name: subjectPermission.Name, Namespace: subjectPermission.Namespace}
+	if _, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile failed: %s", err)
+	}
+
+	for _, namespace := range []string{"tenant-a", "tenant-b"} {
+		rbList := &rbacv1.RoleBindingList{}
+		if err := reconciler.client.List(ctx, client.InNamespace(namespace), rbList); err != nil {
+			t.Fatalf("Couldn't list RoleBindings: %s", err)
+		}
+		if len(rbList.Items) != 0 {
+			t.Errorf("got %d RoleBindings in namespace %s, want 0", len(rbList.Items), namespace)
+		}
+	}
+
+	updated := &v1alpha1.SubjectPermission{}
+	if err := reconciler.client.Get(ctx, key, updated); err != nil {
+		t.Fatalf("Couldn't get SubjectPermission: %s", err)
+	}
+	if controllerutil.ContainsString(updated.Finalizers, controllerutil.SubjectPermissionFinalizer) {
+		t.Errorf("finalizer was not removed")
+	}
+}
+
+// TestStaleBindingsAreRevoked tests that bindings which are no longer desired get deleted
+// given: a SubjectPermission whose spec dropped a ClusterPermission and no longer allows a namespace
+// expected: the stale bindings are deleted, the desired ones are kept and the Ready condition counts them
+func TestStaleBindingsAreRevoked(t *testing.T) {
+	ctx := context.TODO()
+	if err := apis.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("Unable to add apis scheme: (%v)", err)
+	}
+
+	subjectPermission := mockSubjectPermission()
+	subjectPermission.Spec.SubjectKind = "Group"
+	removedPermission := mockSubjectPermission()
+	removedPermission.Spec.SubjectKind = "Group"
+	removedPermission.Spec.ClusterPermissions = []string{"removedClusterRoleName"}
+	subjectPermission.Spec.ClusterPermissions = []string{"exampleClusterRoleName"}
+	subjectPermission.Spec.Permissions[0].NamespacesAllowedRegex = "^keep$"
+	subjectPermission.Spec.Permissions[0].NamespacesDeniedRegex = "^openshift-.*"
+	subjectPermission.Finalizers = []string{controllerutil.SubjectPermissionFinalizer}
+
+	reconciler := &ReconcileSubjectPermission{
+		client: fake.NewFakeClient(
+			subjectPermission,
+			&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "exampleClusterRoleName"}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "keep"}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "drop"}},
+			mockClusterRoleBinding(),
+			controllerutil.NewClusterRoleBinding(removedPermission, 0),
+			controllerutil.NewRoleBindingForClusterRole(subjectPermission, 0, "keep"),
+			controllerutil.NewRoleBindingForClusterRole(subjectPermission, 0, "drop"),
+		),
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(100),
+	}
+
+	key := types.NamespacedName{Name: subjectPermission.Name, Namespace: subjectPermission.Namespace}
+	_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key})
+	if err != nil {
+		t.Fatalf("Reconcile failed: %s", err)
+	}
+
+	crbList := &rbacv1.ClusterRoleBindingList{}
+	if err := reconciler.client.List(ctx, &client.ListOptions{}, crbList); err != nil {
+		t.Fatalf("Couldn't list ClusterRoleBindings: %s", err)
+	}
+	if len(crbList.Items) != 1 || crbList.Items[0].RoleRef.Name != "exampleClusterRoleName" {
+		t.Errorf("got ClusterRoleBindings %v, want only the one for exampleClusterRoleName", crbList.Items)
+	}
+
+	rbList := &rbacv1.RoleBindingList{}
+	if err := reconciler.client.List(ctx, &client.ListOptions{}, rbList); err != nil {
+		t.Fatalf("Couldn't list RoleBindings: %s", err)
+	}
+	if len(rbList.Items) != 1 || rbList.Items[0].Namespace != "keep" {
+		t.Errorf("got RoleBindings %v, want only the one in namespace keep", rbList.Items)
+	}
+
+	updated := &v1alpha1.SubjectPermission{}
+	if err := reconciler.client.Get(ctx, key, updated); err != nil {
+		t.Fatalf("Couldn't get SubjectPermission: %s", err)
+	}
+	readyCondition := controllerutil.FindCondition(updated, v1alpha1.SubjectPermissionConditionReady)
+	if readyCondition == nil || !readyCondition.Status || readyCondition.Message != "2 bindings granted" {
+		t.Errorf("got Ready condition %v, want it true with 2 bindings granted", readyCondition)
+	}
+
+	// the inventory lists the remaining bindings
+	if updated.Status.ClusterRoleBindingCount != 1 || updated.Status.RoleBindingCount != 1 {
+		t.Errorf("got %d ClusterRoleBindings and %d RoleBindings in status, want 1 and 1", updated.Status.ClusterRoleBindingCount, updated.Status.RoleBindingCount)
+	}
+	if len(updated.Status.Permissions) != 1 {
+		t.Fatalf("got %d Permissions in status, want 1", len(updated.Status.Permissions))
+	}
+	inventory := updated.Status.Permissions[0]
+	if !reflect.DeepEqual(inventory.MatchedNamespaces, []string{"keep"}) || inventory.SkippedNamespaceCount != 1 || inventory.LastVerifiedTime == nil {
+		t.Errorf("got Permission inventory %v, want it verified, matching keep and skipping drop", inventory)
+	}
+}
+
+// TestDriftedBindingsAreRepaired tests that generated bindings which were edited get restored
+// given: a generated ClusterRoleBinding with changed Subjects and a generated RoleBinding with a changed RoleRef
+// expected: both bindings are back to their desired Subjects and RoleRef
+func TestDriftedBindingsAreRepaired(t *testing.T) {
+	ctx := context.TODO()
+	if err := apis.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("Unable to add apis scheme: (%v)", err)
+	}
+
+	subjectPermission := mockSubjectPermission()
+	subjectPermission.Spec.SubjectKind = "Group"
+	subjectPermission.Spec.ClusterPermissions = []string{"exampleClusterRoleName"}
+	subjectPermission.Spec.Permissions[0].NamespacesAllowedRegex = "^examplenamespace$"
+	subjectPermission.Spec.Permissions[0].NamespacesDeniedRegex = "^openshift-.*"
+	subjectPermission.Finalizers = []string{controllerutil.SubjectPermissionFinalizer}
+
+	driftedClusterRoleBinding := mockClusterRoleBinding()
+	driftedClusterRoleBinding.Subjects = []rbacv1.Subject{{Kind: "User", Name: "intruder"}}
+	driftedRoleBinding := expectedRoleBinding()
+	driftedRoleBinding.RoleRef.Name = "admin"
+
+	reconciler := &ReconcileSubjectPermission{
+		client: fake.NewFakeClient(
+			subjectPermission,
+			&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "exampleClusterRoleName"}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "examplenamespace"}},
+			driftedClusterRoleBinding,
+			driftedRoleBinding,
+		),
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(100),
+	}
+
+	_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: subjectPermission.Name, Namespace: subjectPermission.Namespace}})
+	if err != nil {
+		t.Fatalf("Reconcile failed: %s", err)
+	}
+
+	clusterRoleBinding := &rbacv1.ClusterRoleBinding{}
+	if err := reconciler.client.Get(ctx, types.NamespacedName{Name: driftedClusterRoleBinding.Name}, clusterRoleBinding); err != nil {
+		t.Fatalf("Couldn't get ClusterRoleBinding: %s", err)
+	}
+	if !reflect.DeepEqual(clusterRoleBinding.Subjects, mockClusterRoleBinding().Subjects) {
+		t.Errorf("got Subjects %v, want %v", clusterRoleBinding.Subjects, mockClusterRoleBinding().Subjects)
+	}
+
+	roleBinding := &rbacv1.RoleBinding{}
+	if err := reconciler.client.Get(ctx, types.NamespacedName{Name: driftedRoleBinding.Name, Namespace: driftedRoleBinding.Namespace}, roleBinding); err != nil {
+		t.Fatalf("Couldn't get RoleBinding: %s", err)
+	}
+	if !reflect.DeepEqual(roleBinding.RoleRef, expectedRoleBinding().RoleRef) {
+		t.Errorf("got RoleRef %v, want %v", roleBinding.RoleRef, expectedRoleBinding().RoleRef)
+	}
+}
+
+// TestClusterRoleNamesIndexFunc tests the index of SubjectPermissions by the ClusterRoles they reference
+// given: a SubjectPermission referencing a ClusterRole from both ClusterPermissions and Permissions
+// expected: every referenced ClusterRole name, once
+func TestClusterRoleNamesIndexFunc(t *testing.T) {
+	indexed := clusterRoleNamesIndexFunc(mockSubjectPermission())
+	expected := []string{"exampleClusterRoleName", "exampleClusterRoleNameTwo"}
+
+	if !reflect.DeepEqual(indexed, expected) {
+		t.Errorf("got %s, want %s", indexed, expected)
+	}
+	if clusterRoleNamesIndexFunc(mockClusterRole()) != nil {
+		t.Errorf("expected no index values for an object that is not a SubjectPermission")
+	}
+}
+
+// TestMissingClusterRoleBecomesReady tests the state transition once a missing ClusterRole gets created
+// given: a SubjectPermission referencing a ClusterRole that does not exist
+// expected: state is ClusterRoleMissing, then Ready after the ClusterRole is created. The four
+// conditions are updated in place and the status records the generation it was computed for
+func TestMissingClusterRoleBecomesReady(t *testing.T) {
+	ctx := context.TODO()
+	if err := apis.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("Unable to add apis scheme: (%v)", err)
+	}
+
+	subjectPermission := mockSubjectPermission()
+	subjectPermission.Spec.SubjectKind = "Group"
+	subjectPermission.Spec.ClusterPermissions = nil
+	subjectPermission.Spec.Permissions[0].NamespacesAllowedRegex = ".*"
+	subjectPermission.Finalizers = []string{controllerutil.SubjectPermissionFinalizer}
+	subjectPermission.Generation = 2
+	key := types.NamespacedName{Name: subjectPermission.Name, Namespace: subjectPermission.Namespace}
+
+	// without restricted rules, which hold back the bindings of a ClusterRole whose rules cannot be checked yet
+	unrestricted := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: operatorconfig.OperatorConfigMapName, Namespace: operatorconfig.OperatorNamespace}}
+	reconciler := &ReconcileSubjectPermission{
+		client:   fake.NewFakeClient(subjectPermission, unrestricted),
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(100),
+	}
+
+	var tests = []struct {
+		label string
+		setup func()
+		state v1alpha1.SubjectPermissionState
+	}{
+		{"before the ClusterRole exists", func() {}, v1alpha1.SubjectPermissionClusterRoleMissing},
+		{"after the ClusterRole is created", func() {
+			clusterRole := mockClusterRole()
+			clusterRole.Name = "exampleClusterRoleName"
+			if err := reconciler.client.Create(ctx, clusterRole); err != nil {
+				t.Fatalf("Couldn't create clusterRole for test: %s", err)
+			}
+		}, v1alpha1.SubjectPermissionReady},
+	}
+
+	for _, test := range tests {
+		test.setup()
+		if _, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("Reconcile %s failed: %s", test.label, err)
+		}
+		updated := &v1alpha1.SubjectPermission{}
+		if err := reconciler.client.Get(ctx, key, updated); err != nil {
+			t.Fatalf("Couldn't get SubjectPermission: %s", err)
+		}
+		if updated.Status.State != string(test.state) {
+			t.Errorf("%s: got state %s, want %s", test.label, updated.Status.State, test.state)
+		}
+		if len(updated.Status.Conditions) != 4 {
+			t.Errorf("%s: got %d conditions, want 4", test.label, len(updated.Status.Conditions))
+		}
+		missing := controllerutil.FindCondition(updated, v1alpha1.SubjectPermissionConditionClusterRoleMissing)
+		ready := controllerutil.FindCondition(updated, v1alpha1.SubjectPermissionConditionReady)
+		if missing == nil || ready == nil || missing.Status == ready.Status {
+			t.Errorf("%s: got ClusterRoleMissing %v and Ready %v, want exactly one of them true", test.label, missing, ready)
+		}
+		if updated.Status.ObservedGeneration != 2 {
+			t.Errorf("%s: got observedGeneration %d, want 2", test.label, updated.Status.ObservedGeneration)
 		}
 	}
 }
@@ -413,3 +802,714 @@ func TestValidClusterRoleBindingListCreation(t *testing.T) {
 // 	}
 // 	return true
 // }
+
+// TestGeneratedClusterRoleLifecycle tests the ClusterRole generated for the Rules of a Permission
+// given: a SubjectPermission with inline Rules, whose Rules then change, and which is then deleted
+// expected: the ClusterRole is created and bound, updated with the Rules, and deleted with the SubjectPermission
+func TestGeneratedClusterRoleLifecycle(t *testing.T) {
+	ctx := context.TODO()
+	if err := apis.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("Unable to add apis scheme: (%v)", err)
+	}
+
+	subjectPermission := mockSubjectPermission()
+	subjectPermission.Spec.SubjectKind = "Group"
+	subjectPermission.Spec.ClusterPermissions = nil
+	subjectPermission.Spec.Permissions = []v1alpha1.Permission{
+		{
+			NamespacesAllowedRegex: "^examplenamespace$",
+			Rules:                  []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}}},
+		},
+	}
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: subjectPermission.Name, Namespace: subjectPermission.Namespace}}
+	clusterRoleName := controllerutil.GeneratedClusterRoleName(subjectPermission, 0)
+	reconciler := &ReconcileSubjectPermission{
+		client: fake.NewFakeClient(
+			subjectPermission,
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "examplenamespace"}},
+		),
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(100),
+	}
+
+	// the ClusterRole is created and bound
+	if _, err := reconciler.Reconcile(request); err != nil {
+		t.Fatalf("Reconcile failed: %s", err)
+	}
+	clusterRole := &rbacv1.ClusterRole{}
+	if err := reconciler.client.Get(ctx, types.NamespacedName{Name: clusterRoleName}, clusterRole); err != nil {
+		t.Fatalf("Couldn't get generated ClusterRole: %s", err)
+	}
+	if !reflect.DeepEqual(clusterRole.Rules, subjectPermission.Spec.Permissions[0].Rules) {
+		t.Errorf("got Rules %v, want %v", clusterRole.Rules, subjectPermission.Spec.Permissions[0].Rules)
+	}
+	rbList := &rbacv1.RoleBindingList{}
+	if err := reconciler.client.List(ctx, &client.ListOptions{Namespace: "examplenamespace"}, rbList); err != nil {
+		t.Fatalf("Couldn't list RoleBindings: %s", err)
+	}
+	if len(rbList.Items) != 1 || rbList.Items[0].RoleRef.Name != clusterRoleName {
+		t.Errorf("got RoleBindings %v, want one bound to %s", rbList.Items, clusterRoleName)
+	}
+
+	// the ClusterRole follows the Rules
+	updated := &v1alpha1.SubjectPermission{}
+	if err := reconciler.client.Get(ctx, request.NamespacedName, updated); err != nil {
+		t.Fatalf("Couldn't get SubjectPermission: %s", err)
+	}
+	updated.Spec.Permissions[0].Rules[0].Verbs = []string{"get", "list"}
+	if err := reconciler.client.Update(ctx, updated); err != nil {
+		t.Fatalf("Couldn't update SubjectPermission: %s", err)
+	}
+	if _, err := reconciler.Reconcile(request); err != nil {
+		t.Fatalf("Reconcile failed: %s", err)
+	}
+	if err := reconciler.client.Get(ctx, types.NamespacedName{Name: clusterRoleName}, clusterRole); err != nil {
+		t.Fatalf("Couldn't get generated ClusterRole: %s", err)
+	}
+	if !reflect.DeepEqual(clusterRole.Rules[0].Verbs, []string{"get", "list"}) {
+		t.Errorf("got verbs %v, want [get list]", clusterRole.Rules[0].Verbs)
+	}
+
+	// the ClusterRole goes away with the SubjectPermission
+	if err := reconciler.client.Get(ctx, request.NamespacedName, updated); err != nil {
+		t.Fatalf("Couldn't get SubjectPermission: %s", err)
+	}
+	now := metav1.Now()
+	updated.DeletionTimestamp = &now
+	if err := reconciler.client.Update(ctx, updated); err != nil {
+		t.Fatalf("Couldn't update SubjectPermission: %s", err)
+	}
+	if _, err := reconciler.Reconcile(request); err != nil {
+		t.Fatalf("Reconcile failed: %s", err)
+	}
+	crList := &rbacv1.ClusterRoleList{}
+	if err := reconciler.client.List(ctx, &client.ListOptions{}, crList); err != nil {
+		t.Fatalf("Couldn't list ClusterRoles: %s", err)
+	}
+	if len(crList.Items) != 0 {
+		t.Errorf("got %d ClusterRoles after deletion, want 0", len(crList.Items))
+	}
+}
+
+// TestClusterSubjectPermission tests that a ClusterSubjectPermission is reconciled like a SubjectPermission
+// given: a ClusterSubjectPermission with ClusterPermissions and a Permission, and their ClusterRoles
+// expected: its bindings are created and its own status is updated
+func TestClusterSubjectPermission(t *testing.T) {
+	ctx := context.TODO()
+	if err := apis.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("Unable to add apis scheme: (%v)", err)
+	}
+
+	subjectPermission := mockSubjectPermission()
+	subjectPermission.Spec.SubjectKind = "Group"
+	subjectPermission.Spec.Permissions[0].NamespacesAllowedRegex = "^examplenamespace$"
+	clusterSubjectPermission := &v1alpha1.ClusterSubjectPermission{
+		ObjectMeta: metav1.ObjectMeta{Name: "testClusterSubjectPermission", UID: "exampleClusterUID"},
+		Spec:       subjectPermission.Spec,
+	}
+	reconciler := &ReconcileSubjectPermission{
+		client: fake.NewFakeClient(
+			clusterSubjectPermission,
+			&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "exampleClusterRoleName"}},
+			&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "exampleClusterRoleNameTwo"}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "examplenamespace"}},
+		),
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(100),
+	}
+
+	_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: clusterSubjectPermission.Name}})
+	if err != nil {
+		t.Fatalf("Reconcile failed: %s", err)
+	}
+
+	crbList := &rbacv1.ClusterRoleBindingList{}
+	if err := reconciler.client.List(ctx, &client.ListOptions{}, crbList); err != nil {
+		t.Fatalf("Couldn't list ClusterRoleBindings: %s", err)
+	}
+	if len(crbList.Items) != 2 {
+		t.Errorf("got %d ClusterRoleBindings, want 2", len(crbList.Items))
+	}
+	rbList := &rbacv1.RoleBindingList{}
+	if err := reconciler.client.List(ctx, &client.ListOptions{Namespace: "examplenamespace"}, rbList); err != nil {
+		t.Fatalf("Couldn't list RoleBindings: %s", err)
+	}
+	if len(rbList.Items) != 1 {
+		t.Errorf("got %d RoleBindings, want 1", len(rbList.Items))
+	}
+
+	updated := &v1alpha1.ClusterSubjectPermission{}
+	if err := reconciler.client.Get(ctx, types.NamespacedName{Name: clusterSubjectPermission.Name}, updated); err != nil {
+		t.Fatalf("Couldn't get ClusterSubjectPermission: %s", err)
+	}
+	if updated.Status.State != string(v1alpha1.SubjectPermissionReady) {
+		t.Errorf("got state %q, want %q", updated.Status.State, v1alpha1.SubjectPermissionReady)
+	}
+	if !controllerutil.ContainsString(updated.Finalizers, controllerutil.SubjectPermissionFinalizer) {
+		t.Errorf("finalizer was not added to the ClusterSubjectPermission")
+	}
+}
+
+// TestValidityExpiry tests that bindings only exist inside the validity window of a SubjectPermission
+// given: an expired SubjectPermission with bindings, and one expiring within the warning window
+// expected: the expired one revokes its bindings and is Expired, the other one keeps them,
+// warns about the expiry and is requeued before it expires
+func TestValidityExpiry(t *testing.T) {
+	ctx := context.TODO()
+	if err := apis.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("Unable to add apis scheme: (%v)", err)
+	}
+
+	var tests = []struct {
+		label         string
+		notAfter      time.Time
+		bindings      int
+		state         v1alpha1.SubjectPermissionState
+		expectWarning bool
+	}{
+		{"expired", time.Now().Add(-time.Minute), 0, v1alpha1.SubjectPermissionExpired, false},
+		{"expiring soon", time.Now().Add(10 * time.Minute), 1, v1alpha1.SubjectPermissionReady, true},
+	}
+
+	for _, test := range tests {
+		subjectPermission := mockSubjectPermission()
+		subjectPermission.Spec.SubjectKind = "Group"
+		subjectPermission.Spec.Permissions = nil
+		subjectPermission.Spec.ClusterPermissions = []string{"exampleClusterRoleName"}
+		subjectPermission.Spec.NotAfter = &metav1.Time{Time: test.notAfter}
+		subjectPermission.Finalizers = []string{controllerutil.SubjectPermissionFinalizer}
+
+		recorder := record.NewFakeRecorder(100)
+		reconciler := &ReconcileSubjectPermission{
+			client: fake.NewFakeClient(
+				subjectPermission,
+				&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "exampleClusterRoleName"}},
+				controllerutil.NewClusterRoleBinding(subjectPermission, 0),
+			),
+			scheme:   scheme.Scheme,
+			recorder: recorder,
+		}
+
+		key := types.NamespacedName{Name: subjectPermission.Name, Namespace: subjectPermission.Namespace}
+		result, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key})
+		if err != nil {
+			t.Fatalf("%s: Reconcile failed: %s", test.label, err)
+		}
+
+		crbList := &rbacv1.ClusterRoleBindingList{}
+		if err := reconciler.client.List(ctx, &client.ListOptions{}, crbList); err != nil {
+			t.Fatalf("%s: Couldn't list ClusterRoleBindings: %s", test.label, err)
+		}
+		if len(crbList.Items) != test.bindings {
+			t.Errorf("%s: got %d ClusterRoleBindings, want %d", test.label, len(crbList.Items), test.bindings)
+		}
+
+		updated := &v1alpha1.SubjectPermission{}
+		if err := reconciler.client.Get(ctx, key, updated); err != nil {
+			t.Fatalf("%s: Couldn't get SubjectPermission: %s", test.label, err)
+		}
+		if updated.Status.State != string(test.state) {
+			t.Errorf("%s: got state %q, want %q", test.label, updated.Status.State, test.state)
+		}
+		if updated.Status.ExpiresAt == nil || !updated.Status.ExpiresAt.Equal(&metav1.Time{Time: test.notAfter.Truncate(time.Second)}) {
+			t.Errorf("%s: got expiresAt %v, want %v", test.label, updated.Status.ExpiresAt, test.notAfter)
+		}
+
+		if test.expectWarning {
+			if result.RequeueAfter <= 0 || result.RequeueAfter > 10*time.Minute {
+				t.Errorf("%s: got requeue after %s, want at most 10m", test.label, result.RequeueAfter)
+			}
+			select {
+			case event := <-recorder.Events:
+				if !strings.Contains(event, eventReasonExpiringSoon) {
+					t.Errorf("%s: got event %q, want reason %s", test.label, event, eventReasonExpiringSoon)
+				}
+			default:
+				t.Errorf("%s: no %s event recorded", test.label, eventReasonExpiringSoon)
+			}
+		}
+	}
+}
+
+// TestExpiringSoonWarnsOnce tests that the expiry warning is not repeated on every reconcile
+// given: a SubjectPermission expiring within the warning window, reconciled three times
+// expected: a single ExpiringSoon Event, recorded in the status
+func TestExpiringSoonWarnsOnce(t *testing.T) {
+	ctx := context.TODO()
+	if err := apis.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("Unable to add apis scheme: (%v)", err)
+	}
+
+	subjectPermission := mockSubjectPermission()
+	subjectPermission.Spec.SubjectKind = "Group"
+	subjectPermission.Spec.Permissions = nil
+	subjectPermission.Spec.ClusterPermissions = []string{"exampleClusterRoleName"}
+	subjectPermission.Spec.NotAfter = &metav1.Time{Time: time.Now().Add(10 * time.Minute)}
+	subjectPermission.Finalizers = []string{controllerutil.SubjectPermissionFinalizer}
+
+	recorder := record.NewFakeRecorder(100)
+	reconciler := &ReconcileSubjectPermission{
+		client: fake.NewFakeClient(
+			subjectPermission,
+			&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "exampleClusterRoleName"}},
+		),
+		scheme:   scheme.Scheme,
+		recorder: recorder,
+	}
+
+	key := types.NamespacedName{Name: subjectPermission.Name, Namespace: subjectPermission.Namespace}
+	for i := 0; i < 3; i++ {
+		if _, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("Reconcile %d failed: %s", i, err)
+		}
+	}
+
+	warnings := 0
+	for len(recorder.Events) > 0 {
+		if event := <-recorder.Events; strings.Contains(event, eventReasonExpiringSoon) {
+			warnings++
+		}
+	}
+	if warnings != 1 {
+		t.Errorf("got %d %s events, want 1", warnings, eventReasonExpiringSoon)
+	}
+
+	updated := &v1alpha1.SubjectPermission{}
+	if err := reconciler.client.Get(ctx, key, updated); err != nil {
+		t.Fatalf("Couldn't get SubjectPermission: %s", err)
+	}
+	if len(updated.Status.ExpiryWarnings) != 1 {
+		t.Errorf("got expiry warnings %v, want 1", updated.Status.ExpiryWarnings)
+	}
+}
+
+// TestAuditMode tests that Audit mode writes nothing but reports the changes it would make
+// given: a SubjectPermission in Audit mode with a stale ClusterRoleBinding and a missing RoleBinding
+// expected: the bindings are left as they are, the status and Events list the changes,
+// and deleting the SubjectPermission revokes the bindings it owns
+func TestAuditMode(t *testing.T) {
+	ctx := context.TODO()
+	if err := apis.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("Unable to add apis scheme: (%v)", err)
+	}
+
+	subjectPermission := mockSubjectPermission()
+	subjectPermission.Spec.SubjectKind = "Group"
+	subjectPermission.Spec.ClusterPermissions = nil
+	subjectPermission.Spec.Permissions[0].NamespacesAllowedRegex = "^examplenamespace$"
+	subjectPermission.Spec.Mode = v1alpha1.ModeAudit
+	subjectPermission.Finalizers = []string{controllerutil.SubjectPermissionFinalizer}
+	removed := mockSubjectPermission()
+	removed.Spec.SubjectKind = "Group"
+
+	recorder := record.NewFakeRecorder(100)
+	reconciler := &ReconcileSubjectPermission{
+		client: fake.NewFakeClient(
+			subjectPermission,
+			&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "exampleClusterRoleName"}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "examplenamespace"}},
+			controllerutil.NewClusterRoleBinding(removed, 0),
+		),
+		scheme:   scheme.Scheme,
+		recorder: recorder,
+	}
+
+	key := types.NamespacedName{Name: subjectPermission.Name, Namespace: subjectPermission.Namespace}
+	if _, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile failed: %s", err)
+	}
+
+	crbList := &rbacv1.ClusterRoleBindingList{}
+	if err := reconciler.client.List(ctx, &client.ListOptions{}, crbList); err != nil {
+		t.Fatalf("Couldn't list ClusterRoleBindings: %s", err)
+	}
+	rbList := &rbacv1.RoleBindingList{}
+	if err := reconciler.client.List(ctx, &client.ListOptions{}, rbList); err != nil {
+		t.Fatalf("Couldn't list RoleBindings: %s", err)
+	}
+	if len(crbList.Items) != 1 || len(rbList.Items) != 0 {
+		t.Errorf("got %d ClusterRoleBindings and %d RoleBindings, want them untouched", len(crbList.Items), len(rbList.Items))
+	}
+
+	updated := &v1alpha1.SubjectPermission{}
+	if err := reconciler.client.Get(ctx, key, updated); err != nil {
+		t.Fatalf("Couldn't get SubjectPermission: %s", err)
+	}
+	if updated.Status.State != string(v1alpha1.SubjectPermissionAudit) {
+		t.Errorf("got state %q, want %q", updated.Status.State, v1alpha1.SubjectPermissionAudit)
+	}
+	expectedChanges := []v1alpha1.PlannedChange{
+		{Action: planner.ActionDelete, Kind: "ClusterRoleBinding", Name: crbList.Items[0].Name},
+		{Action: planner.ActionCreate, Kind: "RoleBinding", Namespace: "examplenamespace", Name: controllerutil.NewRoleBindingForClusterRole(subjectPermission, 0, "examplenamespace").Name},
+	}
+	if updated.Status.PlannedChangeCount != 2 || !reflect.DeepEqual(updated.Status.PlannedChanges, expectedChanges) {
+		t.Errorf("got %d planned changes %v, want %v", updated.Status.PlannedChangeCount, updated.Status.PlannedChanges, expectedChanges)
+	}
+
+	var reasons []string
+	for len(recorder.Events) > 0 {
+		event := <-recorder.Events
+		reasons = append(reasons, strings.Fields(event)[1])
+	}
+	if !reflect.DeepEqual(reasons, []string{"WouldCreate", "WouldDelete"}) {
+		t.Errorf("got Events with reasons %v, want WouldCreate and WouldDelete", reasons)
+	}
+
+	// deleting the SubjectPermission revokes the bindings it owns, like in Enforce mode
+	now := metav1.Now()
+	updated.DeletionTimestamp = &now
+	if err := reconciler.client.Update(ctx, updated); err != nil {
+		t.Fatalf("Couldn't mark SubjectPermission as deleted: %s", err)
+	}
+	if _, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile of the deletion failed: %s", err)
+	}
+	if err := reconciler.client.List(ctx, &client.ListOptions{}, crbList); err != nil {
+		t.Fatalf("Couldn't list ClusterRoleBindings: %s", err)
+	}
+	if len(crbList.Items) != 0 {
+		t.Errorf("got %d ClusterRoleBindings after deletion, want 0", len(crbList.Items))
+	}
+}
+
+// TestSuspend tests that a suspended SubjectPermission leaves its bindings alone
+// given: a suspended SubjectPermission with a stale ClusterRoleBinding and an allowed namespace
+// expected: nothing is created or revoked and the status is Suspended, once resumed the bindings are applied
+func TestSuspend(t *testing.T) {
+	ctx := context.TODO()
+	if err := apis.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("Unable to add apis scheme: (%v)", err)
+	}
+
+	subjectPermission := mockSubjectPermission()
+	subjectPermission.Spec.SubjectKind = "Group"
+	subjectPermission.Spec.ClusterPermissions = nil
+	subjectPermission.Spec.Permissions[0].NamespacesAllowedRegex = "^examplenamespace$"
+	subjectPermission.Spec.Suspend = true
+	subjectPermission.Finalizers = []string{controllerutil.SubjectPermissionFinalizer}
+	removed := mockSubjectPermission()
+	removed.Spec.SubjectKind = "Group"
+
+	reconciler := &ReconcileSubjectPermission{
+		client: fake.NewFakeClient(
+			subjectPermission,
+			&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "exampleClusterRoleName"}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "examplenamespace"}},
+			controllerutil.NewClusterRoleBinding(removed, 0),
+		),
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(100),
+	}
+
+	var tests = []struct {
+		suspend   bool
+		crbs, rbs int
+		state     v1alpha1.SubjectPermissionState
+		reason    string
+	}{
+		{true, 1, 0, v1alpha1.SubjectPermissionSuspended, controllerutil.ReasonSuspended},
+		{false, 0, 1, v1alpha1.SubjectPermissionReady, controllerutil.ReasonResumed},
+	}
+
+	key := types.NamespacedName{Name: subjectPermission.Name, Namespace: subjectPermission.Namespace}
+	for _, test := range tests {
+		current := &v1alpha1.SubjectPermission{}
+		if err := reconciler.client.Get(ctx, key, current); err != nil {
+			t.Fatalf("Couldn't get SubjectPermission: %s", err)
+		}
+		current.Spec.Suspend = test.suspend
+		if err := reconciler.client.Update(ctx, current); err != nil {
+			t.Fatalf("Couldn't update SubjectPermission: %s", err)
+		}
+		if _, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("Reconcile failed: %s", err)
+		}
+
+		crbList := &rbacv1.ClusterRoleBindingList{}
+		if err := reconciler.client.List(ctx, &client.ListOptions{}, crbList); err != nil {
+			t.Fatalf("Couldn't list ClusterRoleBindings: %s", err)
+		}
+		rbList := &rbacv1.RoleBindingList{}
+		if err := reconciler.client.List(ctx, &client.ListOptions{}, rbList); err != nil {
+			t.Fatalf("Couldn't list RoleBindings: %s", err)
+		}
+		if len(crbList.Items) != test.crbs || len(rbList.Items) != test.rbs {
+			t.Errorf("suspend %t: got %d ClusterRoleBindings and %d RoleBindings, want %d and %d", test.suspend, len(crbList.Items), len(rbList.Items), test.crbs, test.rbs)
+		}
+
+		updated := &v1alpha1.SubjectPermission{}
+		if err := reconciler.client.Get(ctx, key, updated); err != nil {
+			t.Fatalf("Couldn't get SubjectPermission: %s", err)
+		}
+		if updated.Status.State != string(test.state) {
+			t.Errorf("suspend %t: got state %q, want %q", test.suspend, updated.Status.State, test.state)
+		}
+		condition := controllerutil.FindCondition(updated, v1alpha1.SubjectPermissionConditionSuspended)
+		if condition == nil || condition.Status != test.suspend || condition.Reason != test.reason {
+			t.Errorf("suspend %t: got Suspended condition %v, want status %t with reason %s", test.suspend, condition, test.suspend, test.reason)
+		}
+	}
+}
+
+// TestSuspendedDeletion tests that deleting a suspended SubjectPermission revokes its bindings
+// given: a suspended SubjectPermission being deleted, with a ClusterRoleBinding and a RoleBinding it owns
+// expected: the bindings are revoked and the finalizer is removed
+func TestSuspendedDeletion(t *testing.T) {
+	ctx := context.TODO()
+	if err := apis.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("Unable to add apis scheme: (%v)", err)
+	}
+
+	subjectPermission := mockSubjectPermission()
+	subjectPermission.Spec.SubjectKind = "Group"
+	subjectPermission.Spec.Suspend = true
+	now := metav1.Now()
+	subjectPermission.DeletionTimestamp = &now
+	subjectPermission.Finalizers = []string{controllerutil.SubjectPermissionFinalizer}
+
+	reconciler := &ReconcileSubjectPermission{
+		client: fake.NewFakeClient(
+			subjectPermission,
+			controllerutil.NewClusterRoleBinding(subjectPermission, 0),
+			controllerutil.NewRoleBindingForClusterRole(subjectPermission, 0, "examplenamespace"),
+		),
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(100),
+	}
+
+	key := types.NamespacedName{Name: subjectPermission.Name, Namespace: subjectPermission.Namespace}
+	if _, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile failed: %s", err)
+	}
+
+	crbList := &rbacv1.ClusterRoleBindingList{}
+	if err := reconciler.client.List(ctx, &client.ListOptions{}, crbList); err != nil {
+		t.Fatalf("Couldn't list ClusterRoleBindings: %s", err)
+	}
+	rbList := &rbacv1.RoleBindingList{}
+	if err := reconciler.client.List(ctx, &client.ListOptions{}, rbList); err != nil {
+		t.Fatalf("Couldn't list RoleBindings: %s", err)
+	}
+	if len(crbList.Items) != 0 || len(rbList.Items) != 0 {
+		t.Errorf("got %d ClusterRoleBindings and %d RoleBindings, want all of them revoked", len(crbList.Items), len(rbList.Items))
+	}
+
+	updated := &v1alpha1.SubjectPermission{}
+	if err := reconciler.client.Get(ctx, key, updated); err != nil {
+		t.Fatalf("Couldn't get SubjectPermission: %s", err)
+	}
+	if controllerutil.ContainsString(updated.Finalizers, controllerutil.SubjectPermissionFinalizer) {
+		t.Errorf("finalizer was not removed from the SubjectPermission")
+	}
+}
+
+// TestVerifySubjects tests holding back the bindings of a SubjectPermission until its Subjects exist
+// given: a SubjectPermission verifying a Group and a ServiceAccount that do not exist, which are then created
+// expected: nothing is bound and SubjectNotFound names both Subjects, once they exist the bindings are created
+// and the condition is cleared
+func TestVerifySubjects(t *testing.T) {
+	ctx := context.TODO()
+	if err := apis.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("Unable to add apis scheme: (%v)", err)
+	}
+	if err := userv1.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("Unable to add user scheme: (%v)", err)
+	}
+
+	subjectPermission := mockSubjectPermission()
+	subjectPermission.Spec.SubjectKind, subjectPermission.Spec.SubjectName = "", ""
+	subjectPermission.Spec.Subjects = []rbacv1.Subject{
+		{Kind: "Group", Name: "dev"},
+		{Kind: "ServiceAccount", Namespace: "ops", Name: "deployer"},
+	}
+	subjectPermission.Spec.ClusterPermissions = []string{"exampleClusterRoleName"}
+	subjectPermission.Spec.Permissions[0].NamespacesAllowedRegex = "^examplenamespace$"
+	subjectPermission.Spec.VerifySubjects = true
+
+	reconciler := &ReconcileSubjectPermission{
+		client: fake.NewFakeClient(
+			subjectPermission,
+			&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "exampleClusterRoleName"}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "examplenamespace"}},
+		),
+		scheme:      scheme.Scheme,
+		recorder:    record.NewFakeRecorder(100),
+		verifyUsers: true,
+	}
+
+	var tests = []struct {
+		label     string
+		create    []runtime.Object
+		crbs, rbs int
+		state     v1alpha1.SubjectPermissionState
+		missing   bool
+		message   string
+	}{
+		{"missing", nil, 0, 0, v1alpha1.SubjectPermissionSubjectNotFound, true, "Group dev does not exist; ServiceAccount deployer in namespace ops does not exist"},
+		{"group created", []runtime.Object{&userv1.Group{ObjectMeta: metav1.ObjectMeta{Name: "dev"}}}, 0, 0, v1alpha1.SubjectPermissionSubjectNotFound, true, "ServiceAccount deployer in namespace ops does not exist"},
+		{"service account created", []runtime.Object{&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "ops", Name: "deployer"}}}, 1, 1, v1alpha1.SubjectPermissionReady, false, ""},
+	}
+
+	key := types.NamespacedName{Name: subjectPermission.Name, Namespace: subjectPermission.Namespace}
+	for _, test := range tests {
+		for _, object := range test.create {
+			if err := reconciler.client.Create(ctx, object); err != nil {
+				t.Fatalf("Couldn't create subject: %s", err)
+			}
+		}
+		if _, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("Reconcile failed: %s", err)
+		}
+
+		crbList := &rbacv1.ClusterRoleBindingList{}
+		if err := reconciler.client.List(ctx, &client.ListOptions{}, crbList); err != nil {
+			t.Fatalf("Couldn't list ClusterRoleBindings: %s", err)
+		}
+		rbList := &rbacv1.RoleBindingList{}
+		if err := reconciler.client.List(ctx, &client.ListOptions{}, rbList); err != nil {
+			t.Fatalf("Couldn't list RoleBindings: %s", err)
+		}
+		if len(crbList.Items) != test.crbs || len(rbList.Items) != test.rbs {
+			t.Errorf("%s: got %d ClusterRoleBindings and %d RoleBindings, want %d and %d", test.label, len(crbList.Items), len(rbList.Items), test.crbs, test.rbs)
+		}
+
+		updated := &v1alpha1.SubjectPermission{}
+		if err := reconciler.client.Get(ctx, key, updated); err != nil {
+			t.Fatalf("Couldn't get SubjectPermission: %s", err)
+		}
+		if updated.Status.State != string(test.state) {
+			t.Errorf("%s: got state %q, want %q", test.label, updated.Status.State, test.state)
+		}
+		condition := controllerutil.FindCondition(updated, v1alpha1.SubjectPermissionConditionSubjectNotFound)
+		if condition == nil || condition.Status != test.missing || condition.Message != test.message {
+			t.Errorf("%s: got SubjectNotFound condition %v, want status %t with message %q", test.label, condition, test.missing, test.message)
+		}
+	}
+}
+
+// TestBindingEvents tests the Events recorded for the bindings of a SubjectPermission
+// given: a Permission granted in one namespace, a stale ClusterRoleBinding and a Permission of a missing ClusterRole
+// expected: the created RoleBinding is reported on the SubjectPermission and the Namespace, the revoked
+// ClusterRoleBinding and the missing ClusterRole on the SubjectPermission, and nothing is repeated on the next reconcile
+func TestBindingEvents(t *testing.T) {
+	if err := apis.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("Unable to add apis scheme: (%v)", err)
+	}
+
+	subjectPermission := mockSubjectPermission()
+	subjectPermission.Spec.SubjectKind = "Group"
+	subjectPermission.Spec.ClusterPermissions = nil
+	subjectPermission.Spec.Permissions[0].NamespacesAllowedRegex = "^examplenamespace$"
+	subjectPermission.Spec.Permissions = append(subjectPermission.Spec.Permissions, v1alpha1.Permission{ClusterRoleName: "missingClusterRoleName", NamespacesAllowedRegex: "^nothing$"})
+	subjectPermission.Finalizers = []string{controllerutil.SubjectPermissionFinalizer}
+	removed := mockSubjectPermission()
+	removed.Spec.SubjectKind = "Group"
+
+	recorder := record.NewFakeRecorder(100)
+	reconciler := &ReconcileSubjectPermission{
+		client: fake.NewFakeClient(
+			subjectPermission,
+			&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "exampleClusterRoleName"}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "examplenamespace"}},
+			controllerutil.NewClusterRoleBinding(removed, 0),
+		),
+		scheme:   scheme.Scheme,
+		recorder: recorder,
+	}
+
+	var tests = []struct {
+		expected []string
+	}{
+		{[]string{
+			"Normal " + controllerutil.EventReasonBindingCreated,
+			"Normal " + controllerutil.EventReasonBindingCreated,
+			"Normal " + controllerutil.EventReasonBindingRevoked,
+			"Warning " + controllerutil.EventReasonClusterRoleMissing,
+		}},
+		{nil},
+	}
+
+	key := types.NamespacedName{Name: subjectPermission.Name, Namespace: subjectPermission.Namespace}
+	for i, test := range tests {
+		if _, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("Reconcile failed: %s", err)
+		}
+
+		var events []string
+		for len(recorder.Events) > 0 {
+			fields := strings.Fields(<-recorder.Events)
+			events = append(events, fields[0]+" "+fields[1])
+		}
+		if !reflect.DeepEqual(events, test.expected) {
+			t.Errorf("reconcile %d: got Events %v, want %v", i+1, events, test.expected)
+		}
+	}
+}
+
+// TestClusterPermissionsOutOfScope tests that the ignored ClusterPermissions of a namespaced SubjectPermission are surfaced
+// given: a SubjectPermission outside the operator namespace with ClusterPermissions, reconciled twice
+// expected: no ClusterRoleBinding, the Degraded condition reports the ClusterPermissions and a single Warning Event is recorded
+func TestClusterPermissionsOutOfScope(t *testing.T) {
+	ctx := context.TODO()
+	if err := apis.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("Unable to add apis scheme: (%v)", err)
+	}
+
+	subjectPermission := mockSubjectPermission()
+	subjectPermission.Namespace = "team-a"
+	subjectPermission.Spec.SubjectKind = "Group"
+	subjectPermission.Spec.Permissions = nil
+	subjectPermission.Finalizers = []string{controllerutil.SubjectPermissionFinalizer}
+
+	recorder := record.NewFakeRecorder(100)
+	reconciler := &ReconcileSubjectPermission{
+		client: fake.NewFakeClient(
+			subjectPermission,
+			&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "exampleClusterRoleName"}},
+			&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "exampleClusterRoleNameTwo"}},
+		),
+		scheme:   scheme.Scheme,
+		recorder: recorder,
+	}
+
+	var tests = []struct {
+		expected []string
+	}{
+		{[]string{"Warning " + controllerutil.EventReasonClusterPermissionsIgnored}},
+		{nil},
+	}
+
+	key := types.NamespacedName{Name: subjectPermission.Name, Namespace: subjectPermission.Namespace}
+	for i, test := range tests {
+		if _, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("Reconcile failed: %s", err)
+		}
+
+		var events []string
+		for len(recorder.Events) > 0 {
+			fields := strings.Fields(<-recorder.Events)
+			events = append(events, fields[0]+" "+fields[1])
+		}
+		if !reflect.DeepEqual(events, test.expected) {
+			t.Errorf("reconcile %d: got Events %v, want %v", i+1, events, test.expected)
+		}
+	}
+
+	reconciled := &v1alpha1.SubjectPermission{}
+	if err := reconciler.client.Get(ctx, key, reconciled); err != nil {
+		t.Fatalf("Couldn't get SubjectPermission: %s", err)
+	}
+	condition := controllerutil.FindCondition(reconciled, v1alpha1.SubjectPermissionConditionDegraded)
+	expected := (&planner.ClusterPermissionsOutOfScopeError{Namespace: "team-a"}).Error()
+	if condition == nil || !condition.Status || condition.Reason != controllerutil.ReasonNotGranted || condition.Message != expected {
+		t.Errorf("got Degraded condition %+v, want it to report %q", condition, expected)
+	}
+
+	crbList := &rbacv1.ClusterRoleBindingList{}
+	if err := reconciler.client.List(ctx, &client.ListOptions{}, crbList); err != nil {
+		t.Fatalf("Couldn't list ClusterRoleBindings: %s", err)
+	}
+	if len(crbList.Items) != 0 {
+		t.Errorf("got %d ClusterRoleBindings, want none for a namespaced SubjectPermission", len(crbList.Items))
+	}
+}
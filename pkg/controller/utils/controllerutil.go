@@ -1,9 +1,10 @@
 package util
 
 import (
-	"regexp"
+	"reflect"
 
 	managedv1alpha1 "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	"github.com/openshift/rbac-permissions-operator/pkg/utility"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -23,111 +24,195 @@ func PopulateCrPermissionClusterRoleNames(subjectPermission *managedv1alpha1.Sub
 
 	var permissionClusterRoleNames []string
 
-	for _, i := range clusterRoleList.Items {
-		for _, a := range permissions {
-			if i.Name != a.ClusterRoleName {
-				permissionClusterRoleNames = append(permissionClusterRoleNames, a.ClusterRoleName)
-			}
+	for _, a := range permissions {
+		// the ClusterRoles of Rules are created by the operator
+		if len(a.Rules) > 0 {
+			continue
+		}
+		if !ClusterRoleExists(a.ClusterRoleName, clusterRoleList) && !ContainsString(permissionClusterRoleNames, a.ClusterRoleName) {
+			permissionClusterRoleNames = append(permissionClusterRoleNames, a.ClusterRoleName)
 		}
 	}
 
 	return permissionClusterRoleNames
 }
 
-// GenerateSafeList by 1st checking allow regex then check denied regex
-func GenerateSafeList(allowedRegex string, deniedRegex string, nsList *corev1.NamespaceList) []string {
-
-	safeList := allowedNamespacesList(allowedRegex, nsList)
-	updatedSafeList := safeListAfterDeniedRegex(deniedRegex, safeList)
-
-	return updatedSafeList
-
+// ClusterRoleExists checks if a ClusterRole named clusterRoleName is in clusterRoleList
+func ClusterRoleExists(clusterRoleName string, clusterRoleList *v1.ClusterRoleList) bool {
+	for _, clusterRole := range clusterRoleList.Items {
+		if clusterRole.Name == clusterRoleName {
+			return true
+		}
+	}
+	return false
 }
 
-// allowedNamespacesList 1st pass - allowedRegex
-func allowedNamespacesList(allowedRegex string, nsList *corev1.NamespaceList) []string {
-	var matches []string
-
-	// for every namespace on the cluster
-	// check that against the allowedRegex in Permission
+// GenerateSafeList returns the namespaces of nsList allowed by the regex of a Permission, see utility.IsNamespaceAllowed
+func GenerateSafeList(allowedRegex string, deniedRegex string, allowFirst bool, nsList *corev1.NamespaceList) []string {
+	var namespaces []string
 	for _, namespace := range nsList.Items {
-		rp := regexp.MustCompile(allowedRegex)
-
-		// if namespace on cluster matches with regex, append them to slice
-		found := rp.MatchString(namespace.Name)
-		if found {
-			matches = append(matches, namespace.Name)
-		}
+		namespaces = append(namespaces, namespace.Name)
 	}
-
-	return matches
+	return utility.AllowedNamespaces(allowedRegex, deniedRegex, allowFirst, namespaces)
 }
 
-// safeListAfterDeniedRegex 2nd pass - deniedRegex
-func safeListAfterDeniedRegex(namespacesDeniedRegex string, safeList []string) []string {
-	var updatedSafeList []string
-
-	// for every namespace on SafeList
-	// check that against deniedRegex
-	for _, namespace := range safeList {
-		rp := regexp.MustCompile(namespacesDeniedRegex)
+// NewClusterRoleBinding creates and returns ClusterRoleBinding for the ClusterPermission at clusterPermissionIndex
+func NewClusterRoleBinding(subjectPermission *managedv1alpha1.SubjectPermission, clusterPermissionIndex int) *v1.ClusterRoleBinding {
+	clusterRoleName := subjectPermission.Spec.ClusterPermissions[clusterPermissionIndex]
+	subjects := utility.SubjectsForSubjectPermission(subjectPermission)
 
-		found := rp.MatchString(namespace)
-		// if it does not match then append
-		if !found {
-			updatedSafeList = append(updatedSafeList, namespace)
-		}
+	return &v1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        BindingName(subjectPermission, clusterRoleName, subjects...),
+			Labels:      OwnershipLabels(subjectPermission, PermissionScopeCluster, clusterPermissionIndex),
+			Annotations: OwnershipAnnotations(subjectPermission),
+		},
+		Subjects: subjects,
+		RoleRef: v1.RoleRef{
+			APIGroup: v1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     clusterRoleName,
+		},
 	}
-
-	return updatedSafeList
-
 }
 
-// NewRoleBindingForClusterRole creates and returns valid RoleBinding
-func NewRoleBindingForClusterRole(clusterRoleName, subjectName, subjectKind, namespace string) *v1.RoleBinding {
+// NewRoleBindingForClusterRole creates and returns valid RoleBinding for the Permission at permissionIndex
+func NewRoleBindingForClusterRole(subjectPermission *managedv1alpha1.SubjectPermission, permissionIndex int, namespace string) *v1.RoleBinding {
+	clusterRoleName := PermissionClusterRoleName(subjectPermission, permissionIndex)
+	subjects := utility.SubjectsForSubjectPermission(subjectPermission)
+
 	return &v1.RoleBinding{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      clusterRoleName + "-" + subjectName,
-			Namespace: namespace,
-		},
-		Subjects: []v1.Subject{
-			{
-				Kind: subjectKind,
-				Name: subjectName,
-			},
+			Name:        BindingName(subjectPermission, clusterRoleName, subjects...),
+			Namespace:   namespace,
+			Labels:      OwnershipLabels(subjectPermission, PermissionScopeNamespace, permissionIndex),
+			Annotations: OwnershipAnnotations(subjectPermission),
 		},
+		Subjects: subjects,
 		RoleRef: v1.RoleRef{
-			Kind: "ClusterRole",
-			Name: clusterRoleName,
+			APIGroup: v1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     clusterRoleName,
 		},
 	}
 }
 
-// UpdateCondition of SubjectPermission
-func UpdateCondition(subjectPermission *managedv1alpha1.SubjectPermission, message string, clusterRoleNames []string, status bool, state managedv1alpha1.SubjectPermissionState) *managedv1alpha1.SubjectPermission {
-	groupPermissionConditions := subjectPermission.Status.Conditions
+// NewClusterRoleForPermission creates and returns the ClusterRole holding the Rules of the Permission at permissionIndex
+func NewClusterRoleForPermission(subjectPermission *managedv1alpha1.SubjectPermission, permissionIndex int) *v1.ClusterRole {
+	return &v1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        GeneratedClusterRoleName(subjectPermission, permissionIndex),
+			Labels:      OwnershipLabels(subjectPermission, PermissionScopeNamespace, permissionIndex),
+			Annotations: OwnershipAnnotations(subjectPermission),
+		},
+		Rules: subjectPermission.Spec.Permissions[permissionIndex].Rules,
+	}
+}
 
-	// make a new condition
+// Reasons of the conditions of SubjectPermissions
+const (
+	// ReasonBindingsApplied is the reason of a Ready condition when every desired binding exists
+	ReasonBindingsApplied = "BindingsApplied"
+	// ReasonNotYetValid is the reason of a Ready condition before the Validity window
+	ReasonNotYetValid = "NotYetValid"
+	// ReasonExpired is the reason of a Ready condition after the Validity window
+	ReasonExpired = "Expired"
+	// ReasonClusterRoleMissing is the reason of conditions caused by a ClusterRole that does not exist
+	ReasonClusterRoleMissing = "ClusterRoleMissing"
+	// ReasonApplyFailed is the reason of conditions caused by a failed create, update or delete
+	ReasonApplyFailed = "ApplyFailed"
+	// ReasonNotGranted is the reason of conditions caused by permissions the operator refuses to grant
+	ReasonNotGranted = "NotGranted"
+	// ReasonInvalidSpec is the reason of conditions caused by an invalid spec
+	ReasonInvalidSpec = "InvalidSpec"
+	// ReasonAuditMode is the reason of a Ready condition in Audit mode
+	ReasonAuditMode = "AuditMode"
+	// ReasonSuspended is the reason of a Suspended condition while Suspend is set
+	ReasonSuspended = "Suspended"
+	// ReasonResumed is the reason of a Suspended condition once Suspend is unset
+	ReasonResumed = "Resumed"
+	// ReasonSubjectNotFound is the reason of conditions caused by a verified Subject that does not exist
+	ReasonSubjectNotFound = "SubjectNotFound"
+	// ReasonSubjectsFound is the reason of a SubjectNotFound condition once every Subject exists
+	ReasonSubjectsFound = "SubjectsFound"
+)
+
+// SetCondition sets the condition of conditionType of a SubjectPermission in place, LastTransitionTime only
+// changes when status flips. Conditions without a type, written by older versions, are dropped.
+// Returns true when the conditions changed
+func SetCondition(subjectPermission *managedv1alpha1.SubjectPermission, conditionType managedv1alpha1.SubjectPermissionConditionType, status bool, reason, message string, clusterRoleNames []string) bool {
 	newCondition := managedv1alpha1.Condition{
+		Type:               conditionType,
 		LastTransitionTime: metav1.Now(),
-		ClusterRoleNames:   clusterRoleNames,
+		Reason:             reason,
 		Message:            message,
+		ClusterRoleNames:   clusterRoleNames,
 		Status:             status,
-		State:              state,
 	}
 
-	// append new condition back to the conditions array
-	subjectPermission.Status.Conditions = append(groupPermissionConditions, newCondition)
+	changed, found := false, false
+	var conditions []managedv1alpha1.Condition
+	for _, condition := range subjectPermission.Status.Conditions {
+		if condition.Type == "" || (condition.Type == conditionType && found) {
+			changed = true
+			continue
+		}
+		if condition.Type == conditionType {
+			found = true
+			if condition.Status == status {
+				newCondition.LastTransitionTime = condition.LastTransitionTime
+			}
+			if condition.Status != status || condition.Reason != reason || condition.Message != message || !reflect.DeepEqual(condition.ClusterRoleNames, clusterRoleNames) {
+				changed = true
+			}
+			condition = newCondition
+		}
+		conditions = append(conditions, condition)
+	}
+	if !found {
+		conditions = append(conditions, newCondition)
+		changed = true
+	}
+
+	subjectPermission.Status.Conditions = conditions
+	return changed
+}
 
-	return subjectPermission
+// FindCondition returns the condition of conditionType of a SubjectPermission, or nil
+func FindCondition(subjectPermission *managedv1alpha1.SubjectPermission, conditionType managedv1alpha1.SubjectPermissionConditionType) *managedv1alpha1.Condition {
+	for i, condition := range subjectPermission.Status.Conditions {
+		if condition.Type == conditionType {
+			return &subjectPermission.Status.Conditions[i]
+		}
+	}
+	return nil
 }
 
-// RoleBindingExists checks if a rolebinding exists in the cluster already
-func RoleBindingExists(roleBinding *v1.RoleBinding, rbList *v1.RoleBindingList) bool {
-	for _, rb := range rbList.Items {
-		if roleBinding.Name == rb.Name {
+// BindingDrifted compares the Subjects and RoleRef of a generated binding with the desired ones.
+// roleRefChanged is true when the RoleRef drifted, which requires the binding to be recreated as RoleRef is immutable
+func BindingDrifted(desiredSubjects, subjects []v1.Subject, desiredRoleRef, roleRef v1.RoleRef) (drifted bool, roleRefChanged bool) {
+	roleRefChanged = !reflect.DeepEqual(desiredRoleRef, roleRef)
+	drifted = roleRefChanged || !reflect.DeepEqual(desiredSubjects, subjects)
+	return drifted, roleRefChanged
+}
+
+// ContainsString checks if a string is in a slice of strings
+func ContainsString(slice []string, s string) bool {
+	for _, item := range slice {
+		if item == s {
 			return true
 		}
 	}
 	return false
 }
+
+// RemoveString returns a copy of slice without any occurrence of s
+func RemoveString(slice []string, s string) []string {
+	var result []string
+	for _, item := range slice {
+		if item != s {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,60 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	managedv1alpha1 "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestSetCondition tests that conditions are updated in place
+// given: a SubjectPermission with a condition written by an older version and a Ready condition
+// expected: one condition per type, LastTransitionTime only changes when the status flips
+func TestSetCondition(t *testing.T) {
+	transition := metav1.NewTime(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC))
+	subjectPermission := &managedv1alpha1.SubjectPermission{
+		Status: managedv1alpha1.SubjectPermissionStatus{
+			Conditions: []managedv1alpha1.Condition{
+				{LastTransitionTime: transition, Message: "legacy", Status: true},
+				{Type: managedv1alpha1.SubjectPermissionConditionReady, LastTransitionTime: transition, Reason: ReasonBindingsApplied, Status: true},
+			},
+		},
+	}
+
+	var tests = []struct {
+		label      string
+		status     bool
+		reason     string
+		changed    bool
+		transition bool
+	}{
+		{"legacy condition is dropped", true, ReasonBindingsApplied, true, false},
+		{"same status and reason", true, ReasonBindingsApplied, false, false},
+		{"same status with another reason", true, ReasonExpired, true, false},
+		{"status flips", false, ReasonApplyFailed, true, true},
+	}
+
+	for _, test := range tests {
+		before := FindCondition(subjectPermission, managedv1alpha1.SubjectPermissionConditionReady).LastTransitionTime
+		changed := SetCondition(subjectPermission, managedv1alpha1.SubjectPermissionConditionReady, test.status, test.reason, "", nil)
+		if changed != test.changed {
+			t.Errorf("%s: got changed %t, want %t", test.label, changed, test.changed)
+		}
+		if len(subjectPermission.Status.Conditions) != 1 {
+			t.Fatalf("%s: got %d conditions, want 1", test.label, len(subjectPermission.Status.Conditions))
+		}
+		condition := subjectPermission.Status.Conditions[0]
+		if condition.Status != test.status || condition.Reason != test.reason {
+			t.Errorf("%s: got status %t and reason %s, want %t and %s", test.label, condition.Status, condition.Reason, test.status, test.reason)
+		}
+		if transitioned := !condition.LastTransitionTime.Equal(&before); transitioned != test.transition {
+			t.Errorf("%s: got LastTransitionTime changed %t, want %t", test.label, transitioned, test.transition)
+		}
+	}
+
+	// a new type is added next to the existing one
+	if !SetCondition(subjectPermission, managedv1alpha1.SubjectPermissionConditionDegraded, false, "", "", nil) || len(subjectPermission.Status.Conditions) != 2 {
+		t.Errorf("got conditions %v, want Ready and Degraded", subjectPermission.Status.Conditions)
+	}
+}
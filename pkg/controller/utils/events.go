@@ -0,0 +1,61 @@
+package util
+
+import (
+	"fmt"
+
+	managedv1alpha1 "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	"github.com/openshift/rbac-permissions-operator/pkg/utility"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+// Reasons of the Events recorded about the bindings of SubjectPermissions, they are stable so that alerts can rely on them
+const (
+	// EventReasonBindingCreated is the reason of a Normal Event for a created binding
+	EventReasonBindingCreated = "BindingCreated"
+	// EventReasonBindingUpdated is the reason of a Normal Event for an updated binding
+	EventReasonBindingUpdated = "BindingUpdated"
+	// EventReasonBindingRevoked is the reason of a Normal Event for a deleted binding that is no longer granted
+	EventReasonBindingRevoked = "BindingRevoked"
+	// EventReasonBindingFailed is the reason of a Warning Event for bindings that could not be written
+	EventReasonBindingFailed = "BindingFailed"
+	// EventReasonClusterRoleMissing is the reason of a Warning Event for a referenced ClusterRole that does not exist
+	EventReasonClusterRoleMissing = "ClusterRoleMissing"
+	// EventReasonClusterRoleCreated is the reason of a Normal Event for a created generated ClusterRole
+	EventReasonClusterRoleCreated = "ClusterRoleCreated"
+	// EventReasonClusterRoleUpdated is the reason of a Normal Event for an updated generated ClusterRole
+	EventReasonClusterRoleUpdated = "ClusterRoleUpdated"
+	// EventReasonClusterRoleDeleted is the reason of a Normal Event for a deleted generated ClusterRole
+	EventReasonClusterRoleDeleted = "ClusterRoleDeleted"
+	// EventReasonSubjectNotFound is the reason of a Warning Event for bindings held back until their Subjects exist
+	EventReasonSubjectNotFound = "SubjectNotFound"
+	// EventReasonClusterPermissionsIgnored is the reason of a Warning Event for the ClusterPermissions of a
+	// SubjectPermission that may only bind inside its own namespace
+	EventReasonClusterPermissionsIgnored = "ClusterPermissionsIgnored"
+)
+
+// EventObject returns the object Events about a SubjectPermission are recorded on, which is the
+// ClusterSubjectPermission it stands for when it has no namespace
+func EventObject(subjectPermission *managedv1alpha1.SubjectPermission) runtime.Object {
+	if subjectPermission.Namespace == "" {
+		return utility.ClusterSubjectPermissionFor(subjectPermission)
+	}
+	return subjectPermission
+}
+
+// RecordEvent records an Event on a SubjectPermission and, when namespace is set, the same Event on
+// that Namespace, naming the SubjectPermission it is about
+func RecordEvent(recorder record.EventRecorder, subjectPermission *managedv1alpha1.SubjectPermission, namespace, eventType, reason, message string) {
+	recorder.Event(EventObject(subjectPermission), eventType, reason, message)
+	if namespace == "" {
+		return
+	}
+
+	owner := fmt.Sprintf("SubjectPermission %s/%s", subjectPermission.Namespace, subjectPermission.Name)
+	if subjectPermission.Namespace == "" {
+		owner = "ClusterSubjectPermission " + subjectPermission.Name
+	}
+	recorder.Eventf(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}, eventType, reason, "%s for %s", message, owner)
+}
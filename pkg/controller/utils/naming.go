@@ -0,0 +1,94 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+
+	managedv1alpha1 "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	v1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// maxBindingNameLength is the longest name a generated binding can have
+	maxBindingNameLength = validation.DNS1123SubdomainMaxLength
+	// bindingNameHashLength is the number of hex characters of the hash suffix
+	bindingNameHashLength = 10
+)
+
+// BindingName returns the name of the binding of clusterRoleName to subjects generated for subjectPermission.
+// The name starts with a readable clusterRoleName-kind-subjectName prefix, truncated when needed,
+// and ends with a hash of the owning SubjectPermission, the role and full subjects so different
+// (owner, role, subjects) never collide, even when two SubjectPermissions grant the same role to the same subjects.
+// The order of subjects does not matter and their APIGroup is not part of the name.
+// The name is only an identifier and must never be parsed back into a role and subjects.
+func BindingName(subjectPermission *managedv1alpha1.SubjectPermission, clusterRoleName string, subjects ...v1.Subject) string {
+	sorted := append([]v1.Subject(nil), subjects...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return subjectKey(sorted[i]) < subjectKey(sorted[j])
+	})
+
+	// ClusterSubjectPermissions have no namespace
+	parts := []string{subjectPermission.Namespace, subjectPermission.Name, clusterRoleName}
+	for _, subject := range sorted {
+		parts = append(parts, subjectKey(subject))
+	}
+	hash := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	suffix := "-" + hex.EncodeToString(hash[:])[:bindingNameHashLength]
+
+	prefix := clusterRoleName
+	if len(sorted) > 0 {
+		prefix += "-" + strings.ToLower(sorted[0].Kind) + "-" + sorted[0].Name
+	}
+	prefix = sanitizeBindingName(prefix)
+	if len(prefix) > maxBindingNameLength-len(suffix) {
+		prefix = prefix[:maxBindingNameLength-len(suffix)]
+	}
+
+	return prefix + suffix
+}
+
+// subjectKey returns the fields identifying a subject in a binding name
+func subjectKey(subject v1.Subject) string {
+	return strings.Join([]string{subject.Kind, subject.Namespace, subject.Name}, "\x00")
+}
+
+// sanitizeBindingName replaces the characters that are not allowed in RBAC object names
+func sanitizeBindingName(name string) string {
+	return strings.NewReplacer("/", "-", "%", "-").Replace(name)
+}
+
+// GeneratedClusterRoleName returns the name of the ClusterRole generated for the Rules of the Permission
+// at permissionIndex. The name is stable while the Permission stays at the same index, so changing its
+// Rules updates the ClusterRole in place. The hash suffix keeps the names of SubjectPermissions
+// in different namespaces apart.
+func GeneratedClusterRoleName(subjectPermission *managedv1alpha1.SubjectPermission, permissionIndex int) string {
+	index := strconv.Itoa(permissionIndex)
+	hash := sha256.Sum256([]byte(strings.Join([]string{subjectPermission.Namespace, subjectPermission.Name, index}, "\x00")))
+	suffix := "-" + hex.EncodeToString(hash[:])[:bindingNameHashLength]
+
+	// ClusterSubjectPermissions have no namespace
+	parts := []string{"subjectpermission"}
+	if subjectPermission.Namespace != "" {
+		parts = append(parts, subjectPermission.Namespace)
+	}
+	prefix := strings.Join(append(parts, subjectPermission.Name, index), "-")
+	if len(prefix) > maxBindingNameLength-len(suffix) {
+		prefix = prefix[:maxBindingNameLength-len(suffix)]
+	}
+
+	return prefix + suffix
+}
+
+// PermissionClusterRoleName returns the name of the ClusterRole bound for the Permission at permissionIndex,
+// which is the generated one when the Permission has Rules
+func PermissionClusterRoleName(subjectPermission *managedv1alpha1.SubjectPermission, permissionIndex int) string {
+	permission := subjectPermission.Spec.Permissions[permissionIndex]
+	if len(permission.Rules) > 0 {
+		return GeneratedClusterRoleName(subjectPermission, permissionIndex)
+	}
+	return permission.ClusterRoleName
+}
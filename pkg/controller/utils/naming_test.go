@@ -0,0 +1,129 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	managedv1alpha1 "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	v1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// owner is the SubjectPermission the bindings of the tests are generated for
+var owner = &managedv1alpha1.SubjectPermission{ObjectMeta: metav1.ObjectMeta{Namespace: "openshift-rbac-permissions-operator", Name: "dev"}}
+
+func TestBindingName(t *testing.T) {
+	var tests = []struct {
+		clusterRoleName string
+		subject         v1.Subject
+		prefix          string
+	}{
+		{"dedicated-admins-cluster", v1.Subject{Kind: "Group", Name: "dedicated-admins"}, "dedicated-admins-cluster-group-dedicated-admins-"},
+		{"view", v1.Subject{Kind: "User", Name: "system:admin"}, "view-user-system:admin-"},
+		{"view", v1.Subject{Kind: "ServiceAccount", Namespace: "ns", Name: "sa"}, "view-serviceaccount-sa-"},
+		{"view", v1.Subject{Kind: "Group", Name: "a/b%c"}, "view-group-a-b-c-"},
+	}
+	for _, test := range tests {
+		name := BindingName(owner, test.clusterRoleName, test.subject)
+		if !strings.HasPrefix(name, test.prefix) {
+			t.Errorf("BindingName(%s, %v) = %s, expected prefix %s", test.clusterRoleName, test.subject, name, test.prefix)
+		}
+		if name != BindingName(owner, test.clusterRoleName, test.subject) {
+			t.Errorf("BindingName(%s, %v) is not deterministic", test.clusterRoleName, test.subject)
+		}
+	}
+}
+
+func TestBindingNameIsUnique(t *testing.T) {
+	var tests = []struct {
+		clusterRoleName string
+		subject         v1.Subject
+	}{
+		// same readable prefix, different split between role and subject
+		{"a-b", v1.Subject{Kind: "Group", Name: "c"}},
+		{"a", v1.Subject{Kind: "Group", Name: "b-c"}},
+		// same name, different kinds
+		{"view", v1.Subject{Kind: "Group", Name: "dev"}},
+		{"view", v1.Subject{Kind: "User", Name: "dev"}},
+		// same ServiceAccount name in different namespaces
+		{"view", v1.Subject{Kind: "ServiceAccount", Namespace: "one", Name: "dev"}},
+		{"view", v1.Subject{Kind: "ServiceAccount", Namespace: "two", Name: "dev"}},
+	}
+	names := make(map[string]int)
+	for i, test := range tests {
+		name := BindingName(owner, test.clusterRoleName, test.subject)
+		if j, found := names[name]; found {
+			t.Errorf("BindingName collision between test %d and %d: %s", j, i, name)
+		}
+		names[name] = i
+	}
+}
+
+func TestBindingNameIsBounded(t *testing.T) {
+	long := strings.Repeat("x", 300)
+	first := BindingName(owner, long, v1.Subject{Kind: "Group", Name: "one"})
+	second := BindingName(owner, long, v1.Subject{Kind: "Group", Name: "two"})
+
+	if len(first) > maxBindingNameLength {
+		t.Errorf("BindingName length %d is over %d", len(first), maxBindingNameLength)
+	}
+	if first == second {
+		t.Errorf("truncated BindingNames collide: %s", first)
+	}
+}
+
+func TestBindingNameForSubjects(t *testing.T) {
+	group := v1.Subject{Kind: "Group", Name: "dev"}
+	user := v1.Subject{Kind: "User", Name: "admin"}
+	withAPIGroup := v1.Subject{APIGroup: v1.GroupName, Kind: "Group", Name: "dev"}
+
+	var tests = []struct {
+		label    string
+		expected bool
+		found    bool
+	}{
+		{"subjects order does not matter", true, BindingName(owner, "view", group, user) == BindingName(owner, "view", user, group)},
+		{"APIGroup does not matter", true, BindingName(owner, "view", group) == BindingName(owner, "view", withAPIGroup)},
+		{"more subjects give another name", false, BindingName(owner, "view", group) == BindingName(owner, "view", group, user)},
+	}
+	for _, test := range tests {
+		if test.expected != test.found {
+			t.Errorf("%s: expected %t, found %t", test.label, test.expected, test.found)
+		}
+	}
+}
+
+func TestBindingNameForOwners(t *testing.T) {
+	group := v1.Subject{Kind: "Group", Name: "dev"}
+	others := []*managedv1alpha1.SubjectPermission{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: owner.Namespace, Name: "ops"}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: owner.Name}},
+		// a ClusterSubjectPermission has no namespace
+		{ObjectMeta: metav1.ObjectMeta{Name: owner.Name}},
+	}
+
+	name := BindingName(owner, "view", group)
+	for _, other := range others {
+		if otherName := BindingName(other, "view", group); otherName == name {
+			t.Errorf("BindingName of %s/%s collides with %s/%s: %s", other.Namespace, other.Name, owner.Namespace, owner.Name, name)
+		}
+	}
+}
+
+func TestGeneratedClusterRoleName(t *testing.T) {
+	subjectPermission := &managedv1alpha1.SubjectPermission{ObjectMeta: metav1.ObjectMeta{Namespace: "a-b", Name: "c"}}
+	other := &managedv1alpha1.SubjectPermission{ObjectMeta: metav1.ObjectMeta{Namespace: "a", Name: "b-c"}}
+
+	name := GeneratedClusterRoleName(subjectPermission, 0)
+	if name != GeneratedClusterRoleName(subjectPermission, 0) {
+		t.Errorf("GeneratedClusterRoleName is not stable")
+	}
+	if name == GeneratedClusterRoleName(subjectPermission, 1) || name == GeneratedClusterRoleName(other, 0) {
+		t.Errorf("GeneratedClusterRoleNames collide: %s", name)
+	}
+
+	long := &managedv1alpha1.SubjectPermission{ObjectMeta: metav1.ObjectMeta{Namespace: strings.Repeat("x", 63), Name: strings.Repeat("y", 253)}}
+	if length := len(GeneratedClusterRoleName(long, 0)); length > maxBindingNameLength {
+		t.Errorf("GeneratedClusterRoleName length %d is over %d", length, maxBindingNameLength)
+	}
+}
@@ -0,0 +1,143 @@
+package util
+
+import (
+	"strconv"
+
+	operatorconfig "github.com/openshift/rbac-permissions-operator/config"
+	managedv1alpha1 "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	"github.com/openshift/rbac-permissions-operator/version"
+	v1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// ManagedByLabel marks a binding as generated by the operator
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	// OwnerUIDLabel holds the UID of the SubjectPermission a binding was generated for
+	OwnerUIDLabel = "managed.openshift.io/subjectpermission-uid"
+	// PermissionScopeLabel holds the scope of the permission entry a binding was generated for
+	PermissionScopeLabel = "managed.openshift.io/permission-scope"
+	// PermissionIndexLabel holds the index of the permission entry a binding was generated for
+	PermissionIndexLabel = "managed.openshift.io/permission-index"
+
+	// OwnerNamespaceAnnotation holds the namespace of the SubjectPermission a binding was generated for
+	OwnerNamespaceAnnotation = "managed.openshift.io/subjectpermission-namespace"
+	// OwnerNameAnnotation holds the name of the SubjectPermission a binding was generated for
+	OwnerNameAnnotation = "managed.openshift.io/subjectpermission-name"
+	// OperatorVersionAnnotation holds the version of the operator that generated a binding
+	OperatorVersionAnnotation = "managed.openshift.io/operator-version"
+
+	// SubjectPermissionFinalizer holds a SubjectPermission until its bindings have been revoked, nothing is
+	// bound for a SubjectPermission before it carries it
+	SubjectPermissionFinalizer = "managed.openshift.io/subjectpermission-bindings"
+
+	// OwnerUIDIndex indexes generated bindings by the UID of the SubjectPermission they were generated for
+	OwnerUIDIndex = "metadata.labels.ownerUID"
+
+	// PermissionScopeCluster is used for bindings generated from Spec.ClusterPermissions
+	PermissionScopeCluster = "cluster"
+	// PermissionScopeNamespace is used for bindings generated from Spec.Permissions
+	PermissionScopeNamespace = "namespace"
+)
+
+// OwnershipLabels returns the labels stamped on a binding generated for the permission entry
+// at permissionIndex of the given scope
+func OwnershipLabels(subjectPermission *managedv1alpha1.SubjectPermission, scope string, permissionIndex int) map[string]string {
+	return map[string]string{
+		ManagedByLabel:       operatorconfig.OperatorName,
+		OwnerUIDLabel:        string(subjectPermission.UID),
+		PermissionScopeLabel: scope,
+		PermissionIndexLabel: strconv.Itoa(permissionIndex),
+	}
+}
+
+// OwnershipAnnotations returns the annotations stamped on a binding generated for subjectPermission
+func OwnershipAnnotations(subjectPermission *managedv1alpha1.SubjectPermission) map[string]string {
+	return map[string]string{
+		OwnerNamespaceAnnotation:  subjectPermission.Namespace,
+		OwnerNameAnnotation:       subjectPermission.Name,
+		OperatorVersionAnnotation: version.Version,
+	}
+}
+
+// OwnerSelector returns the labels selecting every binding generated for subjectPermission
+func OwnerSelector(subjectPermission *managedv1alpha1.SubjectPermission) map[string]string {
+	return map[string]string{
+		ManagedByLabel: operatorconfig.OperatorName,
+		OwnerUIDLabel:  string(subjectPermission.UID),
+	}
+}
+
+// IsOwnedBy checks if the object was generated by the operator for subjectPermission
+func IsOwnedBy(object metav1.Object, subjectPermission *managedv1alpha1.SubjectPermission) bool {
+	if subjectPermission.UID == "" {
+		return false
+	}
+	for key, value := range OwnerSelector(subjectPermission) {
+		if object.GetLabels()[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// IsLegacyBinding checks if a binding was generated for subjectPermission by a version of the operator that
+// named bindings clusterRoleName-subjectName and did not label them. Only unlabeled bindings of one of
+// clusterRoleNames to the single SubjectKind and SubjectName of the SubjectPermission match.
+func IsLegacyBinding(subjectPermission *managedv1alpha1.SubjectPermission, binding metav1.Object, subjects []v1.Subject, roleRef v1.RoleRef, clusterRoleNames []string) bool {
+	subjectName := subjectPermission.Spec.SubjectName
+	if subjectName == "" || binding.GetLabels()[ManagedByLabel] != "" {
+		return false
+	}
+	if roleRef.Kind != "ClusterRole" || !ContainsString(clusterRoleNames, roleRef.Name) || binding.GetName() != roleRef.Name+"-"+subjectName {
+		return false
+	}
+	return len(subjects) == 1 && subjects[0].Kind == subjectPermission.Spec.SubjectKind && subjects[0].Name == subjectName
+}
+
+// LegacyClusterRoleNames returns the ClusterRoles the legacy ClusterRoleBindings and RoleBindings of
+// subjectPermission bound, see IsLegacyBinding
+func LegacyClusterRoleNames(subjectPermission *managedv1alpha1.SubjectPermission) (clusterPermissions []string, permissions []string) {
+	for _, permission := range subjectPermission.Spec.Permissions {
+		// the ClusterRoles of Rules were never bound by legacy versions
+		if len(permission.Rules) == 0 {
+			permissions = append(permissions, permission.ClusterRoleName)
+		}
+	}
+	return subjectPermission.Spec.ClusterPermissions, permissions
+}
+
+// OwnerRequests maps an object generated by the operator to a reconcile.Request for the SubjectPermission
+// it was generated for. Objects that were not generated by the operator map to no request.
+func OwnerRequests(object metav1.Object) []reconcile.Request {
+	if object.GetLabels()[ManagedByLabel] != operatorconfig.OperatorName {
+		return nil
+	}
+	annotations := object.GetAnnotations()
+	if annotations[OwnerNameAnnotation] == "" {
+		return nil
+	}
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{
+			Namespace: annotations[OwnerNamespaceAnnotation],
+			Name:      annotations[OwnerNameAnnotation],
+		}},
+	}
+}
+
+// OwnerUIDIndexFunc returns the UID of the SubjectPermission an object generated by the operator belongs to
+func OwnerUIDIndexFunc(object runtime.Object) []string {
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return nil
+	}
+	labels := accessor.GetLabels()
+	if labels[ManagedByLabel] != operatorconfig.OperatorName || labels[OwnerUIDLabel] == "" {
+		return nil
+	}
+	return []string{labels[OwnerUIDLabel]}
+}
@@ -0,0 +1,60 @@
+package util
+
+import (
+	"testing"
+
+	managedv1alpha1 "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	v1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOwnership(t *testing.T) {
+	subjectPermission := &managedv1alpha1.SubjectPermission{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example",
+			Namespace: "example-namespace",
+			UID:       "example-uid",
+		},
+	}
+	other := subjectPermission.DeepCopy()
+	other.UID = "other-uid"
+
+	generated := &v1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      OwnershipLabels(subjectPermission, PermissionScopeNamespace, 1),
+			Annotations: OwnershipAnnotations(subjectPermission),
+		},
+	}
+	handMade := &v1.RoleBinding{}
+
+	subjectPermission.Spec.SubjectKind, subjectPermission.Spec.SubjectName = "Group", "dev"
+	legacySubjects := []v1.Subject{{Kind: "Group", Name: "dev"}}
+	legacyRoleRef := v1.RoleRef{Kind: "ClusterRole", Name: "view"}
+	legacy := &v1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "view-dev"}}
+	otherName := &v1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "view-dev-team"}}
+
+	var tests = []struct {
+		label    string
+		expected bool
+		found    bool
+	}{
+		{"generated binding owned by its SubjectPermission", true, IsOwnedBy(generated, subjectPermission)},
+		{"generated binding owned by another SubjectPermission", false, IsOwnedBy(generated, other)},
+		{"hand-made binding owned by the SubjectPermission", false, IsOwnedBy(handMade, subjectPermission)},
+		{"generated binding maps to its SubjectPermission", true, len(OwnerRequests(generated)) == 1 && OwnerRequests(generated)[0].Name == "example" && OwnerRequests(generated)[0].Namespace == "example-namespace"},
+		{"hand-made binding maps to a SubjectPermission", false, len(OwnerRequests(handMade)) > 0},
+		{"generated binding is indexed by its owner UID", true, len(OwnerUIDIndexFunc(generated)) == 1 && OwnerUIDIndexFunc(generated)[0] == "example-uid"},
+		{"hand-made binding is indexed", false, len(OwnerUIDIndexFunc(handMade)) > 0},
+		{"legacy binding of the SubjectPermission", true, IsLegacyBinding(subjectPermission, legacy, legacySubjects, legacyRoleRef, []string{"view"})},
+		{"legacy binding of a ClusterRole the SubjectPermission does not bind", false, IsLegacyBinding(subjectPermission, legacy, legacySubjects, legacyRoleRef, []string{"edit"})},
+		{"legacy binding of another subject", false, IsLegacyBinding(subjectPermission, legacy, []v1.Subject{{Kind: "User", Name: "dev"}}, legacyRoleRef, []string{"view"})},
+		{"legacy binding of several subjects", false, IsLegacyBinding(subjectPermission, legacy, append(legacySubjects, v1.Subject{Kind: "Group", Name: "ops"}), legacyRoleRef, []string{"view"})},
+		{"binding named otherwise", false, IsLegacyBinding(subjectPermission, otherName, legacySubjects, legacyRoleRef, []string{"view"})},
+		{"generated binding is legacy", false, IsLegacyBinding(subjectPermission, generated, legacySubjects, legacyRoleRef, []string{"view"})},
+	}
+	for _, test := range tests {
+		if test.expected != test.found {
+			t.Errorf("Mismatch for %s. Expected(%t), Found(%t)", test.label, test.expected, test.found)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package util
+
+import (
+	"context"
+
+	managedv1alpha1 "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	"github.com/openshift/rbac-permissions-operator/pkg/utility"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Both SubjectPermissions and ClusterSubjectPermissions are handled as a SubjectPermission, see
+// utility.SubjectPermissionForCluster. An empty namespace stands for a ClusterSubjectPermission.
+
+// GetSubjectPermission fetches the SubjectPermission, or the ClusterSubjectPermission when key has no namespace
+func GetSubjectPermission(ctx context.Context, c client.Client, key types.NamespacedName) (*managedv1alpha1.SubjectPermission, error) {
+	if key.Namespace != "" {
+		subjectPermission := &managedv1alpha1.SubjectPermission{}
+		err := c.Get(ctx, key, subjectPermission)
+		return subjectPermission, err
+	}
+
+	clusterSubjectPermission := &managedv1alpha1.ClusterSubjectPermission{}
+	err := c.Get(ctx, key, clusterSubjectPermission)
+	return utility.SubjectPermissionForCluster(clusterSubjectPermission), err
+}
+
+// ListSubjectPermissions lists every SubjectPermission and ClusterSubjectPermission matching opts
+func ListSubjectPermissions(ctx context.Context, c client.Client, opts *client.ListOptions) ([]*managedv1alpha1.SubjectPermission, error) {
+	subjectPermissionList := &managedv1alpha1.SubjectPermissionList{}
+	err := c.List(ctx, opts, subjectPermissionList)
+	if err != nil {
+		return nil, err
+	}
+	clusterSubjectPermissionList := &managedv1alpha1.ClusterSubjectPermissionList{}
+	err = c.List(ctx, opts, clusterSubjectPermissionList)
+	if err != nil {
+		return nil, err
+	}
+
+	var subjectPermissions []*managedv1alpha1.SubjectPermission
+	for i := range subjectPermissionList.Items {
+		subjectPermissions = append(subjectPermissions, &subjectPermissionList.Items[i])
+	}
+	for i := range clusterSubjectPermissionList.Items {
+		subjectPermissions = append(subjectPermissions, utility.SubjectPermissionForCluster(&clusterSubjectPermissionList.Items[i]))
+	}
+	return subjectPermissions, nil
+}
+
+// UpdateSubjectPermission writes the metadata and spec of a SubjectPermission, or of the ClusterSubjectPermission
+// it stands for, and refreshes its metadata
+func UpdateSubjectPermission(ctx context.Context, c client.Client, subjectPermission *managedv1alpha1.SubjectPermission) error {
+	if subjectPermission.Namespace != "" {
+		return c.Update(ctx, subjectPermission)
+	}
+
+	clusterSubjectPermission := utility.ClusterSubjectPermissionFor(subjectPermission)
+	err := c.Update(ctx, clusterSubjectPermission)
+	subjectPermission.ObjectMeta = clusterSubjectPermission.ObjectMeta
+	return err
+}
+
+// UpdateSubjectPermissionStatus writes the status of a SubjectPermission, or of the ClusterSubjectPermission
+// it stands for, and refreshes its metadata.
+// The status subresource ignores the spec, so the status is written again on top of the latest object
+// when a concurrent spec edit conflicts with it, instead of failing the reconcile. controller-runtime
+// has no Patch yet, a merge patch of the status can replace this once it does
+func UpdateSubjectPermissionStatus(ctx context.Context, c client.Client, subjectPermission *managedv1alpha1.SubjectPermission) error {
+	status := subjectPermission.Status.DeepCopy()
+	key := types.NamespacedName{Namespace: subjectPermission.Namespace, Name: subjectPermission.Name}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		err := updateSubjectPermissionStatus(ctx, c, subjectPermission)
+		if !errors.IsConflict(err) {
+			return err
+		}
+		latest, getErr := GetSubjectPermission(ctx, c, key)
+		if getErr != nil {
+			return getErr
+		}
+		latest.Status = *status
+		*subjectPermission = *latest
+		return err
+	})
+}
+
+// updateSubjectPermissionStatus writes the status of a SubjectPermission once
+func updateSubjectPermissionStatus(ctx context.Context, c client.Client, subjectPermission *managedv1alpha1.SubjectPermission) error {
+	if subjectPermission.Namespace != "" {
+		return c.Status().Update(ctx, subjectPermission)
+	}
+
+	clusterSubjectPermission := utility.ClusterSubjectPermissionFor(subjectPermission)
+	err := c.Status().Update(ctx, clusterSubjectPermission)
+	subjectPermission.ObjectMeta = clusterSubjectPermission.ObjectMeta
+	return err
+}
@@ -0,0 +1,59 @@
+package util
+
+import (
+	"context"
+
+	userv1 "github.com/openshift/api/user/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// UserAPIAvailable checks if the cluster serves the Groups and Users of user.openshift.io,
+// only OpenShift clusters do
+func UserAPIAvailable(mapper meta.RESTMapper) bool {
+	_, err := mapper.RESTMapping(userv1.SchemeGroupVersion.WithKind("Group").GroupKind(), userv1.SchemeGroupVersion.Version)
+	return err == nil
+}
+
+// SubjectKey returns Kind/name for a Group or User and Kind/namespace/name for a ServiceAccount,
+// which SubjectPermissions are indexed by
+func SubjectKey(subject v1.Subject) string {
+	if subject.Kind == v1.ServiceAccountKind {
+		return subject.Kind + "/" + subject.Namespace + "/" + subject.Name
+	}
+	return subject.Kind + "/" + subject.Name
+}
+
+// MissingSubjects returns the subjects that do not exist. Groups and Users are only looked up when
+// verifyUsers is set, as they are not served outside of OpenShift. ServiceAccounts are looked up in their
+// own namespace, so c must be able to read every namespace
+func MissingSubjects(ctx context.Context, c client.Client, subjects []v1.Subject, verifyUsers bool) ([]v1.Subject, error) {
+	var missing []v1.Subject
+	for _, subject := range subjects {
+		var object runtime.Object
+		switch subject.Kind {
+		case v1.GroupKind:
+			object = &userv1.Group{}
+		case v1.UserKind:
+			object = &userv1.User{}
+		case v1.ServiceAccountKind:
+			object = &corev1.ServiceAccount{}
+		}
+		if object == nil || (subject.Kind != v1.ServiceAccountKind && !verifyUsers) {
+			continue
+		}
+
+		err := c.Get(ctx, types.NamespacedName{Namespace: subject.Namespace, Name: subject.Name}, object)
+		if errors.IsNotFound(err) {
+			missing = append(missing, subject)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return missing, nil
+}
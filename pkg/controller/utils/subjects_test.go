@@ -0,0 +1,37 @@
+package util
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// TestMissingSubjects tests that ServiceAccounts are looked up in their own namespace
+// given: ServiceAccounts in tenant namespaces, and one only in the operator namespace
+// expected: only the ServiceAccounts that do not exist in their namespace are missing
+func TestMissingSubjects(t *testing.T) {
+	c := fake.NewFakeClient(
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-a", Name: "deployer"}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "openshift-rbac-permissions-operator", Name: "builder"}},
+	)
+
+	subjects := []v1.Subject{
+		{Kind: v1.ServiceAccountKind, Namespace: "tenant-a", Name: "deployer"},
+		{Kind: v1.ServiceAccountKind, Namespace: "tenant-b", Name: "deployer"},
+		{Kind: v1.ServiceAccountKind, Namespace: "tenant-a", Name: "builder"},
+		{Kind: v1.GroupKind, Name: "dev"},
+	}
+	missing, err := MissingSubjects(context.TODO(), c, subjects, false)
+	if err != nil {
+		t.Fatalf("MissingSubjects failed: %s", err)
+	}
+	expected := []v1.Subject{subjects[1], subjects[2]}
+	if !reflect.DeepEqual(missing, expected) {
+		t.Errorf("got missing %v, want %v", missing, expected)
+	}
+}
@@ -18,78 +18,105 @@ import (
 	"context"
 	"regexp"
 	"strings"
+	"sync"
 
-	operatorconfig "github.com/openshift/dedicated-admin-operator/config"
+	operatorconfig "github.com/openshift/rbac-permissions-operator/config"
+	"github.com/openshift/rbac-permissions-operator/pkg/utility"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 )
 
+const (
+	// ProtectedNamespacesKey is the key of the operator ConfigMap holding the comma separated regexes of the
+	// protected namespaces, which no SubjectPermission can bind into
+	ProtectedNamespacesKey = "project_blacklist"
+	// DefaultProtectedNamespaces are the regexes of the namespaces protected when the operator ConfigMap does not
+	// exist, which deploy/config_map.yaml ships
+	DefaultProtectedNamespaces = "^kube-.*,^openshift-.*,^logging$,^default$,^openshift$,^ops-health-monitoring$,^ops-project-operation-check$,^management-infra$"
+)
+
 var (
 	log      = logf.Log.WithName("dedicatedadmin")
 	daLogger = log.WithValues("DedicatedAdmin", "functions")
 )
 
-// GetAllowedNamespaces returns a list of all namespaces that are allowed based on the input data.  Empty string regex is treated as unset.
+// IsNamespaceAllowed checks a namespace against the allowed and denied regex.  Empty string regex is treated as unset.
+// It is kept for compatibility, the matching is implemented by utility.IsNamespaceAllowed
 func IsNamespaceAllowed(namespacesAllowedRegex string, namespacesDeniedRegex string, allowFirst bool, namespace string) bool {
-	if allowFirst && namespacesAllowedRegex != "" {
-		// check allow first
-		// NOTE if allowed regex is missing nothing is allowed
-		allowed, _ := regexp.MatchString(namespacesAllowedRegex, namespace)
-		if allowed && namespacesDeniedRegex != "" {
-			// it's allowed.  now check that it is not denied.
-			denied, _ := regexp.MatchString(namespacesDeniedRegex, namespace)
-			if denied {
-				// it's denied
-				return false
-			}
-		}
-		// it was not denied, return 'allowed' value
-		return allowed
-	} else {
-		// check deny first
-		// NOTE if deny regex is missing only the allowed regex applies
-		if namespacesDeniedRegex != "" {
-			denied, _ := regexp.MatchString(namespacesDeniedRegex, namespace)
-			if denied {
-				// it's denied
-				return false
-			}
-		}
-		// it was not denied, check if it's allowed
-		if namespacesAllowedRegex != "" {
-			allowed, _ := regexp.MatchString(namespacesAllowedRegex, namespace)
-			if allowed {
-				// it's allowed
-				return true
-			}
+	return utility.IsNamespaceAllowed(namespacesAllowedRegex, namespacesDeniedRegex, allowFirst, namespace)
+}
+
+// IsBlackListedNamespace matches a namespace against the comma separated regexes of blacklistedNamespaces.
+// It fails closed: every namespace is blacklisted by a list holding an invalid regex
+func IsBlackListedNamespace(namespace string, blacklistedNamespaces string) bool {
+	for _, pattern := range compileBlacklist(blacklistedNamespaces) {
+		if pattern == nil || pattern.MatchString(namespace) {
+			return true
 		}
 	}
-	// it was not denied or allowed (implies it was denied, default behavior)
 	return false
 }
 
-// IsBlackListedNamespace matchs a nam,espace against the blacklist
-func IsBlackListedNamespace(namespace string, blacklistedNamespaces string) bool {
+// compiledBlacklists caches the regexes compiled by compileBlacklist, by list
+var compiledBlacklists sync.Map
+
+// compileBlacklist compiles each regex of the comma separated blacklistedNamespaces once, an invalid one is nil
+func compileBlacklist(blacklistedNamespaces string) []*regexp.Regexp {
+	if patterns, ok := compiledBlacklists.Load(blacklistedNamespaces); ok {
+		return patterns.([]*regexp.Regexp)
+	}
+
+	var patterns []*regexp.Regexp
 	for _, blackListedNS := range strings.Split(blacklistedNamespaces, ",") {
-		matched, _ := regexp.MatchString(blackListedNS, namespace)
-		if matched {
-			return true
+		pattern, err := regexp.Compile(blackListedNS)
+		if err != nil {
+			daLogger.Error(err, "Invalid protected namespaces regex, every namespace is protected", "Regex", blackListedNS)
 		}
+		patterns = append(patterns, pattern)
 	}
-	return false
+	compiledBlacklists.Store(blacklistedNamespaces, patterns)
+	return patterns
 }
 
 // GetOperatorConfig gets the operator's configuration from a config map
 func GetOperatorConfig(ctx context.Context, k8sClient client.Client) (*corev1.ConfigMap, error) {
-	return &corev1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      operatorconfig.OperatorConfigMapName,
-			Namespace: operatorconfig.OperatorNamespace,
-		},
-		Data: map[string]string{
-			"project_blacklist": "^kube-.*,^openshift-.*,^logging$,^default$,^openshift$,^ops-health-monitoring$,^ops-project-operation-check$,^management-infra$",
-		},
-	}, nil
+	configMap := &corev1.ConfigMap{}
+	err := k8sClient.Get(ctx, types.NamespacedName{Namespace: operatorconfig.OperatorNamespace, Name: operatorconfig.OperatorConfigMapName}, configMap)
+	return configMap, err
+}
+
+// GetProtectedNamespaces gets the regexes of the protected namespaces from the operator's config map,
+// comma separated for IsBlackListedNamespace. The DefaultProtectedNamespaces are protected when the config
+// map does not exist
+func GetProtectedNamespaces(ctx context.Context, k8sClient client.Client) (string, error) {
+	configMap, err := GetOperatorConfig(ctx, k8sClient)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			daLogger.Info("Operator config map not found, protecting the default namespaces")
+			return DefaultProtectedNamespaces, nil
+		}
+		return "", err
+	}
+	return ProtectedNamespaces(configMap), nil
+}
+
+// ProtectedNamespaces returns the regexes of the protected namespaces of the operator's config map. Blank
+// entries are dropped, as IsBlackListedNamespace would match every namespace against them
+func ProtectedNamespaces(configMap *corev1.ConfigMap) string {
+	var patterns []string
+	for _, pattern := range strings.Split(configMap.Data[ProtectedNamespacesKey], ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return strings.Join(patterns, ",")
+}
+
+// IsOperatorConfig checks if an object is the operator's config map
+func IsOperatorConfig(object metav1.Object) bool {
+	return object.GetNamespace() == operatorconfig.OperatorNamespace && object.GetName() == operatorconfig.OperatorConfigMapName
 }
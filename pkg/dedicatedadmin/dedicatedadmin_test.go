@@ -15,7 +15,14 @@
 package dedicatedadmin
 
 import (
+	"context"
 	"testing"
+
+	operatorconfig "github.com/openshift/rbac-permissions-operator/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
 // TestBlacklist exercises the comma-separating in IsBlackListedNamespace
@@ -35,6 +42,8 @@ func TestBlacklist(t *testing.T) {
 		{"^kube-(system|default|public)", "kube-default-baz", true},
 		{"^(kube-(system|default|foo)|openshift-.*).*$", "kube-default-baz", true},
 		{"^(kube-(system|default|foo)|openshift-.*).*$", "kube-baz", false},
+		// an invalid regex protects every namespace
+		{"^kube-.*,^(openshift", "customer", true},
 	}
 	for _, test := range tests {
 		if IsBlackListedNamespace(test.challenge, test.configmapstring) != test.valid {
@@ -43,6 +52,38 @@ func TestBlacklist(t *testing.T) {
 	}
 }
 
+// TestGetProtectedNamespaces reads the protected namespaces from the operator's config map,
+// blank entries are dropped and a missing config map protects the default namespaces
+func TestGetProtectedNamespaces(t *testing.T) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: operatorconfig.OperatorConfigMapName, Namespace: operatorconfig.OperatorNamespace},
+		Data:       map[string]string{ProtectedNamespacesKey: " ^kube-.*,,^default$ ,"},
+	}
+	other := configMap.DeepCopy()
+	other.Namespace = "other"
+
+	var tests = []struct {
+		objects  []runtime.Object
+		expected string
+	}{
+		{[]runtime.Object{configMap}, "^kube-.*,^default$"},
+		{[]runtime.Object{other}, DefaultProtectedNamespaces},
+		{nil, DefaultProtectedNamespaces},
+	}
+	for _, test := range tests {
+		protected, err := GetProtectedNamespaces(context.TODO(), fake.NewFakeClient(test.objects...))
+		if err != nil {
+			t.Fatalf("GetProtectedNamespaces failed: %s", err)
+		}
+		if protected != test.expected {
+			t.Errorf("got protected namespaces %q, want %q", protected, test.expected)
+		}
+		if protected != "" && (!IsBlackListedNamespace("kube-system", protected) || IsBlackListedNamespace("customer", protected)) {
+			t.Errorf("protected namespaces %q do not match as expected", protected)
+		}
+	}
+}
+
 func TestIsNamespaceAllowed(t *testing.T) {
 	var tests = []struct {
 		namespacesAllowedRegex string
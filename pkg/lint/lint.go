@@ -0,0 +1,174 @@
+// Copyright 2019 RedHat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lint checks SubjectPermissions offline, the way the admission webhook does, and simulates the
+// bindings the operator would create for them in a given set of Namespaces.
+package lint
+
+import (
+	"fmt"
+	"time"
+
+	managedv1alpha1 "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	"github.com/openshift/rbac-permissions-operator/pkg/planner"
+	"github.com/openshift/rbac-permissions-operator/pkg/policy"
+	"github.com/openshift/rbac-permissions-operator/pkg/validation"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// Input is the state SubjectPermissions are checked and simulated against
+type Input struct {
+	// SubjectPermissions to check, see utility.SubjectPermissionForCluster for ClusterSubjectPermissions
+	SubjectPermissions []*managedv1alpha1.SubjectPermission
+	// ClusterRoles existing on the cluster
+	ClusterRoles []rbacv1.ClusterRole
+	// Namespaces the Permissions are simulated in
+	Namespaces []corev1.Namespace
+	// Now is the time the Validity windows are evaluated at
+	Now time.Time
+	// ProtectedNamespaces are the comma separated regexes of the Namespaces nothing is bound into
+	ProtectedNamespaces string
+	// Policy restricting the ClusterRoles that are bound, nil restricts nothing
+	Policy *policy.Policy
+}
+
+// Report is the outcome of checking SubjectPermissions
+type Report struct {
+	SubjectPermissions []SubjectPermissionReport `json:"subjectPermissions"`
+	// ErrorCount is the number of errors found in all the SubjectPermissions
+	ErrorCount int `json:"errorCount"`
+	// WarningCount is the number of warnings found in all the SubjectPermissions
+	WarningCount int `json:"warningCount"`
+}
+
+// SubjectPermissionReport is the outcome of checking one SubjectPermission
+type SubjectPermissionReport struct {
+	// Kind is SubjectPermission or ClusterSubjectPermission
+	Kind string `json:"kind"`
+	// Namespace of the SubjectPermission, empty for a ClusterSubjectPermission
+	Namespace string `json:"namespace,omitempty"`
+	// Name of the SubjectPermission
+	Name string `json:"name"`
+	// Errors the admission webhook would reject the SubjectPermission for, nothing is simulated when there are some
+	Errors []string `json:"errors,omitempty"`
+	// Warnings about entries that would not be granted as written
+	Warnings []string `json:"warnings,omitempty"`
+	// ClusterRoles that would be generated for the Rules of the Permissions
+	GeneratedClusterRoles []string `json:"generatedClusterRoles,omitempty"`
+	// ClusterPermissions simulates the ClusterRoleBinding of each entry of the ClusterPermissions
+	ClusterPermissions []ClusterPermissionReport `json:"clusterPermissions,omitempty"`
+	// Permissions simulates the RoleBindings of each Permission
+	Permissions []PermissionReport `json:"permissions,omitempty"`
+}
+
+// ClusterPermissionReport simulates one entry of the ClusterPermissions
+type ClusterPermissionReport struct {
+	ClusterRoleName string `json:"clusterRoleName"`
+	// BindingName of the ClusterRoleBinding that would be created, empty when it is not granted
+	BindingName string `json:"bindingName,omitempty"`
+	// Reason it is not granted
+	Reason string `json:"reason,omitempty"`
+}
+
+// PermissionReport simulates one Permission
+type PermissionReport struct {
+	ClusterRoleName string `json:"clusterRoleName"`
+	// BindingName of the RoleBinding that would be created in each of MatchedNamespaces
+	BindingName string `json:"bindingName,omitempty"`
+	// Reason it is not granted in any namespace
+	Reason string `json:"reason,omitempty"`
+	// MatchedNamespaces are all the Namespaces the Permission binds into
+	MatchedNamespaces []string `json:"matchedNamespaces,omitempty"`
+	// SkippedNamespaces are all the Namespaces the Permission does not bind into, with the reason
+	SkippedNamespaces []managedv1alpha1.SkippedNamespace `json:"skippedNamespaces,omitempty"`
+}
+
+// Run checks and simulates every SubjectPermission of the Input
+func Run(input Input) *Report {
+	report := &Report{SubjectPermissions: []SubjectPermissionReport{}}
+	for _, subjectPermission := range input.SubjectPermissions {
+		subjectPermissionReport := check(input, subjectPermission)
+		report.ErrorCount += len(subjectPermissionReport.Errors)
+		report.WarningCount += len(subjectPermissionReport.Warnings)
+		report.SubjectPermissions = append(report.SubjectPermissions, subjectPermissionReport)
+	}
+	return report
+}
+
+// check checks and simulates one SubjectPermission
+func check(input Input, subjectPermission *managedv1alpha1.SubjectPermission) SubjectPermissionReport {
+	report := SubjectPermissionReport{Kind: "SubjectPermission", Namespace: subjectPermission.Namespace, Name: subjectPermission.Name}
+	if subjectPermission.Namespace == "" {
+		report.Kind = "ClusterSubjectPermission"
+	}
+
+	for _, err := range validation.ValidateAdmission(subjectPermission, input.Policy, input.ClusterRoles) {
+		report.Errors = append(report.Errors, err.Error())
+	}
+	// the operator does not act on a SubjectPermission the webhook rejects
+	if len(validation.ValidateSubjectPermission(subjectPermission)) > 0 {
+		return report
+	}
+
+	plan := planner.New(planner.Input{
+		SubjectPermission:   subjectPermission,
+		Namespaces:          input.Namespaces,
+		ClusterRoles:        input.ClusterRoles,
+		Now:                 input.Now,
+		ProtectedNamespaces: input.ProtectedNamespaces,
+		Policy:              input.Policy,
+	})
+	for _, clusterRoleName := range plan.MissingClusterRoles() {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("ClusterRole %s does not exist, it is bound once it is created", clusterRoleName))
+	}
+	for _, clusterRole := range plan.DesiredClusterRoles {
+		report.GeneratedClusterRoles = append(report.GeneratedClusterRoles, clusterRole.Name)
+	}
+	for _, inventory := range plan.ClusterPermissions {
+		report.ClusterPermissions = append(report.ClusterPermissions, ClusterPermissionReport{
+			ClusterRoleName: inventory.ClusterRoleName,
+			BindingName:     inventory.BindingName,
+			Reason:          inventory.Reason,
+		})
+	}
+
+	// the inventory of the planner only keeps a sample of the namespaces, plan each namespace on its own
+	// to list all of them, the way the namespace controller does
+	for i, inventory := range plan.Permissions {
+		permissionReport := PermissionReport{ClusterRoleName: inventory.ClusterRoleName, BindingName: inventory.BindingName, Reason: inventory.Reason}
+		if inventory.Reason == "" {
+			for _, namespace := range input.Namespaces {
+				namespacePlan := planner.ForRoleBindings(planner.Input{
+					SubjectPermission:   subjectPermission,
+					Namespaces:          []corev1.Namespace{namespace},
+					ClusterRoles:        input.ClusterRoles,
+					Now:                 input.Now,
+					ProtectedNamespaces: input.ProtectedNamespaces,
+					Policy:              input.Policy,
+				})
+				namespaceInventory := namespacePlan.Permissions[i]
+				if namespaceInventory.MatchedNamespaceCount > 0 {
+					permissionReport.MatchedNamespaces = append(permissionReport.MatchedNamespaces, namespace.Name)
+				}
+				permissionReport.SkippedNamespaces = append(permissionReport.SkippedNamespaces, namespaceInventory.SkippedNamespaces...)
+			}
+			if len(permissionReport.MatchedNamespaces) == 0 && len(input.Namespaces) > 0 {
+				report.Warnings = append(report.Warnings, fmt.Sprintf("spec.permissions[%d]: ClusterRole %s is not bound in any namespace", i, inventory.ClusterRoleName))
+			}
+		}
+		report.Permissions = append(report.Permissions, permissionReport)
+	}
+	return report
+}
@@ -0,0 +1,104 @@
+package lint
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	managedv1alpha1 "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	"github.com/openshift/rbac-permissions-operator/pkg/planner"
+	"github.com/openshift/rbac-permissions-operator/pkg/policy"
+	"github.com/openshift/rbac-permissions-operator/pkg/utility"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func namespaces(names ...string) []corev1.Namespace {
+	var namespaces []corev1.Namespace
+	for _, name := range names {
+		namespaces = append(namespaces, corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	return namespaces
+}
+
+// TestRun tests checking and simulating SubjectPermissions
+// given: a valid SubjectPermission with a protected namespace and a missing ClusterRole, an invalid one
+// and one binding a restricted ClusterRole
+// expected: the matched namespaces are all listed, the missing ClusterRole and the unmatched Permission are
+// warnings, the invalid spec and the restricted ClusterRole are errors
+func TestRun(t *testing.T) {
+	operatorNamespace := "openshift-rbac-permissions-operator"
+	dev := &managedv1alpha1.SubjectPermission{
+		ObjectMeta: metav1.ObjectMeta{Namespace: operatorNamespace, Name: "dev"},
+		Spec: managedv1alpha1.SubjectPermissionSpec{
+			Subjects:           []rbacv1.Subject{{Kind: "Group", Name: "dev"}},
+			ClusterPermissions: []string{"view"},
+			Permissions: []managedv1alpha1.Permission{
+				{ClusterRoleName: "edit", NamespacesAllowedRegex: "^dev-.*", NamespacesDeniedRegex: "-three$"},
+				{ClusterRoleName: "missing", NamespacesAllowedRegex: "^prod-.*"},
+			},
+		},
+	}
+	invalid := &managedv1alpha1.SubjectPermission{
+		ObjectMeta: metav1.ObjectMeta{Namespace: operatorNamespace, Name: "invalid"},
+		Spec: managedv1alpha1.SubjectPermissionSpec{
+			Subjects:    []rbacv1.Subject{{Kind: "Robot", Name: "r2d2"}},
+			Permissions: []managedv1alpha1.Permission{{ClusterRoleName: "edit", NamespacesAllowedRegex: "("}},
+		},
+	}
+	admin := utility.SubjectPermissionForCluster(&managedv1alpha1.ClusterSubjectPermission{
+		ObjectMeta: metav1.ObjectMeta{Name: "admin"},
+		Spec: managedv1alpha1.SubjectPermissionSpec{
+			Subjects:           []rbacv1.Subject{{Kind: "Group", Name: "dev"}},
+			ClusterPermissions: []string{"cluster-admin"},
+		},
+	})
+
+	report := Run(Input{
+		SubjectPermissions: []*managedv1alpha1.SubjectPermission{dev, invalid, admin},
+		ClusterRoles: []rbacv1.ClusterRole{
+			{ObjectMeta: metav1.ObjectMeta{Name: "view"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "edit"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin"}},
+		},
+		Namespaces:          namespaces("dev-one", "dev-two", "dev-three", "dev-kube", "other"),
+		Now:                 time.Now(),
+		ProtectedNamespaces: "-kube$",
+		Policy:              &policy.Policy{RestrictedClusterRoles: []string{"^cluster-admin$"}},
+	})
+
+	if report.ErrorCount != 3 || report.WarningCount != 2 {
+		t.Errorf("got %d errors and %d warnings, want 3 and 2", report.ErrorCount, report.WarningCount)
+	}
+
+	devReport := report.SubjectPermissions[0]
+	if len(devReport.Errors) != 0 || len(devReport.Warnings) != 2 {
+		t.Errorf("dev: got errors %v and warnings %v, want 2 warnings", devReport.Errors, devReport.Warnings)
+	}
+	if len(devReport.ClusterPermissions) != 1 || devReport.ClusterPermissions[0].BindingName == "" {
+		t.Errorf("dev: got ClusterPermissions %v, want view bound", devReport.ClusterPermissions)
+	}
+	edit := devReport.Permissions[0]
+	if !reflect.DeepEqual(edit.MatchedNamespaces, []string{"dev-one", "dev-two"}) || edit.BindingName == "" {
+		t.Errorf("dev: got matched namespaces %v with binding %q, want dev-one and dev-two", edit.MatchedNamespaces, edit.BindingName)
+	}
+	expectedSkipped := []managedv1alpha1.SkippedNamespace{
+		{Name: "dev-three", Reason: utility.NamespaceDenied},
+		{Name: "dev-kube", Reason: planner.ReasonProtected},
+		{Name: "other", Reason: utility.NamespaceNotAllowed},
+	}
+	if !reflect.DeepEqual(edit.SkippedNamespaces, expectedSkipped) {
+		t.Errorf("dev: got skipped namespaces %v, want %v", edit.SkippedNamespaces, expectedSkipped)
+	}
+
+	invalidReport := report.SubjectPermissions[1]
+	if len(invalidReport.Errors) != 2 || invalidReport.Permissions != nil {
+		t.Errorf("invalid: got errors %v and Permissions %v, want 2 errors and nothing simulated", invalidReport.Errors, invalidReport.Permissions)
+	}
+
+	adminReport := report.SubjectPermissions[2]
+	if adminReport.Kind != "ClusterSubjectPermission" || len(adminReport.Errors) != 1 || adminReport.ClusterPermissions[0].Reason != planner.ReasonRestricted {
+		t.Errorf("admin: got %s with errors %v and ClusterPermissions %v, want the restricted cluster-admin", adminReport.Kind, adminReport.Errors, adminReport.ClusterPermissions)
+	}
+}
@@ -2,8 +2,12 @@ package localmetrics
 
 import (
 	"fmt"
+	"strings"
 
 	managedv1alpha1 "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	controllerutil "github.com/openshift/rbac-permissions-operator/pkg/controller/utils"
+	"github.com/openshift/rbac-permissions-operator/pkg/utility"
+	corev1 "k8s.io/api/core/v1"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -42,11 +46,58 @@ var (
 		"stage",
 	})
 
+	// RBACNamespacePermissionMatches for the number of namespaces matched by each per-namespace permission
+	RBACNamespacePermissionMatches = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rbac_permissions_operator_namespace_permission_matches",
+		Help: "Number of namespaces matched by a per-namespace permission",
+	}, []string{
+		"subject_permission_name",
+		"cluster_role_name",
+	})
+
+	// RBACBindingDrift for generated bindings restored after drifting
+	RBACBindingDrift = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rbac_permissions_operator_binding_drift_total",
+		Help: "Generated bindings restored after drifting from the desired state",
+	}, []string{
+		"subject_permission_name",
+		"binding_kind",
+		"drift",
+	})
+
+	// RBACPendingChanges for the changes between the planned bindings and the ones on the cluster
+	RBACPendingChanges = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rbac_permissions_operator_pending_changes",
+		Help: "Changes needed to bring the bindings and generated ClusterRoles on the cluster to the planned state, which stay pending in Audit mode",
+	}, []string{
+		"subject_permission_name",
+		"mode",
+		"kind",
+		"action",
+	})
+
+	// RBACSuspended for SubjectPermissions whose bindings are left alone
+	RBACSuspended = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rbac_permissions_operator_suspended",
+		Help: "Set to 1 while a SubjectPermission is suspended and its bindings are neither created, repaired nor revoked",
+	}, []string{
+		"subject_permission_name",
+	})
+
 	// MetricsList all metrics exported by this package
 	MetricsList = []prometheus.Collector{
 		RBACClusterwidePermissions,
 		RBACNamespacePermissions,
+		RBACNamespacePermissionMatches,
+		RBACBindingDrift,
+		RBACPendingChanges,
+		RBACSuspended,
 	}
+
+	// pendingChangeKinds and pendingChangeActions are the label values of RBACPendingChanges
+	pendingChangeKinds   = []string{"ClusterRole", "ClusterRoleBinding", "RoleBinding"}
+	pendingChangeActions = []string{"Create", "Update", "Delete"}
+	modes                = []managedv1alpha1.Mode{managedv1alpha1.ModeEnforce, managedv1alpha1.ModeAudit}
 )
 
 // DeletePrometheusMetric - Helper function to delete both clusterwide and
@@ -54,6 +105,9 @@ var (
 func DeletePrometheusMetric(gp *managedv1alpha1.SubjectPermission) {
 	deleteRBACClusterPermissionMetric(gp)
 	deleteRBACNamespacePermissionMetric(gp)
+	deleteRBACNamespacePermissionMatchesMetric(gp)
+	deleteRBACPendingChangesMetric(gp)
+	RBACSuspended.DeleteLabelValues(gp.ObjectMeta.GetName())
 }
 
 // AddPrometheusMetric - Helper function to add both clusterwide and namespace
@@ -63,12 +117,90 @@ func AddPrometheusMetric(gp *managedv1alpha1.SubjectPermission) {
 	addRBACNamespacePermissionMetric(gp)
 }
 
+// SetNamespaceMatchesMetric - Helper function to export the number of namespaces
+// matched by each per-namespace permission, see utility.IsNamespaceMatched.
+// A SubjectPermission restricted to its own namespace matches at most that one
+func SetNamespaceMatchesMetric(gp *managedv1alpha1.SubjectPermission, namespaces []corev1.Namespace) {
+	if utility.IsNamespaceRestricted(gp) {
+		var own []corev1.Namespace
+		for _, namespace := range namespaces {
+			if namespace.Name == gp.Namespace {
+				own = append(own, namespace)
+			}
+		}
+		namespaces = own
+	}
+	for i, permission := range gp.Spec.Permissions {
+		matched := utility.MatchedNamespaces(permission, namespaces)
+		RBACNamespacePermissionMatches.With(prometheus.Labels{
+			"subject_permission_name": gp.ObjectMeta.GetName(),
+			"cluster_role_name":       controllerutil.PermissionClusterRoleName(gp, i),
+		}).Set(float64(len(matched)))
+	}
+}
+
+// SetPendingChangesMetric - Helper function to export the number of changes
+// of each kind and action still pending for a SubjectPermission. In Enforce
+// mode they are the changes that failed to apply
+func SetPendingChangesMetric(gp *managedv1alpha1.SubjectPermission, changes []managedv1alpha1.PlannedChange) {
+	mode := gp.Spec.Mode
+	if mode == "" {
+		mode = managedv1alpha1.ModeEnforce
+	}
+	for _, m := range modes {
+		if m != mode {
+			deletePendingChangesMetric(gp, m)
+		}
+	}
+
+	for _, kind := range pendingChangeKinds {
+		for _, action := range pendingChangeActions {
+			count := 0
+			for _, change := range changes {
+				if change.Kind == kind && change.Action == action {
+					count++
+				}
+			}
+			RBACPendingChanges.With(prometheus.Labels{
+				"subject_permission_name": gp.ObjectMeta.GetName(),
+				"mode":                    string(mode),
+				"kind":                    kind,
+				"action":                  action,
+			}).Set(float64(count))
+		}
+	}
+}
+
+// SetSuspendedMetric - Helper function to export whether a SubjectPermission
+// is suspended
+func SetSuspendedMetric(gp *managedv1alpha1.SubjectPermission) {
+	suspended := 0.0
+	if gp.Spec.Suspend {
+		suspended = 1.0
+	}
+	RBACSuspended.WithLabelValues(gp.ObjectMeta.GetName()).Set(suspended)
+}
+
+// AddBindingDriftMetric - Helper function to count a generated binding of
+// bindingKind that drifted. drift is either "subjects" or "roleRef"
+func AddBindingDriftMetric(gp *managedv1alpha1.SubjectPermission, bindingKind string, drift string) {
+	RBACBindingDrift.With(prometheus.Labels{
+		"subject_permission_name": gp.ObjectMeta.GetName(),
+		"binding_kind":            bindingKind,
+		"drift":                   drift,
+	}).Inc()
+}
+
 // addRBACClusterPermissionMetric - add a SubjectPermission to the exported data
-// Iterates through the ClusterPermissions
+// Iterates through the ClusterPermissions, which are not granted for a
+// SubjectPermission restricted to its own namespace
 func addRBACClusterPermissionMetric(gp *managedv1alpha1.SubjectPermission) {
+	if utility.IsNamespaceRestricted(gp) {
+		return
+	}
 	for _, clusterPermissionName := range gp.Spec.ClusterPermissions {
 		RBACClusterwidePermissions.With(prometheus.Labels{
-			"subject_name":            gp.Spec.SubjectName,
+			"subject_name":            subjectName(gp),
 			"subject_permission_name": gp.ObjectMeta.GetName(),
 			"cluster_permission_name": clusterPermissionName,
 			"state":                   "1",
@@ -80,9 +212,12 @@ func addRBACClusterPermissionMetric(gp *managedv1alpha1.SubjectPermission) {
 // exported Prometheus data. Iterates through al the ClusterPermissions
 func deleteRBACClusterPermissionMetric(gp *managedv1alpha1.SubjectPermission) {
 	var r bool
+	if utility.IsNamespaceRestricted(gp) {
+		return
+	}
 	for _, clusterPermissionName := range gp.Spec.ClusterPermissions {
 		r = RBACClusterwidePermissions.DeleteLabelValues(
-			gp.Spec.SubjectName,
+			subjectName(gp),
 			gp.ObjectMeta.GetName(),
 			clusterPermissionName,
 			"1",
@@ -90,7 +225,7 @@ func deleteRBACClusterPermissionMetric(gp *managedv1alpha1.SubjectPermission) {
 		// It's possible that we weren't able to delete the metric, so let's log a message to that effect.
 		if !r {
 			log.Info(fmt.Sprintf("Failed to delete GaugeVec labels: subject_name='%s', subject_permission_name='%s', cluster_permission='%s', state='1'",
-				gp.Spec.SubjectName, gp.ObjectMeta.GetName(), clusterPermissionName))
+				subjectName(gp), gp.ObjectMeta.GetName(), clusterPermissionName))
 		}
 	}
 }
@@ -99,15 +234,15 @@ func deleteRBACClusterPermissionMetric(gp *managedv1alpha1.SubjectPermission) {
 // Iterates through the ClusterPermissions
 func addRBACNamespacePermissionMetric(gp *managedv1alpha1.SubjectPermission) {
 
-	for _, permission := range gp.Spec.Permissions {
+	for i, permission := range gp.Spec.Permissions {
 		RBACNamespacePermissions.With(prometheus.Labels{
-			"subject_name":            gp.Spec.SubjectName,
+			"subject_name":            subjectName(gp),
 			"subject_permission_name": gp.ObjectMeta.GetName(),
-			"cluster_role_name":       permission.ClusterRoleName,
+			"permission_name":         controllerutil.PermissionClusterRoleName(gp, i),
 			"namespace_allow":         permission.NamespacesAllowedRegex,
 			"namespace_deny":          permission.NamespacesDeniedRegex,
 			"allow_first":             allowFirstToString(permission.AllowFirst),
-			"state":                   "1",
+			"stage":                   "1",
 		}).Set(1.0)
 	}
 }
@@ -117,11 +252,12 @@ func addRBACNamespacePermissionMetric(gp *managedv1alpha1.SubjectPermission) {
 func deleteRBACNamespacePermissionMetric(gp *managedv1alpha1.SubjectPermission) {
 	var r bool
 
-	for _, permission := range gp.Spec.Permissions {
+	for i, permission := range gp.Spec.Permissions {
+		clusterRoleName := controllerutil.PermissionClusterRoleName(gp, i)
 		r = RBACNamespacePermissions.DeleteLabelValues(
-			gp.Spec.SubjectName,
+			subjectName(gp),
 			gp.ObjectMeta.GetName(),
-			permission.ClusterRoleName,
+			clusterRoleName,
 			permission.NamespacesAllowedRegex,
 			permission.NamespacesDeniedRegex,
 			allowFirstToString(permission.AllowFirst),
@@ -129,12 +265,48 @@ func deleteRBACNamespacePermissionMetric(gp *managedv1alpha1.SubjectPermission)
 		)
 		// It's possible that we weren't able to delete the metric, so let's log a message to that effect.
 		if !r {
-			log.Info(fmt.Sprintf("Failed to delete GaugeVec labels: subject_name='%s', subject_permission_name='%s', cluster_permission='%s', state='1'",
-				gp.Spec.SubjectName, gp.ObjectMeta.GetName(), permission.ClusterRoleName))
+			log.Info(fmt.Sprintf("Failed to delete GaugeVec labels: subject_name='%s', subject_permission_name='%s', permission_name='%s', stage='1'",
+				subjectName(gp), gp.ObjectMeta.GetName(), clusterRoleName))
 		}
 	}
 }
 
+// deleteRBACNamespacePermissionMatchesMetric - delete the matched namespaces
+// of a SubjectPermission from the exported Prometheus data
+func deleteRBACNamespacePermissionMatchesMetric(gp *managedv1alpha1.SubjectPermission) {
+	for i := range gp.Spec.Permissions {
+		RBACNamespacePermissionMatches.DeleteLabelValues(gp.ObjectMeta.GetName(), controllerutil.PermissionClusterRoleName(gp, i))
+	}
+}
+
+// deleteRBACPendingChangesMetric - delete the pending changes of a
+// SubjectPermission from the exported Prometheus data
+func deleteRBACPendingChangesMetric(gp *managedv1alpha1.SubjectPermission) {
+	for _, mode := range modes {
+		deletePendingChangesMetric(gp, mode)
+	}
+}
+
+// deletePendingChangesMetric - delete the pending changes of a
+// SubjectPermission in mode from the exported Prometheus data
+func deletePendingChangesMetric(gp *managedv1alpha1.SubjectPermission, mode managedv1alpha1.Mode) {
+	for _, kind := range pendingChangeKinds {
+		for _, action := range pendingChangeActions {
+			RBACPendingChanges.DeleteLabelValues(gp.ObjectMeta.GetName(), string(mode), kind, action)
+		}
+	}
+}
+
+// subjectName returns the names of the Subjects of a SubjectPermission, comma
+// separated, for the subject_name label
+func subjectName(gp *managedv1alpha1.SubjectPermission) string {
+	var names []string
+	for _, subject := range utility.SubjectsForSubjectPermission(gp) {
+		names = append(names, subject.Name)
+	}
+	return strings.Join(names, ",")
+}
+
 // allowFirstToString translates the boolean value to a "1" or "0" for the
 // Prometheus metric
 func allowFirstToString(a bool) string {
@@ -2,6 +2,10 @@ package localmetrics
 
 import (
 	"testing"
+
+	managedv1alpha1 "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestBoolToString(t *testing.T) {
@@ -19,3 +23,75 @@ func TestBoolToString(t *testing.T) {
 		}
 	}
 }
+
+func TestSetPendingChangesMetric(t *testing.T) {
+	gp := &managedv1alpha1.SubjectPermission{
+		ObjectMeta: metav1.ObjectMeta{Name: "example"},
+		Spec:       managedv1alpha1.SubjectPermissionSpec{Mode: managedv1alpha1.ModeAudit},
+	}
+	SetPendingChangesMetric(gp, []managedv1alpha1.PlannedChange{
+		{Action: "Create", Kind: "RoleBinding", Namespace: "a", Name: "example"},
+		{Action: "Create", Kind: "RoleBinding", Namespace: "b", Name: "example"},
+		{Action: "Delete", Kind: "ClusterRoleBinding", Name: "example"},
+	})
+
+	tests := []struct {
+		mode     string
+		kind     string
+		action   string
+		expected float64
+	}{
+		{"Audit", "RoleBinding", "Create", 2},
+		{"Audit", "ClusterRoleBinding", "Delete", 1},
+		{"Audit", "RoleBinding", "Delete", 0},
+	}
+	for _, test := range tests {
+		if r := testutil.ToFloat64(RBACPendingChanges.WithLabelValues("example", test.mode, test.kind, test.action)); r != test.expected {
+			t.Errorf("Expected %v pending %s of %s in %s mode, but got %v\n", test.expected, test.action, test.kind, test.mode, r)
+		}
+	}
+
+	// switching to Enforce drops the Audit series
+	gp.Spec.Mode = managedv1alpha1.ModeEnforce
+	SetPendingChangesMetric(gp, nil)
+	if RBACPendingChanges.DeleteLabelValues("example", "Audit", "RoleBinding", "Create") {
+		t.Errorf("Expected the Audit series to be deleted in Enforce mode\n")
+	}
+	DeletePrometheusMetric(gp)
+}
+
+func TestSetSuspendedMetric(t *testing.T) {
+	gp := &managedv1alpha1.SubjectPermission{
+		ObjectMeta: metav1.ObjectMeta{Name: "example"},
+		Spec:       managedv1alpha1.SubjectPermissionSpec{Suspend: true},
+	}
+
+	for _, expected := range []float64{1, 0} {
+		SetSuspendedMetric(gp)
+		if r := testutil.ToFloat64(RBACSuspended.WithLabelValues("example")); r != expected {
+			t.Errorf("Expected %v with suspend %t, but got %v\n", expected, gp.Spec.Suspend, r)
+		}
+		gp.Spec.Suspend = false
+	}
+	DeletePrometheusMetric(gp)
+}
+
+func TestAddPrometheusMetric(t *testing.T) {
+	gp := &managedv1alpha1.SubjectPermission{
+		ObjectMeta: metav1.ObjectMeta{Name: "example"},
+		Spec: managedv1alpha1.SubjectPermissionSpec{
+			SubjectName:        "dev",
+			ClusterPermissions: []string{"view"},
+			Permissions:        []managedv1alpha1.Permission{{ClusterRoleName: "edit", NamespacesAllowedRegex: "^dev-.*"}},
+		},
+	}
+
+	AddPrometheusMetric(gp)
+	if r := testutil.ToFloat64(RBACClusterwidePermissions.WithLabelValues("dev", "example", "view", "1")); r != 1 {
+		t.Errorf("Expected the ClusterPermission to be exported, but got %v\n", r)
+	}
+	if r := testutil.ToFloat64(RBACNamespacePermissions.WithLabelValues("dev", "example", "edit", "^dev-.*", "", "0", "1")); r != 1 {
+		t.Errorf("Expected the Permission to be exported, but got %v\n", r)
+	}
+	DeletePrometheusMetric(gp)
+}
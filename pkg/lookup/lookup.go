@@ -0,0 +1,205 @@
+// Copyright 2019 RedHat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lookup answers why a Subject has access to a Namespace: it lists the SubjectPermissions, and their
+// entries, binding the Subject there and explains the decision taken for each. The decisions are the ones of
+// the planner, so the answer matches the bindings the operator creates.
+package lookup
+
+import (
+	"fmt"
+	"time"
+
+	managedv1alpha1 "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	controllerutil "github.com/openshift/rbac-permissions-operator/pkg/controller/utils"
+	"github.com/openshift/rbac-permissions-operator/pkg/planner"
+	"github.com/openshift/rbac-permissions-operator/pkg/policy"
+	"github.com/openshift/rbac-permissions-operator/pkg/utility"
+	"github.com/openshift/rbac-permissions-operator/pkg/validation"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// Input is the state a lookup is answered from
+type Input struct {
+	// Subject whose access is looked up, APIGroup is ignored
+	Subject rbacv1.Subject
+	// Namespace the access is looked up in, its labels are matched against the NamespaceSelectors
+	Namespace corev1.Namespace
+	// SubjectPermissions to look through, see utility.SubjectPermissionForCluster for ClusterSubjectPermissions
+	SubjectPermissions []*managedv1alpha1.SubjectPermission
+	// ClusterRoles existing on the cluster, nil when they are not known and ClusterRoles are not reported missing
+	ClusterRoles []rbacv1.ClusterRole
+	// Now is the time the Validity windows are evaluated at
+	Now time.Time
+	// ProtectedNamespaces are the comma separated regexes of the Namespaces nothing is bound into
+	ProtectedNamespaces string
+	// Policy restricting the ClusterRoles that are bound, nil restricts nothing
+	Policy *policy.Policy
+}
+
+// Entry is the decision taken for one ClusterPermission or Permission of a SubjectPermission naming the Subject
+type Entry struct {
+	// Kind is SubjectPermission or ClusterSubjectPermission
+	Kind string `json:"kind"`
+	// Namespace of the SubjectPermission, empty for a ClusterSubjectPermission
+	Namespace string `json:"namespace,omitempty"`
+	// Name of the SubjectPermission
+	Name string `json:"name"`
+	// Field of the entry in the spec, such as spec.permissions[0]
+	Field string `json:"field"`
+	// ClusterRoleName that is bound
+	ClusterRoleName string `json:"clusterRoleName"`
+	// Granted is true when the entry binds the ClusterRole to the Subject in the Namespace
+	Granted bool `json:"granted"`
+	// BindingName of the ClusterRoleBinding or RoleBinding granting it
+	BindingName string `json:"bindingName,omitempty"`
+	// Explanation of the decision
+	Explanation string `json:"explanation"`
+}
+
+// reasons explains the reasons of the inventory of the planner
+var reasons = map[string]string{
+	planner.ReasonOutsideValidity: "outside of its Validity window",
+	planner.ReasonNotManaged:      "the name of the binding or of the generated ClusterRole is taken by an object the operator did not generate",
+	planner.ReasonProtected:       "the namespace is protected by the operator configuration",
+	planner.ReasonRestricted:      "the ClusterRole is restricted by the policy of the operator configuration",
+	planner.ReasonSubjectNotFound: "a Subject does not exist",
+}
+
+// Lookup returns an Entry for every ClusterPermission and Permission of the SubjectPermissions naming the
+// Subject, granted or not, in the order of the SubjectPermissions
+func Lookup(input Input) []Entry {
+	var entries []Entry
+	for _, subjectPermission := range input.SubjectPermissions {
+		if !namesSubject(subjectPermission, input.Subject) {
+			continue
+		}
+		entries = append(entries, lookupSubjectPermission(input, subjectPermission)...)
+	}
+	return entries
+}
+
+// Granted returns the entries that grant access
+func Granted(entries []Entry) []Entry {
+	var granted []Entry
+	for _, entry := range entries {
+		if entry.Granted {
+			granted = append(granted, entry)
+		}
+	}
+	return granted
+}
+
+// lookupSubjectPermission returns the entries of one SubjectPermission
+func lookupSubjectPermission(input Input, subjectPermission *managedv1alpha1.SubjectPermission) []Entry {
+	base := Entry{Kind: "SubjectPermission", Namespace: subjectPermission.Namespace, Name: subjectPermission.Name}
+	if subjectPermission.Namespace == "" {
+		base.Kind = "ClusterSubjectPermission"
+	}
+
+	// the operator does not act on an invalid SubjectPermission, nor write anything in Audit mode
+	notGranted := ""
+	if allErrs := validation.ValidateSubjectPermission(subjectPermission); len(allErrs) > 0 {
+		notGranted = "the SubjectPermission is invalid: " + allErrs.ToAggregate().Error()
+	} else if subjectPermission.Spec.Mode == managedv1alpha1.ModeAudit {
+		notGranted = "the SubjectPermission is in Audit mode, no binding is written"
+	}
+	note := ""
+	if subjectPermission.Spec.Suspend {
+		note = ", the SubjectPermission is suspended and its bindings are left as they are"
+	}
+
+	plan := planner.New(planner.Input{
+		SubjectPermission:   subjectPermission,
+		Namespaces:          []corev1.Namespace{input.Namespace},
+		ClusterRoles:        input.ClusterRoles,
+		Now:                 input.Now,
+		ProtectedNamespaces: input.ProtectedNamespaces,
+		Policy:              input.Policy,
+	})
+
+	// the bindings of a missing ClusterRole grant nothing until it is created
+	missing := func(clusterRoleName string) string {
+		if input.ClusterRoles != nil && controllerutil.ContainsString(plan.MissingClusterRoles(), clusterRoleName) {
+			return fmt.Sprintf(", but ClusterRole %s does not exist", clusterRoleName)
+		}
+		return ""
+	}
+
+	var entries []Entry
+	restricted := utility.IsNamespaceRestricted(subjectPermission)
+	for i, inventory := range plan.ClusterPermissions {
+		entry := base
+		entry.Field = fmt.Sprintf("spec.clusterPermissions[%d]", i)
+		entry.ClusterRoleName = inventory.ClusterRoleName
+		switch {
+		case notGranted != "":
+			entry.Explanation = notGranted
+		case restricted:
+			entry.Explanation = fmt.Sprintf("a SubjectPermission in namespace %s can only bind inside its own namespace", subjectPermission.Namespace)
+		case inventory.Reason != "":
+			entry.Explanation = reasons[inventory.Reason]
+		default:
+			entry.Granted, entry.BindingName = true, inventory.BindingName
+			entry.Explanation = "ClusterPermissions are bound in every namespace" + missing(entry.ClusterRoleName) + note
+		}
+		entries = append(entries, entry)
+	}
+
+	for i, inventory := range plan.Permissions {
+		permission := subjectPermission.Spec.Permissions[i]
+		entry := base
+		entry.Field = fmt.Sprintf("spec.permissions[%d]", i)
+		entry.ClusterRoleName = inventory.ClusterRoleName
+		switch {
+		case notGranted != "":
+			entry.Explanation = notGranted
+		case inventory.Reason != "":
+			entry.Explanation = reasons[inventory.Reason]
+		case inventory.MatchedNamespaceCount > 0:
+			entry.Granted, entry.BindingName = true, inventory.BindingName
+			entry.Explanation = utility.ExplainNamespaceMatch(permission, &input.Namespace) + missing(entry.ClusterRoleName) + note
+		default:
+			entry.Explanation = skippedExplanation(inventory, subjectPermission, permission, &input.Namespace)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// skippedExplanation explains why a Permission is not granted in a namespace it was evaluated against
+func skippedExplanation(inventory managedv1alpha1.PermissionStatus, subjectPermission *managedv1alpha1.SubjectPermission, permission managedv1alpha1.Permission, namespace *corev1.Namespace) string {
+	if len(inventory.SkippedNamespaces) == 0 {
+		return utility.ExplainNamespaceMatch(permission, namespace)
+	}
+	switch reason := inventory.SkippedNamespaces[0].Reason; reason {
+	case planner.ReasonOutOfScope:
+		return fmt.Sprintf("a SubjectPermission in namespace %s can only bind inside its own namespace", subjectPermission.Namespace)
+	case utility.NamespaceNotSelected, utility.NamespaceDenied, utility.NamespaceNotAllowed:
+		return utility.ExplainNamespaceMatch(permission, namespace)
+	default:
+		return reasons[reason]
+	}
+}
+
+// namesSubject checks if subject is one of the Subjects of a SubjectPermission
+func namesSubject(subjectPermission *managedv1alpha1.SubjectPermission, subject rbacv1.Subject) bool {
+	for _, s := range utility.SubjectsForSubjectPermission(subjectPermission) {
+		if s.Kind == subject.Kind && s.Name == subject.Name && s.Namespace == subject.Namespace {
+			return true
+		}
+	}
+	return false
+}
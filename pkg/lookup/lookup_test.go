@@ -0,0 +1,77 @@
+package lookup
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	managedv1alpha1 "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	"github.com/openshift/rbac-permissions-operator/pkg/planner"
+	"github.com/openshift/rbac-permissions-operator/pkg/policy"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func subjectPermission(namespace, name string, subject rbacv1.Subject, clusterPermissions []string, permissions ...managedv1alpha1.Permission) *managedv1alpha1.SubjectPermission {
+	return &managedv1alpha1.SubjectPermission{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, UID: "exampleUID"},
+		Spec: managedv1alpha1.SubjectPermissionSpec{
+			Subjects:           []rbacv1.Subject{subject},
+			ClusterPermissions: clusterPermissions,
+			Permissions:        permissions,
+		},
+	}
+}
+
+func clusterRoles(names ...string) []rbacv1.ClusterRole {
+	var clusterRoles []rbacv1.ClusterRole
+	for _, name := range names {
+		clusterRoles = append(clusterRoles, rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	return clusterRoles
+}
+
+// TestLookup tests the entries found for a Subject in a Namespace
+// given: SubjectPermissions of the group dev and of another group, allowing and denying the namespace
+// expected: only the entries of dev are listed, each with the regex or restriction that decided it
+func TestLookup(t *testing.T) {
+	dev := rbacv1.Subject{Kind: "Group", Name: "dev"}
+	ops := rbacv1.Subject{Kind: "Group", Name: "ops"}
+	operatorNamespace := "openshift-rbac-permissions-operator"
+
+	input := Input{
+		Subject:   dev,
+		Namespace: corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "dev-one"}},
+		SubjectPermissions: []*managedv1alpha1.SubjectPermission{
+			subjectPermission(operatorNamespace, "dev", dev, []string{"view", "cluster-admin"},
+				managedv1alpha1.Permission{ClusterRoleName: "edit", NamespacesAllowedRegex: "^dev-.*", NamespacesDeniedRegex: "-two$"},
+				managedv1alpha1.Permission{ClusterRoleName: "admin", NamespacesAllowedRegex: "^dev-.*", NamespacesDeniedRegex: "-one$"},
+			),
+			subjectPermission(operatorNamespace, "ops", ops, []string{"view"}),
+			subjectPermission("team", "scoped", dev, nil, managedv1alpha1.Permission{ClusterRoleName: "edit", NamespacesAllowedRegex: ".*"}),
+		},
+		ClusterRoles: clusterRoles("view", "edit", "cluster-admin"),
+		Now:          time.Now(),
+		Policy:       &policy.Policy{RestrictedClusterRoles: []string{"^cluster-admin$"}},
+	}
+
+	var summary [][]interface{}
+	for _, entry := range Lookup(input) {
+		summary = append(summary, []interface{}{entry.Namespace + "/" + entry.Name, entry.Field, entry.ClusterRoleName, entry.Granted, entry.Explanation})
+	}
+	expected := [][]interface{}{
+		{operatorNamespace + "/dev", "spec.clusterPermissions[0]", "view", true, "ClusterPermissions are bound in every namespace"},
+		{operatorNamespace + "/dev", "spec.clusterPermissions[1]", "cluster-admin", false, reasons[planner.ReasonRestricted]},
+		{operatorNamespace + "/dev", "spec.permissions[0]", "edit", true, `allowed by NamespacesAllowedRegex "^dev-.*" and not denied by NamespacesDeniedRegex "-two$"`},
+		{operatorNamespace + "/dev", "spec.permissions[1]", "admin", false, `denied by NamespacesDeniedRegex "-one$", NamespacesDeniedRegex is checked first`},
+		{"team/scoped", "spec.permissions[0]", "edit", false, "a SubjectPermission in namespace team can only bind inside its own namespace"},
+	}
+	if !reflect.DeepEqual(summary, expected) {
+		t.Errorf("got entries\n%v\nwant\n%v", summary, expected)
+	}
+
+	if granted := Granted(Lookup(input)); len(granted) != 2 {
+		t.Errorf("got %d granted entries, want 2", len(granted))
+	}
+}
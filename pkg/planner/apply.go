@@ -0,0 +1,331 @@
+// Copyright 2019 RedHat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"context"
+	"fmt"
+
+	managedv1alpha1 "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	controllerutil "github.com/openshift/rbac-permissions-operator/pkg/controller/utils"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Actions of a PlannedChange
+const (
+	ActionCreate = "Create"
+	ActionUpdate = "Update"
+	ActionDelete = "Delete"
+)
+
+// BindingRef identifies a binding touched while applying a Plan
+type BindingRef struct {
+	Kind            string
+	Namespace       string
+	Name            string
+	ClusterRoleName string
+}
+
+// String returns namespace/name for a RoleBinding and name for a ClusterRoleBinding
+func (b BindingRef) String() string {
+	if b.Namespace == "" {
+		return b.Name
+	}
+	return b.Namespace + "/" + b.Name
+}
+
+// Result holds the bindings and generated ClusterRoles changed while applying a Plan
+type Result struct {
+	Created []BindingRef
+	Updated []BindingRef
+	Deleted []BindingRef
+
+	CreatedClusterRoles []string
+	UpdatedClusterRoles []string
+	DeletedClusterRoles []string
+
+	// Failed are the changes that failed or were skipped, they are still pending
+	Failed []managedv1alpha1.PlannedChange
+}
+
+// Revoked returns the deleted bindings that were not recreated
+func (r *Result) Revoked() []BindingRef {
+	created := make(map[string]bool)
+	for _, ref := range r.Created {
+		created[ref.Kind+"/"+ref.String()] = true
+	}
+
+	var revoked []BindingRef
+	for _, ref := range r.Deleted {
+		if !created[ref.Kind+"/"+ref.String()] {
+			revoked = append(revoked, ref)
+		}
+	}
+	return revoked
+}
+
+// Changes returns every change of the Result, in the order Apply makes them
+func (r *Result) Changes() []managedv1alpha1.PlannedChange {
+	var changes []managedv1alpha1.PlannedChange
+	for _, name := range r.CreatedClusterRoles {
+		changes = append(changes, managedv1alpha1.PlannedChange{Action: ActionCreate, Kind: "ClusterRole", Name: name})
+	}
+	for _, name := range r.UpdatedClusterRoles {
+		changes = append(changes, managedv1alpha1.PlannedChange{Action: ActionUpdate, Kind: "ClusterRole", Name: name})
+	}
+	for _, ref := range r.Deleted {
+		changes = append(changes, managedv1alpha1.PlannedChange{Action: ActionDelete, Kind: ref.Kind, Namespace: ref.Namespace, Name: ref.Name})
+	}
+	for _, ref := range r.Created {
+		changes = append(changes, managedv1alpha1.PlannedChange{Action: ActionCreate, Kind: ref.Kind, Namespace: ref.Namespace, Name: ref.Name})
+	}
+	for _, ref := range r.Updated {
+		changes = append(changes, managedv1alpha1.PlannedChange{Action: ActionUpdate, Kind: ref.Kind, Namespace: ref.Namespace, Name: ref.Name})
+	}
+	for _, name := range r.DeletedClusterRoles {
+		changes = append(changes, managedv1alpha1.PlannedChange{Action: ActionDelete, Kind: "ClusterRole", Name: name})
+	}
+	return changes
+}
+
+// Preview returns the Result Apply would return for a Plan if every change succeeded, without making any change
+func Preview(plan *Plan) *Result {
+	result := &Result{}
+	for _, clusterRole := range plan.CreateClusterRoles {
+		result.CreatedClusterRoles = append(result.CreatedClusterRoles, clusterRole.Name)
+	}
+	for _, clusterRole := range plan.UpdateClusterRoles {
+		result.UpdatedClusterRoles = append(result.UpdatedClusterRoles, clusterRole.Name)
+	}
+	for _, clusterRoleBinding := range plan.DeleteClusterRoleBindings {
+		result.Deleted = append(result.Deleted, clusterRoleBindingRef(clusterRoleBinding))
+	}
+	for _, roleBinding := range plan.DeleteRoleBindings {
+		result.Deleted = append(result.Deleted, roleBindingRef(roleBinding))
+	}
+	for _, clusterRoleBinding := range plan.CreateClusterRoleBindings {
+		result.Created = append(result.Created, clusterRoleBindingRef(clusterRoleBinding))
+	}
+	for _, roleBinding := range plan.CreateRoleBindings {
+		result.Created = append(result.Created, roleBindingRef(roleBinding))
+	}
+	for _, clusterRoleBinding := range plan.UpdateClusterRoleBindings {
+		result.Updated = append(result.Updated, clusterRoleBindingRef(clusterRoleBinding))
+	}
+	for _, roleBinding := range plan.UpdateRoleBindings {
+		result.Updated = append(result.Updated, roleBindingRef(roleBinding))
+	}
+	for _, clusterRole := range plan.DeleteClusterRoles {
+		result.DeletedClusterRoles = append(result.DeletedClusterRoles, clusterRole.Name)
+	}
+	return result
+}
+
+// Apply makes the changes of a Plan on the cluster. The generated ClusterRoles are created and updated
+// before the bindings referencing them, and stale ones are deleted after their bindings.
+// Binding deletions go first so that a binding with a changed RoleRef can be recreated.
+// A failed change does not stop the others, only the bindings of a generated ClusterRole that could not be
+// created or updated are skipped. Returns the changes made and the errors of the failed ones, aggregated
+func Apply(ctx context.Context, c client.Client, plan *Plan) (*Result, error) {
+	result := &Result{}
+	var errs []error
+	fail := func(action, kind, namespace, name string, err error) {
+		result.Failed = append(result.Failed, managedv1alpha1.PlannedChange{Action: action, Kind: kind, Namespace: namespace, Name: name})
+		errs = append(errs, err)
+	}
+
+	// the bindings of a ClusterRole that is not as desired are left pending
+	failedClusterRoles := make(map[string]bool)
+	for _, clusterRole := range plan.CreateClusterRoles {
+		err := c.Create(ctx, clusterRole.DeepCopy())
+		if err != nil {
+			failedClusterRoles[clusterRole.Name] = true
+			fail(ActionCreate, "ClusterRole", "", clusterRole.Name, fmt.Errorf("unable to create ClusterRole %s: %v", clusterRole.Name, err))
+			continue
+		}
+		result.CreatedClusterRoles = append(result.CreatedClusterRoles, clusterRole.Name)
+	}
+	for _, clusterRole := range plan.UpdateClusterRoles {
+		err := c.Update(ctx, clusterRole.DeepCopy())
+		if err != nil {
+			failedClusterRoles[clusterRole.Name] = true
+			fail(ActionUpdate, "ClusterRole", "", clusterRole.Name, fmt.Errorf("unable to update ClusterRole %s: %v", clusterRole.Name, err))
+			continue
+		}
+		result.UpdatedClusterRoles = append(result.UpdatedClusterRoles, clusterRole.Name)
+	}
+
+	for _, clusterRoleBinding := range plan.DeleteClusterRoleBindings {
+		ref := clusterRoleBindingRef(clusterRoleBinding)
+		err := c.Delete(ctx, clusterRoleBinding)
+		if err != nil && !errors.IsNotFound(err) {
+			fail(ActionDelete, ref.Kind, "", ref.Name, fmt.Errorf("unable to delete ClusterRoleBinding %s: %v", ref, err))
+			continue
+		}
+		result.Deleted = append(result.Deleted, ref)
+	}
+	for _, roleBinding := range plan.DeleteRoleBindings {
+		ref := roleBindingRef(roleBinding)
+		err := c.Delete(ctx, roleBinding)
+		if err != nil && !errors.IsNotFound(err) {
+			fail(ActionDelete, ref.Kind, ref.Namespace, ref.Name, fmt.Errorf("unable to delete RoleBinding %s: %v", ref, err))
+			continue
+		}
+		result.Deleted = append(result.Deleted, ref)
+	}
+
+	for _, clusterRoleBinding := range plan.CreateClusterRoleBindings {
+		ref := clusterRoleBindingRef(clusterRoleBinding)
+		if failedClusterRoles[ref.ClusterRoleName] {
+			fail(ActionCreate, ref.Kind, "", ref.Name, fmt.Errorf("unable to create ClusterRoleBinding %s: ClusterRole %s is not as desired", ref, ref.ClusterRoleName))
+			continue
+		}
+		action, err := createBinding(ctx, c, clusterRoleBinding, &rbacv1.ClusterRoleBinding{})
+		if err != nil {
+			fail(ActionCreate, ref.Kind, "", ref.Name, fmt.Errorf("unable to create ClusterRoleBinding %s: %v", ref, err))
+			continue
+		}
+		result.add(action, ref)
+	}
+	for _, roleBinding := range plan.CreateRoleBindings {
+		ref := roleBindingRef(roleBinding)
+		if failedClusterRoles[ref.ClusterRoleName] {
+			fail(ActionCreate, ref.Kind, ref.Namespace, ref.Name, fmt.Errorf("unable to create RoleBinding %s: ClusterRole %s is not as desired", ref, ref.ClusterRoleName))
+			continue
+		}
+		action, err := createBinding(ctx, c, roleBinding, &rbacv1.RoleBinding{})
+		if err != nil {
+			fail(ActionCreate, ref.Kind, ref.Namespace, ref.Name, fmt.Errorf("unable to create RoleBinding %s: %v", ref, err))
+			continue
+		}
+		result.add(action, ref)
+	}
+
+	for _, clusterRoleBinding := range plan.UpdateClusterRoleBindings {
+		ref := clusterRoleBindingRef(clusterRoleBinding)
+		err := c.Update(ctx, clusterRoleBinding.DeepCopy())
+		if err != nil {
+			fail(ActionUpdate, ref.Kind, "", ref.Name, fmt.Errorf("unable to update ClusterRoleBinding %s: %v", ref, err))
+			continue
+		}
+		result.Updated = append(result.Updated, ref)
+	}
+	for _, roleBinding := range plan.UpdateRoleBindings {
+		ref := roleBindingRef(roleBinding)
+		err := c.Update(ctx, roleBinding.DeepCopy())
+		if err != nil {
+			fail(ActionUpdate, ref.Kind, ref.Namespace, ref.Name, fmt.Errorf("unable to update RoleBinding %s: %v", ref, err))
+			continue
+		}
+		result.Updated = append(result.Updated, ref)
+	}
+
+	for _, clusterRole := range plan.DeleteClusterRoles {
+		err := c.Delete(ctx, clusterRole)
+		if err != nil && !errors.IsNotFound(err) {
+			fail(ActionDelete, "ClusterRole", "", clusterRole.Name, fmt.Errorf("unable to delete ClusterRole %s: %v", clusterRole.Name, err))
+			continue
+		}
+		result.DeletedClusterRoles = append(result.DeletedClusterRoles, clusterRole.Name)
+	}
+
+	return result, utilerrors.NewAggregate(errs)
+}
+
+// add records a binding created or updated by createBinding,
+// nothing is recorded when the binding was already as desired
+func (r *Result) add(action string, ref BindingRef) {
+	switch action {
+	case ActionCreate:
+		r.Created = append(r.Created, ref)
+	case ActionUpdate:
+		r.Updated = append(r.Updated, ref)
+	}
+}
+
+// createBinding creates a ClusterRoleBinding or a RoleBinding. When it already exists, as the Plan was computed
+// before it showed up, it is read into existing, an empty object of the same kind, and compared with the desired
+// one: it is updated when its Subjects or ownership metadata differ and recreated when its RoleRef does.
+// Returns the action taken, "" when the existing binding was already as desired
+func createBinding(ctx context.Context, c client.Client, desired, existing runtime.Object) (string, error) {
+	err := c.Create(ctx, desired.DeepCopyObject())
+	if !errors.IsAlreadyExists(err) {
+		return ActionCreate, err
+	}
+
+	desiredMeta, desiredSubjects, desiredRoleRef := bindingOf(desired)
+	key := types.NamespacedName{Namespace: desiredMeta.GetNamespace(), Name: desiredMeta.GetName()}
+	if err = c.Get(ctx, key, existing); err != nil {
+		return "", err
+	}
+	existingMeta, existingSubjects, existingRoleRef := bindingOf(existing)
+	if !sameOwner(desiredMeta, existingMeta) {
+		return "", fmt.Errorf("it already exists and was not generated for this SubjectPermission")
+	}
+	drifted, roleRefChanged := controllerutil.BindingDrifted(*desiredSubjects, *existingSubjects, desiredRoleRef, existingRoleRef)
+	switch {
+	case roleRefChanged:
+		if err = c.Delete(ctx, existing); err != nil && !errors.IsNotFound(err) {
+			return "", err
+		}
+		return ActionCreate, c.Create(ctx, desired.DeepCopyObject())
+	case drifted || !metadataMatches(desiredMeta.GetLabels(), desiredMeta.GetAnnotations(), existingMeta.GetLabels(), existingMeta.GetAnnotations()):
+		updated := existing.DeepCopyObject()
+		updatedMeta, updatedSubjects, _ := bindingOf(updated)
+		*updatedSubjects = *desiredSubjects
+		updatedMeta.SetLabels(mergeMaps(updatedMeta.GetLabels(), desiredMeta.GetLabels()))
+		updatedMeta.SetAnnotations(mergeMaps(updatedMeta.GetAnnotations(), desiredMeta.GetAnnotations()))
+		return ActionUpdate, c.Update(ctx, updated)
+	}
+	return "", nil
+}
+
+// bindingOf returns the metadata, the Subjects and the RoleRef of a ClusterRoleBinding or a RoleBinding
+func bindingOf(object runtime.Object) (metav1.Object, *[]rbacv1.Subject, rbacv1.RoleRef) {
+	switch binding := object.(type) {
+	case *rbacv1.ClusterRoleBinding:
+		return binding, &binding.Subjects, binding.RoleRef
+	case *rbacv1.RoleBinding:
+		return binding, &binding.Subjects, binding.RoleRef
+	}
+	panic(fmt.Sprintf("%T is not a binding", object))
+}
+
+// sameOwner checks if an existing object was generated by the operator for the SubjectPermission
+// the desired one is generated for
+func sameOwner(desired, existing metav1.Object) bool {
+	for _, key := range []string{controllerutil.ManagedByLabel, controllerutil.OwnerUIDLabel} {
+		if desired.GetLabels()[key] == "" || existing.GetLabels()[key] != desired.GetLabels()[key] {
+			return false
+		}
+	}
+	return true
+}
+
+// clusterRoleBindingRef returns the BindingRef of a ClusterRoleBinding
+func clusterRoleBindingRef(clusterRoleBinding *rbacv1.ClusterRoleBinding) BindingRef {
+	return BindingRef{Kind: "ClusterRoleBinding", Name: clusterRoleBinding.Name, ClusterRoleName: clusterRoleBinding.RoleRef.Name}
+}
+
+// roleBindingRef returns the BindingRef of a RoleBinding
+func roleBindingRef(roleBinding *rbacv1.RoleBinding) BindingRef {
+	return BindingRef{Kind: "RoleBinding", Namespace: roleBinding.Namespace, Name: roleBinding.Name, ClusterRoleName: roleBinding.RoleRef.Name}
+}
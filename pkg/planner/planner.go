@@ -0,0 +1,544 @@
+// Copyright 2019 RedHat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package planner computes which bindings should exist for a SubjectPermission.
+// Planning is pure: it only works on the objects it is given and never talks to the cluster.
+package planner
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	managedv1alpha1 "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	controllerutil "github.com/openshift/rbac-permissions-operator/pkg/controller/utils"
+	"github.com/openshift/rbac-permissions-operator/pkg/dedicatedadmin"
+	"github.com/openshift/rbac-permissions-operator/pkg/policy"
+	"github.com/openshift/rbac-permissions-operator/pkg/utility"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+const (
+	// DriftSubjects is used when the Subjects of a generated binding changed
+	DriftSubjects = "subjects"
+	// DriftRoleRef is used when the RoleRef of a generated binding changed
+	DriftRoleRef = "roleRef"
+
+	// ReasonOutOfScope is used for bindings a SubjectPermission restricted to its own namespace cannot get
+	ReasonOutOfScope = "OutOfScope"
+	// ReasonOutsideValidity is used for bindings outside of their Validity window
+	ReasonOutsideValidity = "OutsideValidity"
+	// ReasonNotManaged is used for bindings whose name, or the name of their generated ClusterRole, is taken
+	// by an object the operator did not generate
+	ReasonNotManaged = "NotManaged"
+	// ReasonProtected is used for Namespaces protected by the operator configuration, which nothing binds into
+	ReasonProtected = "Protected"
+	// ReasonRestricted is used for bindings of ClusterRoles the Policy restricts
+	ReasonRestricted = "Restricted"
+	// ReasonSubjectNotFound is used for bindings held back until every Subject exists
+	ReasonSubjectNotFound = "SubjectNotFound"
+
+	// InventorySampleSize is the number of Namespaces kept in each list of a PermissionStatus
+	InventorySampleSize = 10
+)
+
+// Input is the state a Plan is computed from
+type Input struct {
+	// SubjectPermission the bindings are planned for
+	SubjectPermission *managedv1alpha1.SubjectPermission
+	// Namespaces the Permissions are evaluated against
+	Namespaces []corev1.Namespace
+	// ClusterRoles existing on the cluster
+	ClusterRoles []rbacv1.ClusterRole
+	// ClusterRoleBindings existing on the cluster
+	ClusterRoleBindings []rbacv1.ClusterRoleBinding
+	// RoleBindings existing on the cluster, only the ones in Namespaces are considered
+	RoleBindings []rbacv1.RoleBinding
+	// Now is the time the Validity windows are evaluated at, bindings are only desired inside their window
+	Now time.Time
+	// ProtectedNamespaces are the comma separated regexes of the Namespaces no RoleBinding is desired in,
+	// whatever the Permissions allow, see dedicatedadmin.GetProtectedNamespaces
+	ProtectedNamespaces string
+	// Policy restricting the ClusterRoles that are bound, nil restricts nothing
+	Policy *policy.Policy
+	// MissingSubjects are the Subjects found missing by controllerutil.MissingSubjects, no binding is desired
+	// while any is missing
+	MissingSubjects []rbacv1.Subject
+}
+
+// Plan holds the changes needed to bring the bindings of a SubjectPermission, and the ClusterRoles
+// generated for its Rules, to the desired state.
+// A binding whose RoleRef changed is both deleted and created, as RoleRef is immutable.
+type Plan struct {
+	// DesiredClusterRoles are all the ClusterRoles generated for the Rules of the Permissions
+	DesiredClusterRoles []*rbacv1.ClusterRole
+	// DesiredClusterRoleBindings are all the ClusterRoleBindings that should exist
+	DesiredClusterRoleBindings []*rbacv1.ClusterRoleBinding
+	// DesiredRoleBindings are all the RoleBindings that should exist in the evaluated Namespaces
+	DesiredRoleBindings []*rbacv1.RoleBinding
+
+	CreateClusterRoles []*rbacv1.ClusterRole
+	UpdateClusterRoles []*rbacv1.ClusterRole
+	DeleteClusterRoles []*rbacv1.ClusterRole
+
+	CreateClusterRoleBindings []*rbacv1.ClusterRoleBinding
+	UpdateClusterRoleBindings []*rbacv1.ClusterRoleBinding
+	DeleteClusterRoleBindings []*rbacv1.ClusterRoleBinding
+
+	CreateRoleBindings []*rbacv1.RoleBinding
+	UpdateRoleBindings []*rbacv1.RoleBinding
+	DeleteRoleBindings []*rbacv1.RoleBinding
+
+	// ClusterPermissions is the inventory of the binding of each entry of the ClusterPermissions
+	ClusterPermissions []managedv1alpha1.ClusterPermissionStatus
+	// Permissions is the inventory of the bindings of each Permission in the evaluated Namespaces
+	Permissions []managedv1alpha1.PermissionStatus
+
+	// Drift lists the generated bindings that drifted from the desired state
+	Drift []Drift
+	// Errors found while planning, such as missing ClusterRoles
+	Errors []error
+}
+
+// Drift describes a generated binding that drifted from the desired state
+type Drift struct {
+	Kind      string
+	Namespace string
+	Name      string
+	// Reason is either DriftSubjects or DriftRoleRef
+	Reason string
+}
+
+// MissingClusterRoleError is returned for a ClusterRole referenced by a SubjectPermission that does not exist
+type MissingClusterRoleError struct {
+	ClusterRoleName string
+}
+
+func (e *MissingClusterRoleError) Error() string {
+	return fmt.Sprintf("ClusterRole %s does not exist", e.ClusterRoleName)
+}
+
+// UnmanagedBindingError is returned when the name of a desired binding or generated ClusterRole is taken
+// by an object the operator did not generate
+type UnmanagedBindingError struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func (e *UnmanagedBindingError) Error() string {
+	if e.Namespace == "" {
+		return fmt.Sprintf("%s %s exists but is not managed by the operator", e.Kind, e.Name)
+	}
+	return fmt.Sprintf("%s %s in namespace %s exists but is not managed by the operator", e.Kind, e.Name, e.Namespace)
+}
+
+// RestrictedClusterRoleError is returned for a ClusterPermission or Permission the Policy does not allow
+type RestrictedClusterRoleError struct {
+	ClusterRoleName string
+	// Violation explains which restriction of the Policy was tripped
+	Violation string
+}
+
+func (e *RestrictedClusterRoleError) Error() string {
+	return fmt.Sprintf("ClusterRole %s is not granted: %s", e.ClusterRoleName, e.Violation)
+}
+
+// SubjectNotFoundError is returned for a Subject that does not exist while its existence is verified
+type SubjectNotFoundError struct {
+	Subject rbacv1.Subject
+}
+
+func (e *SubjectNotFoundError) Error() string {
+	if e.Subject.Namespace == "" {
+		return fmt.Sprintf("%s %s does not exist", e.Subject.Kind, e.Subject.Name)
+	}
+	return fmt.Sprintf("%s %s in namespace %s does not exist", e.Subject.Kind, e.Subject.Name, e.Subject.Namespace)
+}
+
+// ClusterPermissionsOutOfScopeError is returned for the ClusterPermissions of a SubjectPermission that may only
+// bind inside its own namespace, see utility.IsNamespaceRestricted
+type ClusterPermissionsOutOfScopeError struct {
+	Namespace string
+}
+
+func (e *ClusterPermissionsOutOfScopeError) Error() string {
+	return fmt.Sprintf("ClusterPermissions are ignored, a SubjectPermission in namespace %s can only bind inside its own namespace, use a ClusterSubjectPermission instead", e.Namespace)
+}
+
+// New computes the complete Plan for the ClusterPermissions and Permissions of a SubjectPermission
+func New(input Input) *Plan {
+	plan := &Plan{}
+	plan.planClusterRoles(input)
+	plan.planClusterRoleBindings(input)
+	plan.planRoleBindings(input)
+	plan.planMissingClusterRoles(input, true)
+	plan.planMissingSubjects(input)
+	return plan
+}
+
+// ForRoleBindings computes the Plan for the Permissions of a SubjectPermission only,
+// which allows to evaluate a subset of the Namespaces. The generated ClusterRoles are left to New.
+func ForRoleBindings(input Input) *Plan {
+	plan := &Plan{}
+	plan.planRoleBindings(input)
+	plan.planMissingClusterRoles(input, false)
+	plan.planMissingSubjects(input)
+	return plan
+}
+
+// MissingClusterRoles returns the names of the ClusterRoles that were found missing while planning
+func (p *Plan) MissingClusterRoles() []string {
+	var clusterRoleNames []string
+	for _, err := range p.Errors {
+		if missing, ok := err.(*MissingClusterRoleError); ok {
+			clusterRoleNames = append(clusterRoleNames, missing.ClusterRoleName)
+		}
+	}
+	return clusterRoleNames
+}
+
+// planClusterRoles plans the ClusterRoles generated for the Permissions with Rules
+func (p *Plan) planClusterRoles(input Input) {
+	subjectPermission := input.SubjectPermission
+
+	desired := make(map[string]bool)
+	for i, permission := range subjectPermission.Spec.Permissions {
+		// the restricted Rules are reported by planRoleBindings
+		if len(permission.Rules) == 0 || input.Policy.CheckPermission(subjectPermission, i, input.ClusterRoles) != "" {
+			continue
+		}
+		clusterRole := controllerutil.NewClusterRoleForPermission(subjectPermission, i)
+		desired[clusterRole.Name] = true
+		p.DesiredClusterRoles = append(p.DesiredClusterRoles, clusterRole)
+
+		existing := findClusterRole(input.ClusterRoles, clusterRole.Name)
+		switch {
+		case existing == nil:
+			p.CreateClusterRoles = append(p.CreateClusterRoles, clusterRole)
+		case !controllerutil.IsOwnedBy(existing, subjectPermission):
+			p.Errors = append(p.Errors, &UnmanagedBindingError{Kind: "ClusterRole", Name: existing.Name})
+		case !reflect.DeepEqual(clusterRole.Rules, existing.Rules) || !metadataMatches(clusterRole.Labels, clusterRole.Annotations, existing.Labels, existing.Annotations):
+			updated := existing.DeepCopy()
+			updated.Rules = clusterRole.Rules
+			updated.Labels = mergeMaps(updated.Labels, clusterRole.Labels)
+			updated.Annotations = mergeMaps(updated.Annotations, clusterRole.Annotations)
+			p.UpdateClusterRoles = append(p.UpdateClusterRoles, updated)
+		}
+	}
+
+	// every other ClusterRole generated for the SubjectPermission is stale
+	for i := range input.ClusterRoles {
+		existing := &input.ClusterRoles[i]
+		if !desired[existing.Name] && controllerutil.IsOwnedBy(existing, subjectPermission) {
+			p.DeleteClusterRoles = append(p.DeleteClusterRoles, existing.DeepCopy())
+		}
+	}
+}
+
+// planClusterRoleBindings plans the ClusterRoleBindings of the ClusterPermissions
+func (p *Plan) planClusterRoleBindings(input Input) {
+	subjectPermission := input.SubjectPermission
+	restricted := utility.IsNamespaceRestricted(subjectPermission)
+	if restricted && len(subjectPermission.Spec.ClusterPermissions) > 0 {
+		p.Errors = append(p.Errors, &ClusterPermissionsOutOfScopeError{Namespace: subjectPermission.Namespace})
+	}
+
+	active := utility.IsValidityActive(subjectPermission.Spec.Validity, subjectPermission.CreationTimestamp.Time, input.Now)
+
+	desired := make(map[string]bool)
+	for i, clusterRoleName := range subjectPermission.Spec.ClusterPermissions {
+		inventory := managedv1alpha1.ClusterPermissionStatus{ClusterRoleName: clusterRoleName}
+		switch {
+		case restricted:
+			inventory.Reason = ReasonOutOfScope
+		case !active:
+			inventory.Reason = ReasonOutsideValidity
+		case len(input.MissingSubjects) > 0:
+			inventory.Reason = ReasonSubjectNotFound
+		default:
+			if violation := input.Policy.CheckClusterPermission(subjectPermission, i, input.ClusterRoles); violation != "" {
+				inventory.Reason = ReasonRestricted
+				p.Errors = append(p.Errors, &RestrictedClusterRoleError{ClusterRoleName: clusterRoleName, Violation: violation})
+			}
+		}
+		if inventory.Reason != "" {
+			p.ClusterPermissions = append(p.ClusterPermissions, inventory)
+			continue
+		}
+
+		clusterRoleBinding := controllerutil.NewClusterRoleBinding(subjectPermission, i)
+		existing := findClusterRoleBinding(input.ClusterRoleBindings, clusterRoleBinding.Name)
+		if existing != nil && !controllerutil.IsOwnedBy(existing, subjectPermission) {
+			inventory.Reason = ReasonNotManaged
+		} else {
+			inventory.BindingName = clusterRoleBinding.Name
+		}
+		p.ClusterPermissions = append(p.ClusterPermissions, inventory)
+
+		if desired[clusterRoleBinding.Name] {
+			continue
+		}
+		desired[clusterRoleBinding.Name] = true
+		p.DesiredClusterRoleBindings = append(p.DesiredClusterRoleBindings, clusterRoleBinding)
+
+		switch {
+		case existing == nil:
+			p.CreateClusterRoleBindings = append(p.CreateClusterRoleBindings, clusterRoleBinding)
+		case !controllerutil.IsOwnedBy(existing, subjectPermission):
+			p.Errors = append(p.Errors, &UnmanagedBindingError{Kind: "ClusterRoleBinding", Name: existing.Name})
+		default:
+			drifted, roleRefChanged := controllerutil.BindingDrifted(clusterRoleBinding.Subjects, existing.Subjects, clusterRoleBinding.RoleRef, existing.RoleRef)
+			switch {
+			case roleRefChanged:
+				p.Drift = append(p.Drift, Drift{Kind: "ClusterRoleBinding", Name: existing.Name, Reason: DriftRoleRef})
+				p.DeleteClusterRoleBindings = append(p.DeleteClusterRoleBindings, existing.DeepCopy())
+				p.CreateClusterRoleBindings = append(p.CreateClusterRoleBindings, clusterRoleBinding)
+			case drifted || !metadataMatches(clusterRoleBinding.Labels, clusterRoleBinding.Annotations, existing.Labels, existing.Annotations):
+				if drifted {
+					p.Drift = append(p.Drift, Drift{Kind: "ClusterRoleBinding", Name: existing.Name, Reason: DriftSubjects})
+				}
+				updated := existing.DeepCopy()
+				updated.Subjects = clusterRoleBinding.Subjects
+				updated.Labels = mergeMaps(updated.Labels, clusterRoleBinding.Labels)
+				updated.Annotations = mergeMaps(updated.Annotations, clusterRoleBinding.Annotations)
+				p.UpdateClusterRoleBindings = append(p.UpdateClusterRoleBindings, updated)
+			}
+		}
+	}
+
+	// every other ClusterRoleBinding generated for the SubjectPermission is stale, and so are the ones
+	// generated by legacy versions, which are replaced by the ones desired under their new names
+	legacyClusterRoleNames, _ := controllerutil.LegacyClusterRoleNames(subjectPermission)
+	for i := range input.ClusterRoleBindings {
+		existing := &input.ClusterRoleBindings[i]
+		if desired[existing.Name] {
+			continue
+		}
+		if controllerutil.IsOwnedBy(existing, subjectPermission) || controllerutil.IsLegacyBinding(subjectPermission, existing, existing.Subjects, existing.RoleRef, legacyClusterRoleNames) {
+			p.DeleteClusterRoleBindings = append(p.DeleteClusterRoleBindings, existing.DeepCopy())
+		}
+	}
+}
+
+// planRoleBindings plans the RoleBindings of the Permissions in the evaluated Namespaces.
+// A SubjectPermission restricted to its own namespace only gets RoleBindings there.
+func (p *Plan) planRoleBindings(input Input) {
+	subjectPermission := input.SubjectPermission
+	restricted := utility.IsNamespaceRestricted(subjectPermission)
+
+	evaluated := make(map[string]bool)
+	for _, namespace := range input.Namespaces {
+		evaluated[namespace.Name] = true
+	}
+
+	desired := make(map[string]bool)
+	for i, permission := range subjectPermission.Spec.Permissions {
+		inventory := managedv1alpha1.PermissionStatus{ClusterRoleName: controllerutil.PermissionClusterRoleName(subjectPermission, i)}
+		switch {
+		case !utility.IsPermissionActive(subjectPermission, i, input.Now):
+			inventory.Reason = ReasonOutsideValidity
+		case len(input.MissingSubjects) > 0:
+			inventory.Reason = ReasonSubjectNotFound
+		case len(permission.Rules) > 0 && isUnmanaged(input.ClusterRoles, controllerutil.GeneratedClusterRoleName(subjectPermission, i), subjectPermission):
+			// never bind a ClusterRole that only looks like the generated one
+			inventory.Reason = ReasonNotManaged
+		default:
+			if violation := input.Policy.CheckPermission(subjectPermission, i, input.ClusterRoles); violation != "" {
+				inventory.Reason = ReasonRestricted
+				p.Errors = append(p.Errors, &RestrictedClusterRoleError{ClusterRoleName: inventory.ClusterRoleName, Violation: violation})
+			}
+		}
+		if inventory.Reason != "" {
+			p.Permissions = append(p.Permissions, inventory)
+			continue
+		}
+
+		for j := range input.Namespaces {
+			namespace := &input.Namespaces[j]
+			if restricted && namespace.Name != subjectPermission.Namespace {
+				skipNamespace(&inventory, namespace.Name, ReasonOutOfScope)
+				continue
+			}
+			if input.ProtectedNamespaces != "" && dedicatedadmin.IsBlackListedNamespace(namespace.Name, input.ProtectedNamespaces) {
+				skipNamespace(&inventory, namespace.Name, ReasonProtected)
+				continue
+			}
+			if reason := utility.NamespaceMismatchReason(permission, namespace); reason != "" {
+				skipNamespace(&inventory, namespace.Name, reason)
+				continue
+			}
+
+			roleBinding := controllerutil.NewRoleBindingForClusterRole(subjectPermission, i, namespace.Name)
+			existing := findRoleBinding(input.RoleBindings, roleBinding.Namespace, roleBinding.Name)
+			if existing != nil && !controllerutil.IsOwnedBy(existing, subjectPermission) {
+				skipNamespace(&inventory, namespace.Name, ReasonNotManaged)
+			} else {
+				inventory.BindingName = roleBinding.Name
+				inventory.MatchedNamespaceCount++
+				if len(inventory.MatchedNamespaces) < InventorySampleSize {
+					inventory.MatchedNamespaces = append(inventory.MatchedNamespaces, namespace.Name)
+				}
+			}
+
+			key := roleBinding.Namespace + "/" + roleBinding.Name
+			if desired[key] {
+				continue
+			}
+			desired[key] = true
+			p.DesiredRoleBindings = append(p.DesiredRoleBindings, roleBinding)
+
+			switch {
+			case existing == nil:
+				p.CreateRoleBindings = append(p.CreateRoleBindings, roleBinding)
+			case !controllerutil.IsOwnedBy(existing, subjectPermission):
+				p.Errors = append(p.Errors, &UnmanagedBindingError{Kind: "RoleBinding", Namespace: existing.Namespace, Name: existing.Name})
+			default:
+				drifted, roleRefChanged := controllerutil.BindingDrifted(roleBinding.Subjects, existing.Subjects, roleBinding.RoleRef, existing.RoleRef)
+				switch {
+				case roleRefChanged:
+					p.Drift = append(p.Drift, Drift{Kind: "RoleBinding", Namespace: existing.Namespace, Name: existing.Name, Reason: DriftRoleRef})
+					p.DeleteRoleBindings = append(p.DeleteRoleBindings, existing.DeepCopy())
+					p.CreateRoleBindings = append(p.CreateRoleBindings, roleBinding)
+				case drifted || !metadataMatches(roleBinding.Labels, roleBinding.Annotations, existing.Labels, existing.Annotations):
+					if drifted {
+						p.Drift = append(p.Drift, Drift{Kind: "RoleBinding", Namespace: existing.Namespace, Name: existing.Name, Reason: DriftSubjects})
+					}
+					updated := existing.DeepCopy()
+					updated.Subjects = roleBinding.Subjects
+					updated.Labels = mergeMaps(updated.Labels, roleBinding.Labels)
+					updated.Annotations = mergeMaps(updated.Annotations, roleBinding.Annotations)
+					p.UpdateRoleBindings = append(p.UpdateRoleBindings, updated)
+				}
+			}
+		}
+		p.Permissions = append(p.Permissions, inventory)
+	}
+
+	// every other RoleBinding generated for the SubjectPermission in the evaluated Namespaces is stale,
+	// and so are the ones generated by legacy versions
+	_, legacyClusterRoleNames := controllerutil.LegacyClusterRoleNames(subjectPermission)
+	for i := range input.RoleBindings {
+		existing := &input.RoleBindings[i]
+		if !evaluated[existing.Namespace] || desired[existing.Namespace+"/"+existing.Name] {
+			continue
+		}
+		if controllerutil.IsOwnedBy(existing, subjectPermission) || controllerutil.IsLegacyBinding(subjectPermission, existing, existing.Subjects, existing.RoleRef, legacyClusterRoleNames) {
+			p.DeleteRoleBindings = append(p.DeleteRoleBindings, existing.DeepCopy())
+		}
+	}
+}
+
+// skipNamespace records in the inventory of a Permission that it is not granted in namespace for reason
+func skipNamespace(inventory *managedv1alpha1.PermissionStatus, namespace, reason string) {
+	inventory.SkippedNamespaceCount++
+	if len(inventory.SkippedNamespaces) < InventorySampleSize {
+		inventory.SkippedNamespaces = append(inventory.SkippedNamespaces, managedv1alpha1.SkippedNamespace{Name: namespace, Reason: reason})
+	}
+}
+
+// planMissingClusterRoles records an error for each referenced ClusterRole that does not exist
+func (p *Plan) planMissingClusterRoles(input Input, includeClusterPermissions bool) {
+	var referenced []string
+	if includeClusterPermissions && !utility.IsNamespaceRestricted(input.SubjectPermission) {
+		referenced = append(referenced, input.SubjectPermission.Spec.ClusterPermissions...)
+	}
+	for _, permission := range input.SubjectPermission.Spec.Permissions {
+		// the ClusterRoles of Rules are planned by planClusterRoles
+		if len(permission.Rules) == 0 {
+			referenced = append(referenced, permission.ClusterRoleName)
+		}
+	}
+
+	clusterRoleList := &rbacv1.ClusterRoleList{Items: input.ClusterRoles}
+	var missing []string
+	for _, clusterRoleName := range referenced {
+		if !controllerutil.ClusterRoleExists(clusterRoleName, clusterRoleList) && !controllerutil.ContainsString(missing, clusterRoleName) {
+			missing = append(missing, clusterRoleName)
+			p.Errors = append(p.Errors, &MissingClusterRoleError{ClusterRoleName: clusterRoleName})
+		}
+	}
+}
+
+// planMissingSubjects records an error for each Subject that does not exist
+func (p *Plan) planMissingSubjects(input Input) {
+	for _, subject := range input.MissingSubjects {
+		p.Errors = append(p.Errors, &SubjectNotFoundError{Subject: subject})
+	}
+}
+
+// findClusterRole returns the ClusterRole called name, or nil
+func findClusterRole(clusterRoles []rbacv1.ClusterRole, name string) *rbacv1.ClusterRole {
+	for i := range clusterRoles {
+		if clusterRoles[i].Name == name {
+			return &clusterRoles[i]
+		}
+	}
+	return nil
+}
+
+// isUnmanaged checks if the ClusterRole called name exists and was not generated for subjectPermission
+func isUnmanaged(clusterRoles []rbacv1.ClusterRole, name string, subjectPermission *managedv1alpha1.SubjectPermission) bool {
+	existing := findClusterRole(clusterRoles, name)
+	return existing != nil && !controllerutil.IsOwnedBy(existing, subjectPermission)
+}
+
+// findClusterRoleBinding returns the ClusterRoleBinding called name, or nil
+func findClusterRoleBinding(clusterRoleBindings []rbacv1.ClusterRoleBinding, name string) *rbacv1.ClusterRoleBinding {
+	for i := range clusterRoleBindings {
+		if clusterRoleBindings[i].Name == name {
+			return &clusterRoleBindings[i]
+		}
+	}
+	return nil
+}
+
+// findRoleBinding returns the RoleBinding called name in namespace, or nil
+func findRoleBinding(roleBindings []rbacv1.RoleBinding, namespace, name string) *rbacv1.RoleBinding {
+	for i := range roleBindings {
+		if roleBindings[i].Namespace == namespace && roleBindings[i].Name == name {
+			return &roleBindings[i]
+		}
+	}
+	return nil
+}
+
+// metadataMatches checks that the desired labels and annotations are set on an existing binding
+func metadataMatches(desiredLabels, desiredAnnotations, labels, annotations map[string]string) bool {
+	return reflect.DeepEqual(desiredLabels, subset(labels, desiredLabels)) && reflect.DeepEqual(desiredAnnotations, subset(annotations, desiredAnnotations))
+}
+
+// subset returns the entries of m whose keys are in keys
+func subset(m, keys map[string]string) map[string]string {
+	result := make(map[string]string)
+	for key := range keys {
+		if value, ok := m[key]; ok {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// mergeMaps returns a copy of m with the entries of overrides set
+func mergeMaps(m, overrides map[string]string) map[string]string {
+	result := make(map[string]string)
+	for key, value := range m {
+		result[key] = value
+	}
+	for key, value := range overrides {
+		result[key] = value
+	}
+	return result
+}
@@ -0,0 +1,868 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	managedv1alpha1 "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	controllerutil "github.com/openshift/rbac-permissions-operator/pkg/controller/utils"
+	"github.com/openshift/rbac-permissions-operator/pkg/dedicatedadmin"
+	"github.com/openshift/rbac-permissions-operator/pkg/policy"
+	"github.com/openshift/rbac-permissions-operator/pkg/utility"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func mockSubjectPermission() *managedv1alpha1.SubjectPermission {
+	return &managedv1alpha1.SubjectPermission{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "testSubjectPermission",
+			Namespace: "openshift-rbac-permissions-operator",
+			UID:       "exampleUID",
+		},
+		Spec: managedv1alpha1.SubjectPermissionSpec{
+			SubjectName:        "exampleSubjectName",
+			SubjectKind:        "Group",
+			ClusterPermissions: []string{"exampleClusterRoleName"},
+			Permissions: []managedv1alpha1.Permission{
+				{
+					ClusterRoleName:        "exampleClusterRoleName",
+					NamespacesAllowedRegex: "^example-.*",
+					NamespacesDeniedRegex:  "^example-denied$",
+				},
+			},
+		},
+	}
+}
+
+func namespaces(names ...string) []corev1.Namespace {
+	var result []corev1.Namespace
+	for _, name := range names {
+		result = append(result, corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	return result
+}
+
+func clusterRoles(names ...string) []rbacv1.ClusterRole {
+	var result []rbacv1.ClusterRole
+	for _, name := range names {
+		result = append(result, rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	return result
+}
+
+func roleBindingKeys(roleBindings []*rbacv1.RoleBinding) []string {
+	var keys []string
+	for _, roleBinding := range roleBindings {
+		keys = append(keys, roleBinding.Namespace+"/"+roleBinding.RoleRef.Name)
+	}
+	return keys
+}
+
+func clusterRoleBindingKeys(clusterRoleBindings []*rbacv1.ClusterRoleBinding) []string {
+	var keys []string
+	for _, clusterRoleBinding := range clusterRoleBindings {
+		keys = append(keys, clusterRoleBinding.RoleRef.Name)
+	}
+	return keys
+}
+
+// TestNewPlansMissingBindings tests the plan for a SubjectPermission without any binding yet
+// given: a SubjectPermission, namespaces allowed and denied by its Permission, no bindings
+// expected: one ClusterRoleBinding and one RoleBinding per allowed namespace to create, nothing else
+func TestNewPlansMissingBindings(t *testing.T) {
+	plan := New(Input{
+		SubjectPermission: mockSubjectPermission(),
+		Namespaces:        namespaces("example-one", "example-denied", "other", "example-two"),
+		ClusterRoles:      clusterRoles("exampleClusterRoleName"),
+	})
+
+	if got, want := clusterRoleBindingKeys(plan.CreateClusterRoleBindings), []string{"exampleClusterRoleName"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got ClusterRoleBindings to create %v, want %v", got, want)
+	}
+	if got, want := roleBindingKeys(plan.CreateRoleBindings), []string{"example-one/exampleClusterRoleName", "example-two/exampleClusterRoleName"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got RoleBindings to create %v, want %v", got, want)
+	}
+	if len(plan.UpdateClusterRoleBindings)+len(plan.DeleteClusterRoleBindings)+len(plan.UpdateRoleBindings)+len(plan.DeleteRoleBindings) != 0 {
+		t.Errorf("expected no update or delete, got %+v", plan)
+	}
+	if len(plan.Errors) != 0 {
+		t.Errorf("expected no errors, got %v", plan.Errors)
+	}
+}
+
+// TestNewBindsAllSubjects tests that every Subject is bound by each generated binding
+// given: a SubjectPermission with the single Subject fields and a Subjects list
+// expected: each binding carries all the Subjects with their APIGroup defaulted
+func TestNewBindsAllSubjects(t *testing.T) {
+	subjectPermission := mockSubjectPermission()
+	subjectPermission.Spec.Subjects = []rbacv1.Subject{
+		{Kind: "User", Name: "admin"},
+		{Kind: "ServiceAccount", Namespace: "ci", Name: "robot"},
+	}
+	expected := []rbacv1.Subject{
+		{APIGroup: rbacv1.GroupName, Kind: "Group", Name: "exampleSubjectName"},
+		{APIGroup: rbacv1.GroupName, Kind: "User", Name: "admin"},
+		{Kind: "ServiceAccount", Namespace: "ci", Name: "robot"},
+	}
+
+	plan := New(Input{SubjectPermission: subjectPermission, Namespaces: namespaces("example-one")})
+	if len(plan.CreateClusterRoleBindings) != 1 || !reflect.DeepEqual(plan.CreateClusterRoleBindings[0].Subjects, expected) {
+		t.Errorf("got ClusterRoleBindings %v, want one binding %v", plan.CreateClusterRoleBindings, expected)
+	}
+	if len(plan.CreateRoleBindings) != 1 || !reflect.DeepEqual(plan.CreateRoleBindings[0].Subjects, expected) {
+		t.Errorf("got RoleBindings %v, want one binding %v", plan.CreateRoleBindings, expected)
+	}
+}
+
+// TestNewHonoursAllowFirst tests that the namespaces are evaluated in the order given by AllowFirst
+// given: a namespace matching both the allowed and the denied regex
+// expected: a RoleBinding only when the allowed regex is applied first and the namespace is not denied
+func TestNewHonoursAllowFirst(t *testing.T) {
+	var tests = []struct {
+		allowFirst bool
+		denied     string
+		expected   int
+	}{
+		{false, "^example-one$", 0},
+		{true, "^example-one$", 0},
+		{true, "", 1},
+		{false, "", 1},
+	}
+
+	for _, test := range tests {
+		subjectPermission := mockSubjectPermission()
+		subjectPermission.Spec.Permissions[0].AllowFirst = test.allowFirst
+		subjectPermission.Spec.Permissions[0].NamespacesDeniedRegex = test.denied
+
+		plan := New(Input{SubjectPermission: subjectPermission, Namespaces: namespaces("example-one")})
+		if len(plan.CreateRoleBindings) != test.expected {
+			t.Errorf("allowFirst %t, denied %q: got %d RoleBindings, want %d", test.allowFirst, test.denied, len(plan.CreateRoleBindings), test.expected)
+		}
+	}
+}
+
+// TestNewHonoursNamespaceSelector tests that the namespace labels are matched on top of the regexes
+// given: a Permission selecting namespaces labelled team=example, with and without an allowed regex
+// expected: RoleBindings only in labelled namespaces that are allowed by the regexes
+func TestNewHonoursNamespaceSelector(t *testing.T) {
+	labelled := func(name string, labels map[string]string) corev1.Namespace {
+		return corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	input := []corev1.Namespace{
+		labelled("example-one", map[string]string{"team": "example"}),
+		labelled("example-two", nil),
+		labelled("example-denied", map[string]string{"team": "example"}),
+		labelled("other", map[string]string{"team": "example"}),
+	}
+
+	var tests = []struct {
+		allowed  string
+		expected []string
+	}{
+		{"^example-.*", []string{"example-one/exampleClusterRoleName"}},
+		{"", []string{"example-one/exampleClusterRoleName", "other/exampleClusterRoleName"}},
+	}
+
+	for _, test := range tests {
+		subjectPermission := mockSubjectPermission()
+		subjectPermission.Spec.Permissions[0].NamespacesAllowedRegex = test.allowed
+		subjectPermission.Spec.Permissions[0].NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"team": "example"}}
+
+		plan := New(Input{SubjectPermission: subjectPermission, Namespaces: input})
+		if got := roleBindingKeys(plan.CreateRoleBindings); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("allowed %q: got RoleBindings %v, want %v", test.allowed, got, test.expected)
+		}
+	}
+}
+
+// TestNewPlansGeneratedClusterRoles tests the plan for the ClusterRoles generated for Rules
+// given: Permissions with Rules whose ClusterRoles are missing, drifted, stale or taken by an unmanaged ClusterRole
+// expected: the generated ClusterRoles are created, updated and deleted, and an unmanaged one is never bound
+func TestNewPlansGeneratedClusterRoles(t *testing.T) {
+	rules := []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}}}
+	subjectPermission := mockSubjectPermission()
+	subjectPermission.Spec.Permissions = []managedv1alpha1.Permission{
+		{NamespacesAllowedRegex: "^example-.*", Rules: rules},
+		{NamespacesAllowedRegex: "^example-.*", Rules: rules},
+		{NamespacesAllowedRegex: "^example-.*", Rules: rules},
+	}
+
+	drifted := controllerutil.NewClusterRoleForPermission(subjectPermission, 1)
+	drifted.Rules = nil
+	unmanaged := controllerutil.NewClusterRoleForPermission(subjectPermission, 2)
+	unmanaged.Labels = nil
+	stale := controllerutil.NewClusterRoleForPermission(subjectPermission, 0)
+	stale.Name = "stale"
+
+	plan := New(Input{
+		SubjectPermission: subjectPermission,
+		Namespaces:        namespaces("example-one"),
+		ClusterRoles:      append(clusterRoles("exampleClusterRoleName"), *drifted, *unmanaged, *stale),
+	})
+
+	var tests = []struct {
+		label    string
+		got      []*rbacv1.ClusterRole
+		expected []string
+	}{
+		{"create", plan.CreateClusterRoles, []string{controllerutil.GeneratedClusterRoleName(subjectPermission, 0)}},
+		{"update", plan.UpdateClusterRoles, []string{drifted.Name}},
+		{"delete", plan.DeleteClusterRoles, []string{"stale"}},
+	}
+	for _, test := range tests {
+		var names []string
+		for _, clusterRole := range test.got {
+			names = append(names, clusterRole.Name)
+		}
+		if !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("%s: got ClusterRoles %v, want %v", test.label, names, test.expected)
+		}
+	}
+
+	expectedRoleBindings := []string{"example-one/" + controllerutil.GeneratedClusterRoleName(subjectPermission, 0), "example-one/" + drifted.Name}
+	if got := roleBindingKeys(plan.CreateRoleBindings); !reflect.DeepEqual(got, expectedRoleBindings) {
+		t.Errorf("got RoleBindings %v, want %v", got, expectedRoleBindings)
+	}
+	if len(plan.Errors) != 1 {
+		t.Errorf("got errors %v, want one for the unmanaged ClusterRole", plan.Errors)
+	}
+}
+
+// TestNewRestrictsNamespacedSubjectPermissions tests that a SubjectPermission outside of the operator namespace only binds in its namespace
+// given: a SubjectPermission in namespace example-one with ClusterPermissions, an existing ClusterRoleBinding and RoleBinding elsewhere
+// expected: a RoleBinding in example-one only, the other bindings are deleted and the ClusterPermissions are reported
+func TestNewRestrictsNamespacedSubjectPermissions(t *testing.T) {
+	subjectPermission := mockSubjectPermission()
+	subjectPermission.Namespace = "example-one"
+
+	plan := New(Input{
+		SubjectPermission:   subjectPermission,
+		Namespaces:          namespaces("example-one", "example-two"),
+		ClusterRoles:        clusterRoles("exampleClusterRoleName"),
+		ClusterRoleBindings: []rbacv1.ClusterRoleBinding{*controllerutil.NewClusterRoleBinding(subjectPermission, 0)},
+		RoleBindings:        []rbacv1.RoleBinding{*controllerutil.NewRoleBindingForClusterRole(subjectPermission, 0, "example-two")},
+	})
+
+	if got, want := roleBindingKeys(plan.CreateRoleBindings), []string{"example-one/exampleClusterRoleName"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got RoleBindings to create %v, want %v", got, want)
+	}
+	if got, want := roleBindingKeys(plan.DeleteRoleBindings), []string{"example-two/exampleClusterRoleName"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got RoleBindings to delete %v, want %v", got, want)
+	}
+	if len(plan.CreateClusterRoleBindings) != 0 || len(plan.DeleteClusterRoleBindings) != 1 {
+		t.Errorf("got %d ClusterRoleBindings to create and %d to delete, want 0 and 1", len(plan.CreateClusterRoleBindings), len(plan.DeleteClusterRoleBindings))
+	}
+	if len(plan.Errors) != 1 {
+		t.Fatalf("got errors %v, want one for the ClusterPermissions", plan.Errors)
+	}
+	if _, ok := plan.Errors[0].(*ClusterPermissionsOutOfScopeError); !ok {
+		t.Errorf("got error %v, want a ClusterPermissionsOutOfScopeError", plan.Errors[0])
+	}
+}
+
+// TestNewHonoursValidity tests that bindings are only desired inside the Validity windows
+// given: a SubjectPermission and a Permission with windows, evaluated before, inside and after them
+// expected: bindings only while the windows are open, existing ones are deleted once they close
+func TestNewHonoursValidity(t *testing.T) {
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hours int) time.Time { return start.Add(time.Duration(hours) * time.Hour) }
+
+	var tests = []struct {
+		now                 time.Time
+		clusterRoleBindings int
+		roleBindings        int
+	}{
+		{at(-1), 0, 0},
+		{at(1), 1, 1},
+		{at(3), 1, 0},
+		{at(5), 0, 0},
+	}
+
+	for _, test := range tests {
+		subjectPermission := mockSubjectPermission()
+		subjectPermission.CreationTimestamp = metav1.Time{Time: start}
+		subjectPermission.Spec.NotBefore = &metav1.Time{Time: start}
+		subjectPermission.Spec.NotAfter = &metav1.Time{Time: at(4)}
+		subjectPermission.Spec.Permissions[0].Duration = &metav1.Duration{Duration: 2 * time.Hour}
+
+		plan := New(Input{
+			SubjectPermission:   subjectPermission,
+			Namespaces:          namespaces("example-one"),
+			ClusterRoles:        clusterRoles("exampleClusterRoleName"),
+			ClusterRoleBindings: []rbacv1.ClusterRoleBinding{*controllerutil.NewClusterRoleBinding(subjectPermission, 0)},
+			RoleBindings:        []rbacv1.RoleBinding{*controllerutil.NewRoleBindingForClusterRole(subjectPermission, 0, "example-one")},
+			Now:                 test.now,
+		})
+		if len(plan.DesiredClusterRoleBindings) != test.clusterRoleBindings || len(plan.DesiredRoleBindings) != test.roleBindings {
+			t.Errorf("at %s: got %d ClusterRoleBindings and %d RoleBindings, want %d and %d", test.now, len(plan.DesiredClusterRoleBindings), len(plan.DesiredRoleBindings), test.clusterRoleBindings, test.roleBindings)
+		}
+		if len(plan.DeleteClusterRoleBindings) != 1-test.clusterRoleBindings || len(plan.DeleteRoleBindings) != 1-test.roleBindings {
+			t.Errorf("at %s: got %d ClusterRoleBindings and %d RoleBindings to delete", test.now, len(plan.DeleteClusterRoleBindings), len(plan.DeleteRoleBindings))
+		}
+	}
+}
+
+// TestNewReportsInventory tests the inventory of the bindings of each entry of the spec
+// given: a SubjectPermission with a ClusterPermission and a Permission, more Namespaces than the sample holds,
+// one of them with a RoleBinding the operator did not generate
+// expected: the counts cover every Namespace, the samples are truncated and each skipped Namespace has a reason
+func TestNewReportsInventory(t *testing.T) {
+	subjectPermission := mockSubjectPermission()
+	var names []string
+	for i := 0; i < InventorySampleSize+5; i++ {
+		names = append(names, fmt.Sprintf("example-%02d", i))
+	}
+	names = append(names, "example-denied", "other")
+	handMade := *controllerutil.NewRoleBindingForClusterRole(subjectPermission, 0, "example-00")
+	handMade.Labels, handMade.Annotations = nil, nil
+
+	plan := New(Input{
+		SubjectPermission: subjectPermission,
+		Namespaces:        namespaces(names...),
+		ClusterRoles:      clusterRoles("exampleClusterRoleName"),
+		RoleBindings:      []rbacv1.RoleBinding{handMade},
+	})
+
+	if len(plan.ClusterPermissions) != 1 || plan.ClusterPermissions[0].BindingName != controllerutil.NewClusterRoleBinding(subjectPermission, 0).Name {
+		t.Errorf("got ClusterPermissions %v, want the ClusterRoleBinding of exampleClusterRoleName", plan.ClusterPermissions)
+	}
+	if len(plan.Permissions) != 1 {
+		t.Fatalf("got %d Permissions, want 1", len(plan.Permissions))
+	}
+	inventory := plan.Permissions[0]
+	if inventory.BindingName != handMade.Name || inventory.MatchedNamespaceCount != InventorySampleSize+4 || len(inventory.MatchedNamespaces) != InventorySampleSize || inventory.MatchedNamespaces[0] != "example-01" {
+		t.Errorf("got binding %s matched in %d Namespaces %v, want %s in %d", inventory.BindingName, inventory.MatchedNamespaceCount, inventory.MatchedNamespaces, handMade.Name, InventorySampleSize+4)
+	}
+	expectedSkipped := []managedv1alpha1.SkippedNamespace{
+		{Name: "example-00", Reason: ReasonNotManaged},
+		{Name: "example-denied", Reason: utility.NamespaceDenied},
+		{Name: "other", Reason: utility.NamespaceNotAllowed},
+	}
+	if inventory.SkippedNamespaceCount != 3 || !reflect.DeepEqual(inventory.SkippedNamespaces, expectedSkipped) {
+		t.Errorf("got skipped %d Namespaces %v, want %v", inventory.SkippedNamespaceCount, inventory.SkippedNamespaces, expectedSkipped)
+	}
+
+	// a SubjectPermission restricted to its own namespace gets no ClusterRoleBinding
+	subjectPermission.Namespace = "example-01"
+	plan = New(Input{SubjectPermission: subjectPermission, Namespaces: namespaces("example-01", "example-02")})
+	if plan.ClusterPermissions[0].Reason != ReasonOutOfScope || plan.ClusterPermissions[0].BindingName != "" {
+		t.Errorf("got ClusterPermissions %v, want it out of scope", plan.ClusterPermissions)
+	}
+	expectedSkipped = []managedv1alpha1.SkippedNamespace{{Name: "example-02", Reason: ReasonOutOfScope}}
+	if !reflect.DeepEqual(plan.Permissions[0].SkippedNamespaces, expectedSkipped) {
+		t.Errorf("got skipped Namespaces %v, want %v", plan.Permissions[0].SkippedNamespaces, expectedSkipped)
+	}
+}
+
+// TestNewSkipsProtectedNamespaces tests that nothing is bound into the protected namespaces
+// given: a Permission allowing two namespaces, one of them protected and holding a generated RoleBinding
+// expected: the RoleBinding is only desired in the other namespace, the protected one is skipped and its RoleBinding deleted
+func TestNewSkipsProtectedNamespaces(t *testing.T) {
+	subjectPermission := mockSubjectPermission()
+	protected := *controllerutil.NewRoleBindingForClusterRole(subjectPermission, 0, "example-two")
+
+	plan := New(Input{
+		SubjectPermission:   subjectPermission,
+		Namespaces:          namespaces("example-one", "example-two"),
+		ClusterRoles:        clusterRoles("exampleClusterRoleName"),
+		RoleBindings:        []rbacv1.RoleBinding{protected},
+		ProtectedNamespaces: "^kube-.*,^example-two$",
+	})
+
+	if len(plan.DesiredRoleBindings) != 1 || plan.DesiredRoleBindings[0].Namespace != "example-one" {
+		t.Errorf("got desired RoleBindings %v, want one in example-one", plan.DesiredRoleBindings)
+	}
+	if len(plan.DeleteRoleBindings) != 1 || plan.DeleteRoleBindings[0].Namespace != "example-two" {
+		t.Errorf("got deleted RoleBindings %v, want the one in example-two", plan.DeleteRoleBindings)
+	}
+	expectedSkipped := []managedv1alpha1.SkippedNamespace{{Name: "example-two", Reason: ReasonProtected}}
+	if !reflect.DeepEqual(plan.Permissions[0].SkippedNamespaces, expectedSkipped) {
+		t.Errorf("got skipped Namespaces %v, want %v", plan.Permissions[0].SkippedNamespaces, expectedSkipped)
+	}
+}
+
+// TestNewHonoursPolicy tests that the ClusterRoles restricted by the Policy are not granted
+// given: a restricted ClusterPermission with its ClusterRoleBinding, and a Permission with wildcard Rules
+// expected: neither is granted, the ClusterRoleBinding is deleted and the restriction that tripped is reported
+func TestNewHonoursPolicy(t *testing.T) {
+	subjectPermission := mockSubjectPermission()
+	subjectPermission.Spec.Permissions[0] = managedv1alpha1.Permission{
+		Rules:                  []rbacv1.PolicyRule{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}},
+		NamespacesAllowedRegex: "^example-.*",
+	}
+
+	plan := New(Input{
+		SubjectPermission:   subjectPermission,
+		Namespaces:          namespaces("example-one"),
+		ClusterRoles:        clusterRoles("exampleClusterRoleName"),
+		ClusterRoleBindings: []rbacv1.ClusterRoleBinding{*controllerutil.NewClusterRoleBinding(subjectPermission, 0)},
+		Policy:              &policy.Policy{RestrictedClusterRoles: []string{"^exampleClusterRoleName$"}, RestrictedRules: []string{policy.RuleWildcard}},
+	})
+
+	if len(plan.DesiredClusterRoleBindings) != 0 || len(plan.DesiredRoleBindings) != 0 || len(plan.CreateClusterRoles) != 0 {
+		t.Errorf("got %d ClusterRoleBindings, %d RoleBindings and %d ClusterRoles desired, want none", len(plan.DesiredClusterRoleBindings), len(plan.DesiredRoleBindings), len(plan.CreateClusterRoles))
+	}
+	if len(plan.DeleteClusterRoleBindings) != 1 {
+		t.Errorf("got %d ClusterRoleBindings deleted, want 1", len(plan.DeleteClusterRoleBindings))
+	}
+	if plan.ClusterPermissions[0].Reason != ReasonRestricted || plan.Permissions[0].Reason != ReasonRestricted {
+		t.Errorf("got reasons %q and %q, want %s", plan.ClusterPermissions[0].Reason, plan.Permissions[0].Reason, ReasonRestricted)
+	}
+	var violations []string
+	for _, err := range plan.Errors {
+		if restricted, ok := err.(*RestrictedClusterRoleError); ok {
+			violations = append(violations, restricted.Violation)
+		}
+	}
+	expected := []string{
+		`ClusterRole exampleClusterRoleName is restricted by "^exampleClusterRoleName$"`,
+		fmt.Sprintf("rule 0 of ClusterRole %s matches the restricted pattern Wildcard", controllerutil.GeneratedClusterRoleName(subjectPermission, 0)),
+	}
+	if !reflect.DeepEqual(violations, expected) {
+		t.Errorf("got violations %v, want %v", violations, expected)
+	}
+}
+
+// TestNewPlansDeletions tests that generated bindings which are no longer desired are deleted
+// given: bindings generated for a removed ClusterPermission and a namespace that is now denied, plus an unrelated binding
+// expected: only the generated bindings are deleted
+func TestNewPlansDeletions(t *testing.T) {
+	subjectPermission := mockSubjectPermission()
+	removed := mockSubjectPermission()
+	removed.Spec.ClusterPermissions = []string{"removedClusterRoleName"}
+
+	unrelated := controllerutil.NewRoleBindingForClusterRole(subjectPermission, 0, "example-denied")
+	unrelated.Name = "unrelated"
+	unrelated.Labels = nil
+
+	plan := New(Input{
+		SubjectPermission: subjectPermission,
+		Namespaces:        namespaces("example-one", "example-denied"),
+		ClusterRoles:      clusterRoles("exampleClusterRoleName"),
+		ClusterRoleBindings: []rbacv1.ClusterRoleBinding{
+			*controllerutil.NewClusterRoleBinding(subjectPermission, 0),
+			*controllerutil.NewClusterRoleBinding(removed, 0),
+		},
+		RoleBindings: []rbacv1.RoleBinding{
+			*controllerutil.NewRoleBindingForClusterRole(subjectPermission, 0, "example-one"),
+			*controllerutil.NewRoleBindingForClusterRole(subjectPermission, 0, "example-denied"),
+			*unrelated,
+		},
+	})
+
+	if got, want := clusterRoleBindingKeys(plan.DeleteClusterRoleBindings), []string{"removedClusterRoleName"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got ClusterRoleBindings to delete %v, want %v", got, want)
+	}
+	if got, want := roleBindingKeys(plan.DeleteRoleBindings), []string{"example-denied/exampleClusterRoleName"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got RoleBindings to delete %v, want %v", got, want)
+	}
+	if len(plan.CreateClusterRoleBindings)+len(plan.CreateRoleBindings) != 0 {
+		t.Errorf("expected nothing to create, got %v and %v", plan.CreateClusterRoleBindings, plan.CreateRoleBindings)
+	}
+}
+
+// legacyBinding returns the ObjectMeta, Subjects and RoleRef of a binding of clusterRoleName to the subject of
+// subjectPermission named the way legacy versions of the operator named them
+func legacyBinding(subjectPermission *managedv1alpha1.SubjectPermission, clusterRoleName, namespace string) (metav1.ObjectMeta, []rbacv1.Subject, rbacv1.RoleRef) {
+	return metav1.ObjectMeta{Name: clusterRoleName + "-" + subjectPermission.Spec.SubjectName, Namespace: namespace},
+		[]rbacv1.Subject{{Kind: subjectPermission.Spec.SubjectKind, Name: subjectPermission.Spec.SubjectName}},
+		rbacv1.RoleRef{Kind: "ClusterRole", Name: clusterRoleName}
+}
+
+// TestNewReplacesLegacyBindings tests the plan for bindings generated by legacy versions of the operator
+// given: unlabeled clusterRoleName-subjectName bindings of the ClusterRole of the SubjectPermission, one of another
+// ClusterRole and one of another subject
+// expected: the legacy bindings of the SubjectPermission are deleted and replaced by generated ones, the others are left alone
+func TestNewReplacesLegacyBindings(t *testing.T) {
+	subjectPermission := mockSubjectPermission()
+
+	meta, subjects, roleRef := legacyBinding(subjectPermission, "exampleClusterRoleName", "")
+	legacyClusterRoleBinding := rbacv1.ClusterRoleBinding{ObjectMeta: meta, Subjects: subjects, RoleRef: roleRef}
+	meta, subjects, roleRef = legacyBinding(subjectPermission, "exampleClusterRoleName", "example-one")
+	legacyRoleBinding := rbacv1.RoleBinding{ObjectMeta: meta, Subjects: subjects, RoleRef: roleRef}
+	meta, subjects, roleRef = legacyBinding(subjectPermission, "otherClusterRoleName", "example-one")
+	otherClusterRole := rbacv1.RoleBinding{ObjectMeta: meta, Subjects: subjects, RoleRef: roleRef}
+	otherSubject := *legacyRoleBinding.DeepCopy()
+	otherSubject.Namespace = "example-two"
+	otherSubject.Subjects[0].Kind = "User"
+
+	plan := New(Input{
+		SubjectPermission:   subjectPermission,
+		Namespaces:          namespaces("example-one", "example-two"),
+		ClusterRoles:        clusterRoles("exampleClusterRoleName", "otherClusterRoleName"),
+		ClusterRoleBindings: []rbacv1.ClusterRoleBinding{legacyClusterRoleBinding},
+		RoleBindings:        []rbacv1.RoleBinding{legacyRoleBinding, otherClusterRole, otherSubject},
+	})
+
+	if len(plan.DeleteClusterRoleBindings) != 1 || plan.DeleteClusterRoleBindings[0].Name != legacyClusterRoleBinding.Name {
+		t.Errorf("got ClusterRoleBindings to delete %v, want %s", plan.DeleteClusterRoleBindings, legacyClusterRoleBinding.Name)
+	}
+	if len(plan.DeleteRoleBindings) != 1 || plan.DeleteRoleBindings[0].Namespace != "example-one" || plan.DeleteRoleBindings[0].Name != legacyRoleBinding.Name {
+		t.Errorf("got RoleBindings to delete %v, want example-one/%s", plan.DeleteRoleBindings, legacyRoleBinding.Name)
+	}
+	if got, want := clusterRoleBindingKeys(plan.CreateClusterRoleBindings), []string{"exampleClusterRoleName"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got ClusterRoleBindings to create %v, want %v", got, want)
+	}
+	if got, want := roleBindingKeys(plan.CreateRoleBindings), []string{"example-one/exampleClusterRoleName", "example-two/exampleClusterRoleName"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got RoleBindings to create %v, want %v", got, want)
+	}
+}
+
+// TestNewPlansDriftRepair tests the plan for generated bindings that were edited
+// given: a ClusterRoleBinding with changed Subjects and a RoleBinding with a changed RoleRef
+// expected: the ClusterRoleBinding is updated, the RoleBinding is deleted and recreated
+func TestNewPlansDriftRepair(t *testing.T) {
+	subjectPermission := mockSubjectPermission()
+
+	clusterRoleBinding := controllerutil.NewClusterRoleBinding(subjectPermission, 0)
+	clusterRoleBinding.Subjects = []rbacv1.Subject{{Kind: "User", Name: "intruder"}}
+	roleBinding := controllerutil.NewRoleBindingForClusterRole(subjectPermission, 0, "example-one")
+	roleBinding.RoleRef.Name = "admin"
+
+	plan := New(Input{
+		SubjectPermission:   subjectPermission,
+		Namespaces:          namespaces("example-one"),
+		ClusterRoles:        clusterRoles("exampleClusterRoleName"),
+		ClusterRoleBindings: []rbacv1.ClusterRoleBinding{*clusterRoleBinding},
+		RoleBindings:        []rbacv1.RoleBinding{*roleBinding},
+	})
+
+	if len(plan.UpdateClusterRoleBindings) != 1 || !reflect.DeepEqual(plan.UpdateClusterRoleBindings[0].Subjects, controllerutil.NewClusterRoleBinding(subjectPermission, 0).Subjects) {
+		t.Errorf("got ClusterRoleBindings to update %v, want the restored one", plan.UpdateClusterRoleBindings)
+	}
+	if got, want := roleBindingKeys(plan.DeleteRoleBindings), []string{"example-one/admin"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got RoleBindings to delete %v, want %v", got, want)
+	}
+	if got, want := roleBindingKeys(plan.CreateRoleBindings), []string{"example-one/exampleClusterRoleName"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got RoleBindings to create %v, want %v", got, want)
+	}
+
+	expectedDrift := []Drift{
+		{Kind: "ClusterRoleBinding", Name: clusterRoleBinding.Name, Reason: DriftSubjects},
+		{Kind: "RoleBinding", Namespace: "example-one", Name: roleBinding.Name, Reason: DriftRoleRef},
+	}
+	if !reflect.DeepEqual(plan.Drift, expectedDrift) {
+		t.Errorf("got drift %v, want %v", plan.Drift, expectedDrift)
+	}
+}
+
+// TestNewReportsErrors tests the errors found while planning
+// given: ClusterRoles that do not exist and a binding name taken by a binding the operator did not generate
+// expected: one MissingClusterRoleError per ClusterRole, an UnmanagedBindingError and no change to the unmanaged binding
+func TestNewReportsErrors(t *testing.T) {
+	subjectPermission := mockSubjectPermission()
+	subjectPermission.Spec.ClusterPermissions = []string{"exampleClusterRoleName", "missingClusterRoleName"}
+
+	unmanaged := controllerutil.NewClusterRoleBinding(subjectPermission, 0)
+	unmanaged.Labels = nil
+	unmanaged.Annotations = nil
+
+	plan := New(Input{
+		SubjectPermission:   subjectPermission,
+		ClusterRoleBindings: []rbacv1.ClusterRoleBinding{*unmanaged},
+	})
+
+	if got, want := plan.MissingClusterRoles(), []string{"exampleClusterRoleName", "missingClusterRoleName"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got missing ClusterRoles %v, want %v", got, want)
+	}
+
+	var unmanagedErrors int
+	for _, err := range plan.Errors {
+		if _, ok := err.(*UnmanagedBindingError); ok {
+			unmanagedErrors++
+		}
+	}
+	if unmanagedErrors != 1 {
+		t.Errorf("got %d UnmanagedBindingErrors, want 1 in %v", unmanagedErrors, plan.Errors)
+	}
+	if len(plan.UpdateClusterRoleBindings)+len(plan.DeleteClusterRoleBindings) != 0 {
+		t.Errorf("expected the unmanaged ClusterRoleBinding to be left alone")
+	}
+}
+
+// TestForRoleBindingsOnlyEvaluatesGivenNamespaces tests the plan restricted to some namespaces
+// given: RoleBindings generated in a namespace that is not evaluated
+// expected: no ClusterRoleBinding planned and the RoleBinding outside the evaluated namespaces is kept
+func TestForRoleBindingsOnlyEvaluatesGivenNamespaces(t *testing.T) {
+	subjectPermission := mockSubjectPermission()
+	subjectPermission.Spec.Permissions[0].NamespacesAllowedRegex = "^example-one$"
+
+	plan := ForRoleBindings(Input{
+		SubjectPermission: subjectPermission,
+		Namespaces:        namespaces("example-one"),
+		ClusterRoles:      clusterRoles("exampleClusterRoleName"),
+		RoleBindings: []rbacv1.RoleBinding{
+			*controllerutil.NewRoleBindingForClusterRole(subjectPermission, 0, "example-two"),
+		},
+	})
+
+	if len(plan.DesiredClusterRoleBindings)+len(plan.CreateClusterRoleBindings) != 0 {
+		t.Errorf("expected no ClusterRoleBinding, got %v", plan.CreateClusterRoleBindings)
+	}
+	if got, want := roleBindingKeys(plan.CreateRoleBindings), []string{"example-one/exampleClusterRoleName"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got RoleBindings to create %v, want %v", got, want)
+	}
+	if len(plan.DeleteRoleBindings) != 0 {
+		t.Errorf("expected no RoleBinding to delete, got %v", roleBindingKeys(plan.DeleteRoleBindings))
+	}
+}
+
+// TestApply tests applying a plan with a fake client
+// given: a plan repairing a RoleRef, revoking a ClusterRoleBinding and creating a RoleBinding
+// expected: the cluster matches the desired bindings, the recreated RoleBinding is not reported as revoked
+// and Preview reports the same changes
+func TestApply(t *testing.T) {
+	ctx := context.TODO()
+	subjectPermission := mockSubjectPermission()
+	removed := mockSubjectPermission()
+	removed.Spec.ClusterPermissions = []string{"removedClusterRoleName"}
+	drifted := controllerutil.NewRoleBindingForClusterRole(subjectPermission, 0, "example-one")
+	drifted.RoleRef.Name = "admin"
+
+	c := fake.NewFakeClient(controllerutil.NewClusterRoleBinding(removed, 0), drifted)
+	plan := New(Input{
+		SubjectPermission:   subjectPermission,
+		Namespaces:          namespaces("example-one", "example-two"),
+		ClusterRoleBindings: []rbacv1.ClusterRoleBinding{*controllerutil.NewClusterRoleBinding(removed, 0)},
+		RoleBindings:        []rbacv1.RoleBinding{*drifted},
+	})
+
+	preview := Preview(plan)
+	result, err := Apply(ctx, c, plan)
+	if err != nil {
+		t.Fatalf("Apply failed: %s", err)
+	}
+	if len(result.Created) != 3 || len(result.Deleted) != 2 {
+		t.Errorf("got %d created and %d deleted, want 3 and 2", len(result.Created), len(result.Deleted))
+	}
+	if !reflect.DeepEqual(preview.Changes(), result.Changes()) {
+		t.Errorf("got preview %v, want the changes of Apply %v", preview.Changes(), result.Changes())
+	}
+	revoked := result.Revoked()
+	if len(revoked) != 1 || revoked[0].ClusterRoleName != "removedClusterRoleName" {
+		t.Errorf("got revoked %v, want only the ClusterRoleBinding for removedClusterRoleName", revoked)
+	}
+
+	crbList := &rbacv1.ClusterRoleBindingList{}
+	if err := c.List(ctx, &client.ListOptions{}, crbList); err != nil {
+		t.Fatalf("Couldn't list ClusterRoleBindings: %s", err)
+	}
+	if len(crbList.Items) != 1 || crbList.Items[0].RoleRef.Name != "exampleClusterRoleName" {
+		t.Errorf("got ClusterRoleBindings %v, want only the one for exampleClusterRoleName", crbList.Items)
+	}
+
+	rbList := &rbacv1.RoleBindingList{}
+	if err := c.List(ctx, &client.ListOptions{}, rbList); err != nil {
+		t.Fatalf("Couldn't list RoleBindings: %s", err)
+	}
+	for _, roleBinding := range rbList.Items {
+		if roleBinding.RoleRef.Name != "exampleClusterRoleName" {
+			t.Errorf("got RoleBinding %s/%s bound to %s, want exampleClusterRoleName", roleBinding.Namespace, roleBinding.Name, roleBinding.RoleRef.Name)
+		}
+	}
+	if len(rbList.Items) != 2 {
+		t.Errorf("got %d RoleBindings, want 2", len(rbList.Items))
+	}
+}
+
+// failingClient fails the creation of the objects named in failCreate
+type failingClient struct {
+	client.Client
+	failCreate map[string]bool
+}
+
+// Create implements client.Client
+func (c *failingClient) Create(ctx context.Context, object runtime.Object) error {
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return err
+	}
+	if c.failCreate[accessor.GetName()] {
+		return fmt.Errorf("create of %s refused", accessor.GetName())
+	}
+	return c.Client.Create(ctx, object)
+}
+
+// TestApplyHandlesAlreadyExists tests applying a plan computed before its bindings showed up
+// given: a plan creating a ClusterRoleBinding and a RoleBinding that already exist, generated for the
+// SubjectPermission with drifted Subjects, and a RoleBinding with the same name generated for another one
+// expected: the drifted ones are updated instead of failing, the one of the other SubjectPermission is an error
+func TestApplyHandlesAlreadyExists(t *testing.T) {
+	ctx := context.TODO()
+	subjectPermission := mockSubjectPermission()
+	clusterRoleBinding := controllerutil.NewClusterRoleBinding(subjectPermission, 0)
+	clusterRoleBinding.Subjects = nil
+	roleBinding := controllerutil.NewRoleBindingForClusterRole(subjectPermission, 0, "example-one")
+	roleBinding.Subjects = nil
+	other := controllerutil.NewRoleBindingForClusterRole(subjectPermission, 0, "example-two")
+	other.Labels[controllerutil.OwnerUIDLabel] = "otherUID"
+
+	c := fake.NewFakeClient(clusterRoleBinding, roleBinding, other)
+	plan := New(Input{SubjectPermission: subjectPermission, Namespaces: namespaces("example-one", "example-two")})
+	if len(plan.CreateClusterRoleBindings) != 1 || len(plan.CreateRoleBindings) != 2 {
+		t.Fatalf("got %d ClusterRoleBindings and %d RoleBindings to create, want 1 and 2", len(plan.CreateClusterRoleBindings), len(plan.CreateRoleBindings))
+	}
+
+	result, err := Apply(ctx, c, plan)
+	if err == nil {
+		t.Errorf("expected an error for the RoleBinding of the other SubjectPermission")
+	}
+	if len(result.Updated) != 2 || len(result.Created) != 0 {
+		t.Errorf("got %d updated and %d created, want 2 and 0", len(result.Updated), len(result.Created))
+	}
+	expectedFailed := []managedv1alpha1.PlannedChange{{Action: ActionCreate, Kind: "RoleBinding", Namespace: "example-two", Name: other.Name}}
+	if !reflect.DeepEqual(result.Failed, expectedFailed) {
+		t.Errorf("got failed %v, want %v", result.Failed, expectedFailed)
+	}
+
+	updated := &rbacv1.RoleBinding{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "example-one", Name: roleBinding.Name}, updated); err != nil {
+		t.Fatalf("Couldn't get RoleBinding: %s", err)
+	}
+	if len(updated.Subjects) != 1 {
+		t.Errorf("got Subjects %v, want the Subject of the SubjectPermission", updated.Subjects)
+	}
+}
+
+// TestApplyContinuesAfterFailure tests that a failed change does not hold back the others
+// given: a plan creating two RoleBindings, one of which fails, and revoking a stale ClusterRoleBinding
+// expected: the other RoleBinding is created and the ClusterRoleBinding deleted, the failure is returned
+// and the failed change is reported as pending
+func TestApplyContinuesAfterFailure(t *testing.T) {
+	ctx := context.TODO()
+	subjectPermission := mockSubjectPermission()
+	subjectPermission.Spec.ClusterPermissions = nil
+	subjectPermission.Spec.Permissions = append(subjectPermission.Spec.Permissions, managedv1alpha1.Permission{ClusterRoleName: "view", NamespacesAllowedRegex: "^example-one$"})
+	removed := mockSubjectPermission()
+	stale := controllerutil.NewClusterRoleBinding(removed, 0)
+	failing := controllerutil.NewRoleBindingForClusterRole(subjectPermission, 0, "example-one")
+
+	c := &failingClient{Client: fake.NewFakeClient(stale), failCreate: map[string]bool{failing.Name: true}}
+	plan := New(Input{
+		SubjectPermission:   subjectPermission,
+		Namespaces:          namespaces("example-one"),
+		ClusterRoleBindings: []rbacv1.ClusterRoleBinding{*stale},
+	})
+
+	result, err := Apply(ctx, c, plan)
+	if err == nil {
+		t.Errorf("expected the failed creation to be returned")
+	}
+	if len(result.Deleted) != 1 || len(result.Created) != 1 || result.Created[0].ClusterRoleName != "view" {
+		t.Errorf("got deleted %v and created %v, want the stale ClusterRoleBinding deleted and the view RoleBinding created", result.Deleted, result.Created)
+	}
+	if len(result.Failed) != 1 || result.Failed[0].Name != failing.Name {
+		t.Errorf("got failed %v, want the RoleBinding %s", result.Failed, failing.Name)
+	}
+
+	crbList := &rbacv1.ClusterRoleBindingList{}
+	if err := c.List(ctx, &client.ListOptions{}, crbList); err != nil {
+		t.Fatalf("Couldn't list ClusterRoleBindings: %s", err)
+	}
+	if len(crbList.Items) != 0 {
+		t.Errorf("got %d ClusterRoleBindings, want the stale one deleted", len(crbList.Items))
+	}
+}
+
+// TestNamespaceMatchingAgrees cross-checks every code path matching namespaces against utility.IsNamespaceAllowed
+// given: combinations of allowed and denied regex, AllowFirst and namespaces, including empty and invalid regex
+// expected: GenerateSafeList, utility.AllowedNamespaces and dedicatedadmin agree with utility.IsNamespaceAllowed, the planner
+// too once an empty allowed regex is defaulted to ".*"
+func TestNamespaceMatchingAgrees(t *testing.T) {
+	regexes := []string{"", ".*", "^openshift-.*", "openshift", "^default$", "(", "^(default|kube-.*)$"}
+	names := []string{"default", "openshift", "openshift-monitoring", "customer-openshift", "kube-system", "somethingelse"}
+	nsList := &corev1.NamespaceList{Items: namespaces(names...)}
+
+	for _, allowed := range regexes {
+		for _, denied := range regexes {
+			for _, allowFirst := range []bool{true, false} {
+				subjectPermission := mockSubjectPermission()
+				subjectPermission.Spec.Permissions[0] = managedv1alpha1.Permission{
+					ClusterRoleName:        "exampleClusterRoleName",
+					NamespacesAllowedRegex: allowed,
+					NamespacesDeniedRegex:  denied,
+					AllowFirst:             allowFirst,
+				}
+
+				var expected []string
+				for _, name := range names {
+					if utility.IsNamespaceAllowed(allowed, denied, allowFirst, name) {
+						expected = append(expected, name)
+					}
+					if dedicatedadmin.IsNamespaceAllowed(allowed, denied, allowFirst, name) != utility.IsNamespaceAllowed(allowed, denied, allowFirst, name) {
+						t.Errorf("dedicatedadmin disagrees for (%q, %q, %t, %s)", allowed, denied, allowFirst, name)
+					}
+				}
+
+				// an empty allowed regex of a Permission allows every namespace
+				permissionAllowed := allowed
+				if permissionAllowed == "" {
+					permissionAllowed = ".*"
+				}
+				want := utility.AllowedNamespaces(permissionAllowed, denied, allowFirst, names)
+				plan := New(Input{SubjectPermission: subjectPermission, Namespaces: nsList.Items})
+				var planned []string
+				for _, roleBinding := range plan.DesiredRoleBindings {
+					planned = append(planned, roleBinding.Namespace)
+				}
+				if !reflect.DeepEqual(planned, want) {
+					t.Errorf("planner (%q, %q, %t): got %v, want %v", allowed, denied, allowFirst, planned, want)
+				}
+				if inventory := plan.Permissions[0]; inventory.MatchedNamespaceCount != len(want) || inventory.SkippedNamespaceCount != len(names)-len(want) {
+					t.Errorf("inventory (%q, %q, %t): got %d matched and %d skipped, want %d and %d", allowed, denied, allowFirst, inventory.MatchedNamespaceCount, inventory.SkippedNamespaceCount, len(want), len(names)-len(want))
+				}
+
+				implementations := map[string][]string{
+					"GenerateSafeList":          controllerutil.GenerateSafeList(allowed, denied, allowFirst, nsList),
+					"utility.AllowedNamespaces": utility.AllowedNamespaces(allowed, denied, allowFirst, names),
+				}
+				for implementation, got := range implementations {
+					if !reflect.DeepEqual(got, expected) {
+						t.Errorf("%s (%q, %q, %t): got %v, want %v", implementation, allowed, denied, allowFirst, got, expected)
+					}
+				}
+			}
+		}
+	}
+}
+
+// TestNewHoldsBackMissingSubjects tests that nothing is granted while a Subject is missing
+// given: a missing Subject, and the ClusterRoleBinding and RoleBinding granted before it went missing
+// expected: no binding is desired, both are deleted and the missing Subject is reported
+func TestNewHoldsBackMissingSubjects(t *testing.T) {
+	subjectPermission := mockSubjectPermission()
+	missing := rbacv1.Subject{Kind: "ServiceAccount", Namespace: "ops", Name: "deployer"}
+
+	plan := New(Input{
+		SubjectPermission:   subjectPermission,
+		Namespaces:          namespaces("example-one"),
+		ClusterRoles:        clusterRoles("exampleClusterRoleName"),
+		ClusterRoleBindings: []rbacv1.ClusterRoleBinding{*controllerutil.NewClusterRoleBinding(subjectPermission, 0)},
+		RoleBindings:        []rbacv1.RoleBinding{*controllerutil.NewRoleBindingForClusterRole(subjectPermission, 0, "example-one")},
+		MissingSubjects:     []rbacv1.Subject{missing},
+	})
+
+	if len(plan.DesiredClusterRoleBindings) != 0 || len(plan.DesiredRoleBindings) != 0 {
+		t.Errorf("got %d ClusterRoleBindings and %d RoleBindings desired, want none", len(plan.DesiredClusterRoleBindings), len(plan.DesiredRoleBindings))
+	}
+	if len(plan.DeleteClusterRoleBindings) != 1 || len(plan.DeleteRoleBindings) != 1 {
+		t.Errorf("got %d ClusterRoleBindings and %d RoleBindings deleted, want 1 of each", len(plan.DeleteClusterRoleBindings), len(plan.DeleteRoleBindings))
+	}
+	if plan.ClusterPermissions[0].Reason != ReasonSubjectNotFound || plan.Permissions[0].Reason != ReasonSubjectNotFound {
+		t.Errorf("got reasons %q and %q, want %q", plan.ClusterPermissions[0].Reason, plan.Permissions[0].Reason, ReasonSubjectNotFound)
+	}
+	expected := []error{&SubjectNotFoundError{Subject: missing}}
+	if !reflect.DeepEqual(plan.Errors, expected) {
+		t.Errorf("got errors %v, want %v", plan.Errors, expected)
+	}
+}
@@ -0,0 +1,328 @@
+// Copyright 2019 RedHat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package policy guards against privilege escalation through SubjectPermissions. The operator ConfigMap
+// lists the restricted ClusterRoles and rule patterns, which are only bound to allowlisted Subjects.
+// It is checked by the admission webhook and by the planner.
+package policy
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	managedv1alpha1 "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	controllerutil "github.com/openshift/rbac-permissions-operator/pkg/controller/utils"
+	"github.com/openshift/rbac-permissions-operator/pkg/dedicatedadmin"
+	"github.com/openshift/rbac-permissions-operator/pkg/utility"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// RestrictedClusterRolesKey is the key of the operator ConfigMap holding the comma separated regexes
+	// of the restricted ClusterRole names
+	RestrictedClusterRolesKey = "restricted_clusterroles"
+	// RestrictedRulesKey is the key of the operator ConfigMap holding the comma separated names of the
+	// restricted rule patterns
+	RestrictedRulesKey = "restricted_rules"
+	// AllowlistedSubjectsKey is the key of the operator ConfigMap holding the comma separated Subjects
+	// restricted ClusterRoles can be bound to, as Kind:name, or ServiceAccount:namespace/name
+	AllowlistedSubjectsKey = "allowlisted_subjects"
+)
+
+// Rule patterns a restricted ClusterRole matches
+const (
+	// RuleWildcard matches rules with * in their verbs, API groups, resources or non-resource URLs
+	RuleWildcard = "Wildcard"
+	// RuleSecrets matches rules granting access to core Secrets
+	RuleSecrets = "Secrets"
+	// RuleEscalate matches rules granting the escalate verb
+	RuleEscalate = "Escalate"
+	// RuleBind matches rules granting the bind verb
+	RuleBind = "Bind"
+	// RuleImpersonate matches rules granting the impersonate verb
+	RuleImpersonate = "Impersonate"
+)
+
+// RulePatterns are the rule patterns that can be restricted, by name
+var RulePatterns = map[string]func(rule rbacv1.PolicyRule) bool{
+	RuleWildcard: func(rule rbacv1.PolicyRule) bool {
+		return controllerutil.ContainsString(rule.Verbs, rbacv1.VerbAll) || controllerutil.ContainsString(rule.APIGroups, rbacv1.APIGroupAll) ||
+			controllerutil.ContainsString(rule.Resources, rbacv1.ResourceAll) || controllerutil.ContainsString(rule.NonResourceURLs, rbacv1.NonResourceAll)
+	},
+	RuleSecrets: func(rule rbacv1.PolicyRule) bool {
+		return (controllerutil.ContainsString(rule.APIGroups, "") || controllerutil.ContainsString(rule.APIGroups, rbacv1.APIGroupAll)) &&
+			(controllerutil.ContainsString(rule.Resources, "secrets") || controllerutil.ContainsString(rule.Resources, rbacv1.ResourceAll))
+	},
+	RuleEscalate:    grantsVerb("escalate"),
+	RuleBind:        grantsVerb("bind"),
+	RuleImpersonate: grantsVerb("impersonate"),
+}
+
+// Policy restricts the ClusterRoles that can be bound, the restricted ones are only bound to allowlisted Subjects.
+// A nil Policy restricts nothing
+type Policy struct {
+	// RestrictedClusterRoles are the regexes of the restricted ClusterRole names
+	RestrictedClusterRoles []string
+	// RestrictedRules are the names of the restricted RulePatterns
+	RestrictedRules []string
+	// AllowlistedSubjects restricted ClusterRoles can be bound to, APIGroup is ignored
+	AllowlistedSubjects []rbacv1.Subject
+}
+
+// Default returns the Policy enforced when the operator's config map does not exist, which is the one
+// deploy/config_map.yaml ships
+func Default() *Policy {
+	return &Policy{
+		RestrictedClusterRoles: []string{"^cluster-admin$"},
+		RestrictedRules:        []string{RuleWildcard, RuleEscalate, RuleBind},
+	}
+}
+
+// Get gets the Policy from the operator's config map, the Default one is enforced when the config map
+// does not exist so that deleting it does not lift every restriction
+func Get(ctx context.Context, k8sClient client.Client) (*Policy, error) {
+	configMap, err := dedicatedadmin.GetOperatorConfig(ctx, k8sClient)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return Default(), nil
+		}
+		return nil, err
+	}
+	return FromConfigMap(configMap)
+}
+
+// FromConfigMap reads the Policy from the operator's config map, it fails on an entry it does not understand
+// rather than enforcing less than configured
+func FromConfigMap(configMap *corev1.ConfigMap) (*Policy, error) {
+	policy := &Policy{}
+	var errs []error
+
+	for _, pattern := range splitList(configMap.Data[RestrictedClusterRolesKey]) {
+		if _, err := regexp.Compile(pattern); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid regex %q: %v", RestrictedClusterRolesKey, pattern, err))
+			continue
+		}
+		policy.RestrictedClusterRoles = append(policy.RestrictedClusterRoles, pattern)
+	}
+	for _, name := range splitList(configMap.Data[RestrictedRulesKey]) {
+		if _, ok := RulePatterns[name]; !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown rule pattern %q", RestrictedRulesKey, name))
+			continue
+		}
+		policy.RestrictedRules = append(policy.RestrictedRules, name)
+	}
+	for _, entry := range splitList(configMap.Data[AllowlistedSubjectsKey]) {
+		subject, err := ParseSubject(entry)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", AllowlistedSubjectsKey, err))
+			continue
+		}
+		policy.AllowlistedSubjects = append(policy.AllowlistedSubjects, subject)
+	}
+
+	return policy, utilerrors.NewAggregate(errs)
+}
+
+// CheckClusterRole returns why the ClusterRole clusterRoleName, with rules, cannot be bound to subjects,
+// or "" when it can. Every Subject must be allowlisted, as they share the bindings
+func (p *Policy) CheckClusterRole(subjects []rbacv1.Subject, clusterRoleName string, rules []rbacv1.PolicyRule) string {
+	if p == nil || p.allowlisted(subjects) {
+		return ""
+	}
+	for _, pattern := range p.RestrictedClusterRoles {
+		if matched, _ := regexp.MatchString(pattern, clusterRoleName); matched {
+			return fmt.Sprintf("ClusterRole %s is restricted by %q", clusterRoleName, pattern)
+		}
+	}
+	for i, rule := range rules {
+		for _, name := range p.RestrictedRules {
+			if RulePatterns[name](rule) {
+				return fmt.Sprintf("rule %d of ClusterRole %s matches the restricted pattern %s", i, clusterRoleName, name)
+			}
+		}
+	}
+	return ""
+}
+
+// CheckClusterPermission returns why the ClusterPermission at index cannot be granted, or "" when it can.
+// The rules of the ClusterRole are looked up in clusterRoles, see checkExisting
+func (p *Policy) CheckClusterPermission(subjectPermission *managedv1alpha1.SubjectPermission, index int, clusterRoles []rbacv1.ClusterRole) string {
+	if p == nil {
+		return ""
+	}
+	clusterRoleName := subjectPermission.Spec.ClusterPermissions[index]
+	return p.checkExisting(utility.SubjectsForSubjectPermission(subjectPermission), clusterRoleName, clusterRoles)
+}
+
+// CheckPermission returns why the Permission at index cannot be granted, or "" when it can. The Rules of
+// the Permission are checked for the ClusterRole generated for them
+func (p *Policy) CheckPermission(subjectPermission *managedv1alpha1.SubjectPermission, index int, clusterRoles []rbacv1.ClusterRole) string {
+	if p == nil {
+		return ""
+	}
+	permission := subjectPermission.Spec.Permissions[index]
+	clusterRoleName := controllerutil.PermissionClusterRoleName(subjectPermission, index)
+	subjects := utility.SubjectsForSubjectPermission(subjectPermission)
+	if len(permission.Rules) > 0 {
+		return p.CheckClusterRole(subjects, clusterRoleName, permission.Rules)
+	}
+	return p.checkExisting(subjects, clusterRoleName, clusterRoles)
+}
+
+// checkExisting returns why the ClusterRole clusterRoleName, looked up in clusterRoles, cannot be bound to subjects.
+// The rules of a ClusterRole that does not exist cannot be checked, it is restricted when there are RestrictedRules
+// so that it is not bound before its rules are known
+func (p *Policy) checkExisting(subjects []rbacv1.Subject, clusterRoleName string, clusterRoles []rbacv1.ClusterRole) string {
+	rules, found := rulesOf(clusterRoles, clusterRoleName)
+	if violation := p.CheckClusterRole(subjects, clusterRoleName, rules); violation != "" || found {
+		return violation
+	}
+	if len(p.RestrictedRules) > 0 && !p.allowlisted(subjects) {
+		return fmt.Sprintf("ClusterRole %s does not exist, its rules cannot be checked against the restricted patterns", clusterRoleName)
+	}
+	return ""
+}
+
+// Validate returns an error for each ClusterPermission and Permission of a SubjectPermission the Policy does not allow
+func (p *Policy) Validate(subjectPermission *managedv1alpha1.SubjectPermission, clusterRoles []rbacv1.ClusterRole) field.ErrorList {
+	return p.validate(subjectPermission, nil, clusterRoles)
+}
+
+// ValidateUpdate is Validate for an update of old to subjectPermission, only the ClusterPermissions and Permissions
+// the update adds or changes are checked, so that a ClusterRole that was deleted or became restricted since they
+// were granted does not block unrelated changes. Everything is checked again when the Subjects change
+func (p *Policy) ValidateUpdate(subjectPermission, old *managedv1alpha1.SubjectPermission, clusterRoles []rbacv1.ClusterRole) field.ErrorList {
+	return p.validate(subjectPermission, old, clusterRoles)
+}
+
+// validate implements Validate and ValidateUpdate, old is nil for Validate
+func (p *Policy) validate(subjectPermission, old *managedv1alpha1.SubjectPermission, clusterRoles []rbacv1.ClusterRole) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if p == nil {
+		return allErrs
+	}
+	if old != nil && !reflect.DeepEqual(utility.SubjectsForSubjectPermission(old), utility.SubjectsForSubjectPermission(subjectPermission)) {
+		old = nil
+	}
+
+	specPath := field.NewPath("spec")
+	for i, clusterRoleName := range subjectPermission.Spec.ClusterPermissions {
+		if old != nil && controllerutil.ContainsString(old.Spec.ClusterPermissions, clusterRoleName) {
+			continue
+		}
+		if violation := p.CheckClusterPermission(subjectPermission, i, clusterRoles); violation != "" {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("clusterPermissions").Index(i), violation))
+		}
+	}
+	for i, permission := range subjectPermission.Spec.Permissions {
+		if old != nil && grantsSameRole(old.Spec.Permissions, permission) {
+			continue
+		}
+		path := specPath.Child("permissions").Index(i).Child("clusterRoleName")
+		if len(permission.Rules) > 0 {
+			path = specPath.Child("permissions").Index(i).Child("rules")
+		}
+		if violation := p.CheckPermission(subjectPermission, i, clusterRoles); violation != "" {
+			allErrs = append(allErrs, field.Forbidden(path, violation))
+		}
+	}
+	return allErrs
+}
+
+// grantsSameRole checks if one of permissions binds the same ClusterRole, or the same Rules, as permission
+func grantsSameRole(permissions []managedv1alpha1.Permission, permission managedv1alpha1.Permission) bool {
+	for _, other := range permissions {
+		if other.ClusterRoleName == permission.ClusterRoleName && reflect.DeepEqual(other.Rules, permission.Rules) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowlisted checks if every one of subjects is allowlisted
+func (p *Policy) allowlisted(subjects []rbacv1.Subject) bool {
+	if len(subjects) == 0 {
+		return false
+	}
+	for _, subject := range subjects {
+		found := false
+		for _, allowed := range p.AllowlistedSubjects {
+			if subject.Kind == allowed.Kind && subject.Name == allowed.Name && subject.Namespace == allowed.Namespace {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseSubject parses Kind:name, or ServiceAccount:namespace/name, as the AllowlistedSubjects are written
+func ParseSubject(entry string) (rbacv1.Subject, error) {
+	parts := strings.SplitN(entry, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return rbacv1.Subject{}, fmt.Errorf("subject %q is not Kind:name", entry)
+	}
+	subject := rbacv1.Subject{Kind: parts[0], Name: parts[1]}
+	switch subject.Kind {
+	case rbacv1.UserKind, rbacv1.GroupKind:
+	case rbacv1.ServiceAccountKind:
+		namespaced := strings.SplitN(subject.Name, "/", 2)
+		if len(namespaced) != 2 || namespaced[0] == "" || namespaced[1] == "" {
+			return rbacv1.Subject{}, fmt.Errorf("subject %q is not ServiceAccount:namespace/name", entry)
+		}
+		subject.Namespace, subject.Name = namespaced[0], namespaced[1]
+	default:
+		return rbacv1.Subject{}, fmt.Errorf("subject %q has an unsupported kind", entry)
+	}
+	return subject, nil
+}
+
+// rulesOf returns the rules of the ClusterRole clusterRoleName in clusterRoles, and whether it was found
+func rulesOf(clusterRoles []rbacv1.ClusterRole, clusterRoleName string) ([]rbacv1.PolicyRule, bool) {
+	for _, clusterRole := range clusterRoles {
+		if clusterRole.Name == clusterRoleName {
+			return clusterRole.Rules, true
+		}
+	}
+	return nil, false
+}
+
+// grantsVerb returns a rule pattern matching rules granting verb
+func grantsVerb(verb string) func(rule rbacv1.PolicyRule) bool {
+	return func(rule rbacv1.PolicyRule) bool {
+		return controllerutil.ContainsString(rule.Verbs, verb) || controllerutil.ContainsString(rule.Verbs, rbacv1.VerbAll)
+	}
+}
+
+// splitList splits a comma separated list, dropping blank entries
+func splitList(list string) []string {
+	var entries []string
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
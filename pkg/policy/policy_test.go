@@ -0,0 +1,221 @@
+package policy
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	operatorconfig "github.com/openshift/rbac-permissions-operator/config"
+	managedv1alpha1 "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// TestFromConfigMap tests reading the Policy from the operator's config map
+// given: valid entries mixed with an invalid regex, an unknown rule pattern and malformed subjects
+// expected: the valid entries are read and every invalid one is reported
+func TestFromConfigMap(t *testing.T) {
+	configMap := &corev1.ConfigMap{Data: map[string]string{
+		RestrictedClusterRolesKey: "^cluster-admin$, (",
+		RestrictedRulesKey:        "Wildcard,Secrets,Everything",
+		AllowlistedSubjectsKey:    "Group:sre, ServiceAccount:ops/deployer,ServiceAccount:deployer,Robot:r2d2,dev",
+	}}
+
+	policy, err := FromConfigMap(configMap)
+	expected := &Policy{
+		RestrictedClusterRoles: []string{"^cluster-admin$"},
+		RestrictedRules:        []string{RuleWildcard, RuleSecrets},
+		AllowlistedSubjects: []rbacv1.Subject{
+			{Kind: "Group", Name: "sre"},
+			{Kind: "ServiceAccount", Namespace: "ops", Name: "deployer"},
+		},
+	}
+	if !reflect.DeepEqual(policy, expected) {
+		t.Errorf("got policy %+v, want %+v", policy, expected)
+	}
+	if err == nil || len(err.(interface{ Errors() []error }).Errors()) != 5 {
+		t.Errorf("got error %v, want 5 invalid entries", err)
+	}
+}
+
+// TestCheckClusterRole tests the restrictions of a Policy
+// given: a Policy restricting cluster-admin and every rule pattern, allowlisting the group sre
+// expected: the restriction tripped by each ClusterRole is named, unless every subject is allowlisted
+func TestCheckClusterRole(t *testing.T) {
+	policy := &Policy{
+		RestrictedClusterRoles: []string{"^cluster-admin$"},
+		RestrictedRules:        []string{RuleWildcard, RuleSecrets, RuleEscalate, RuleBind, RuleImpersonate},
+		AllowlistedSubjects:    []rbacv1.Subject{{Kind: "Group", Name: "sre"}},
+	}
+	dev := []rbacv1.Subject{{Kind: "Group", Name: "dev"}}
+	sre := []rbacv1.Subject{{Kind: "Group", Name: "sre", APIGroup: rbacv1.GroupName}}
+
+	var tests = []struct {
+		label    string
+		subjects []rbacv1.Subject
+		name     string
+		rules    []rbacv1.PolicyRule
+		expected string
+	}{
+		{"restricted name", dev, "cluster-admin", nil, `ClusterRole cluster-admin is restricted by "^cluster-admin$"`},
+		{"view", dev, "view", []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}}}, ""},
+		{"wildcard", dev, "all", []rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}},
+			{APIGroups: []string{"apps"}, Resources: []string{"*"}, Verbs: []string{"get"}},
+		}, "rule 1 of ClusterRole all matches the restricted pattern Wildcard"},
+		{"secrets", dev, "secrets", []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}}}, "rule 0 of ClusterRole secrets matches the restricted pattern Secrets"},
+		{"secrets of another group", dev, "other", []rbacv1.PolicyRule{{APIGroups: []string{"example.com"}, Resources: []string{"secrets"}, Verbs: []string{"get"}}}, ""},
+		{"escalate", dev, "escalate", []rbacv1.PolicyRule{{APIGroups: []string{"rbac.authorization.k8s.io"}, Resources: []string{"roles"}, Verbs: []string{"escalate"}}}, "rule 0 of ClusterRole escalate matches the restricted pattern Escalate"},
+		{"bind", dev, "bind", []rbacv1.PolicyRule{{APIGroups: []string{"rbac.authorization.k8s.io"}, Resources: []string{"roles"}, Verbs: []string{"bind"}}}, "rule 0 of ClusterRole bind matches the restricted pattern Bind"},
+		{"impersonate", dev, "impersonate", []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"users"}, Verbs: []string{"impersonate"}}}, "rule 0 of ClusterRole impersonate matches the restricted pattern Impersonate"},
+		{"allowlisted", sre, "cluster-admin", nil, ""},
+		{"partly allowlisted", append(sre, dev...), "cluster-admin", nil, `ClusterRole cluster-admin is restricted by "^cluster-admin$"`},
+	}
+
+	for _, test := range tests {
+		if violation := policy.CheckClusterRole(test.subjects, test.name, test.rules); violation != test.expected {
+			t.Errorf("%s: got %q, want %q", test.label, violation, test.expected)
+		}
+	}
+
+	var nilPolicy *Policy
+	if violation := nilPolicy.CheckClusterRole(dev, "cluster-admin", nil); violation != "" {
+		t.Errorf("nil policy: got %q, want nothing restricted", violation)
+	}
+}
+
+// TestValidate tests the field paths of the restricted entries of a SubjectPermission
+// given: a ClusterPermission of cluster-admin, a Permission of view and a Permission with wildcard Rules
+// expected: the ClusterPermission and the Rules are forbidden
+func TestValidate(t *testing.T) {
+	policy := &Policy{RestrictedClusterRoles: []string{"^cluster-admin$"}, RestrictedRules: []string{RuleWildcard}}
+	subjectPermission := &managedv1alpha1.SubjectPermission{
+		ObjectMeta: metav1.ObjectMeta{Name: "testSubjectPermission", Namespace: "openshift-rbac-permissions-operator"},
+		Spec: managedv1alpha1.SubjectPermissionSpec{
+			SubjectKind:        "Group",
+			SubjectName:        "dev",
+			ClusterPermissions: []string{"cluster-admin"},
+			Permissions: []managedv1alpha1.Permission{
+				{ClusterRoleName: "view", NamespacesAllowedRegex: ".*"},
+				{Rules: []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"*"}, Verbs: []string{"get"}}}, NamespacesAllowedRegex: ".*"},
+			},
+		},
+	}
+	clusterRoles := []rbacv1.ClusterRole{{ObjectMeta: metav1.ObjectMeta{Name: "view"}}}
+
+	var paths []string
+	for _, err := range policy.Validate(subjectPermission, clusterRoles) {
+		paths = append(paths, err.Field)
+	}
+	expected := []string{"spec.clusterPermissions[0]", "spec.permissions[1].rules"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("got errors for %v, want %v", paths, expected)
+	}
+}
+
+// TestValidateMissingClusterRole tests that a ClusterRole that does not exist does not bypass the RestrictedRules
+// given: a Permission and a ClusterPermission of ClusterRoles that do not exist
+// expected: both are forbidden with RestrictedRules, unless the Subject is allowlisted, and allowed without them
+func TestValidateMissingClusterRole(t *testing.T) {
+	subjectPermission := &managedv1alpha1.SubjectPermission{
+		ObjectMeta: metav1.ObjectMeta{Name: "testSubjectPermission", Namespace: "openshift-rbac-permissions-operator"},
+		Spec: managedv1alpha1.SubjectPermissionSpec{
+			SubjectKind:        "Group",
+			SubjectName:        "dev",
+			ClusterPermissions: []string{"future-reader"},
+			Permissions:        []managedv1alpha1.Permission{{ClusterRoleName: "future-editor", NamespacesAllowedRegex: ".*"}},
+		},
+	}
+
+	var tests = []struct {
+		label    string
+		policy   *Policy
+		expected []string
+	}{
+		{"restricted rules", &Policy{RestrictedRules: []string{RuleWildcard}}, []string{"spec.clusterPermissions[0]", "spec.permissions[0].clusterRoleName"}},
+		{"allowlisted", &Policy{RestrictedRules: []string{RuleWildcard}, AllowlistedSubjects: []rbacv1.Subject{{Kind: "Group", Name: "dev"}}}, nil},
+		{"restricted names only", &Policy{RestrictedClusterRoles: []string{"^cluster-admin$"}}, nil},
+	}
+
+	for _, test := range tests {
+		var paths []string
+		for _, err := range test.policy.Validate(subjectPermission, nil) {
+			paths = append(paths, err.Field)
+		}
+		if !reflect.DeepEqual(paths, test.expected) {
+			t.Errorf("%s: got errors for %v, want %v", test.label, paths, test.expected)
+		}
+	}
+}
+
+// TestGet tests reading the Policy from the cluster
+// given: a cluster with and without the operator's config map
+// expected: the Policy of the config map, or the Default one without it
+func TestGet(t *testing.T) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: operatorconfig.OperatorConfigMapName, Namespace: operatorconfig.OperatorNamespace},
+		Data:       map[string]string{RestrictedRulesKey: RuleSecrets},
+	}
+
+	var tests = []struct {
+		label    string
+		objects  []runtime.Object
+		expected *Policy
+	}{
+		{"config map", []runtime.Object{configMap}, &Policy{RestrictedRules: []string{RuleSecrets}}},
+		{"no config map", nil, Default()},
+	}
+
+	for _, test := range tests {
+		policy, err := Get(context.TODO(), fake.NewFakeClient(test.objects...))
+		if err != nil {
+			t.Fatalf("%s: Couldn't get the policy: %s", test.label, err)
+		}
+		if !reflect.DeepEqual(policy, test.expected) {
+			t.Errorf("%s: got policy %+v, want %+v", test.label, policy, test.expected)
+		}
+	}
+}
+
+// TestValidateUpdate tests that an update is only checked for the entries it adds or changes
+// given: a SubjectPermission granting restricted ClusterRoles, updated to add another one, and to change its Subject
+// expected: only the added entry is forbidden, and every restricted one once the Subject changed
+func TestValidateUpdate(t *testing.T) {
+	policy := &Policy{RestrictedClusterRoles: []string{"^cluster-admin$", "^admin$"}, RestrictedRules: []string{RuleWildcard}}
+	old := &managedv1alpha1.SubjectPermission{
+		ObjectMeta: metav1.ObjectMeta{Name: "testSubjectPermission", Namespace: "openshift-rbac-permissions-operator"},
+		Spec: managedv1alpha1.SubjectPermissionSpec{
+			SubjectKind:        "Group",
+			SubjectName:        "dev",
+			ClusterPermissions: []string{"cluster-admin"},
+			Permissions:        []managedv1alpha1.Permission{{ClusterRoleName: "deleted", NamespacesAllowedRegex: ".*"}},
+		},
+	}
+	added := old.DeepCopy()
+	added.Spec.Permissions[0].NamespacesAllowedRegex = "^team-"
+	added.Spec.Permissions = append(added.Spec.Permissions, managedv1alpha1.Permission{ClusterRoleName: "admin", NamespacesAllowedRegex: ".*"})
+	resubjected := added.DeepCopy()
+	resubjected.Spec.SubjectName = "ops"
+
+	var tests = []struct {
+		label         string
+		updated       *managedv1alpha1.SubjectPermission
+		expectedPaths []string
+	}{
+		{"added permission", added, []string{"spec.permissions[1].clusterRoleName"}},
+		{"changed subject", resubjected, []string{"spec.clusterPermissions[0]", "spec.permissions[0].clusterRoleName", "spec.permissions[1].clusterRoleName"}},
+	}
+
+	for _, test := range tests {
+		var paths []string
+		for _, err := range policy.ValidateUpdate(test.updated, old, nil) {
+			paths = append(paths, err.Field)
+		}
+		if !reflect.DeepEqual(paths, test.expectedPaths) {
+			t.Errorf("%s: got errors for %v, want %v", test.label, paths, test.expectedPaths)
+		}
+	}
+}
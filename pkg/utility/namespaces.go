@@ -15,19 +15,28 @@
 package utility
 
 import (
+	"fmt"
 	"regexp"
+	"sync"
+
+	api "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
-// GetAllowedNamespaces returns a list of all namespaces that are allowed based on the input data.  Empty string regex is treated as unset.
+// IsNamespaceAllowed checks a namespace against the allowed and denied regex of a Permission.  Empty string regex is treated as unset.
+// The regex are not anchored, so "openshift" matches "openshift-monitoring" and anchors have to be part of the regex.
+// An invalid regex never matches for allowed and always matches for denied, so a bad Permission grants nothing.
+// This is the only implementation of the namespace matching, everything else must go through it.
 func IsNamespaceAllowed(namespacesAllowedRegex string, namespacesDeniedRegex string, allowFirst bool, namespace string) bool {
 	if allowFirst && namespacesAllowedRegex != "" {
 		// check allow first
 		// NOTE if allowed regex is missing nothing is allowed
-		allowed, _ := regexp.MatchString(namespacesAllowedRegex, namespace)
+		allowed := matches(namespacesAllowedRegex, namespace, false)
 		if allowed && namespacesDeniedRegex != "" {
 			// it's allowed.  now check that it is not denied.
-			denied, _ := regexp.MatchString(namespacesDeniedRegex, namespace)
-			if denied {
+			if matches(namespacesDeniedRegex, namespace, true) {
 				// it's denied
 				return false
 			}
@@ -38,16 +47,14 @@ func IsNamespaceAllowed(namespacesAllowedRegex string, namespacesDeniedRegex str
 		// check deny first
 		// NOTE if deny regex is missing only the allowed regex applies
 		if namespacesDeniedRegex != "" {
-			denied, _ := regexp.MatchString(namespacesDeniedRegex, namespace)
-			if denied {
+			if matches(namespacesDeniedRegex, namespace, true) {
 				// it's denied
 				return false
 			}
 		}
 		// it was not denied, check if it's allowed
 		if namespacesAllowedRegex != "" {
-			allowed, _ := regexp.MatchString(namespacesAllowedRegex, namespace)
-			if allowed {
+			if matches(namespacesAllowedRegex, namespace, false) {
 				// it's allowed
 				return true
 			}
@@ -56,3 +63,136 @@ func IsNamespaceAllowed(namespacesAllowedRegex string, namespacesDeniedRegex str
 	// it was not denied or allowed (implies it was denied, default behavior)
 	return false
 }
+
+// AllowedNamespaces returns the namespaces, in order, that are allowed by IsNamespaceAllowed
+func AllowedNamespaces(namespacesAllowedRegex string, namespacesDeniedRegex string, allowFirst bool, namespaces []string) []string {
+	var allowed []string
+	for _, namespace := range namespaces {
+		if IsNamespaceAllowed(namespacesAllowedRegex, namespacesDeniedRegex, allowFirst, namespace) {
+			allowed = append(allowed, namespace)
+		}
+	}
+	return allowed
+}
+
+// IsNamespaceMatched checks a namespace against the regexes and the NamespaceSelector of a Permission.
+// When a NamespaceSelector is set the namespace labels must match it. An empty allowed regex allows every name,
+// see permissionAllowedRegex. An invalid NamespaceSelector matches nothing.
+func IsNamespaceMatched(permission api.Permission, namespace *corev1.Namespace) bool {
+	if permission.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(permission.NamespaceSelector)
+		if err != nil || !selector.Matches(labels.Set(namespace.Labels)) {
+			return false
+		}
+	}
+	return IsNamespaceAllowed(permissionAllowedRegex(permission), permission.NamespacesDeniedRegex, permission.AllowFirst, namespace.Name)
+}
+
+// permissionAllowedRegex returns the allowed regex of a Permission, defaulting an empty one to ".*" as the
+// operator always has. IsNamespaceAllowed itself allows nothing without an allowed regex
+func permissionAllowedRegex(permission api.Permission) string {
+	if permission.NamespacesAllowedRegex == "" {
+		return ".*"
+	}
+	return permission.NamespacesAllowedRegex
+}
+
+// Reasons a namespace is not matched by a Permission, see NamespaceMismatchReason
+const (
+	// NamespaceNotSelected is used when the labels of the namespace do not match the NamespaceSelector
+	NamespaceNotSelected = "NotSelected"
+	// NamespaceDenied is used when the name of the namespace matches NamespacesDeniedRegex
+	NamespaceDenied = "Denied"
+	// NamespaceNotAllowed is used when the name of the namespace does not match NamespacesAllowedRegex
+	NamespaceNotAllowed = "NotAllowed"
+)
+
+// NamespaceMismatchReason explains why IsNamespaceMatched does not match a namespace, it is empty when it matches
+func NamespaceMismatchReason(permission api.Permission, namespace *corev1.Namespace) string {
+	if IsNamespaceMatched(permission, namespace) {
+		return ""
+	}
+	if permission.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(permission.NamespaceSelector)
+		if err != nil || !selector.Matches(labels.Set(namespace.Labels)) {
+			return NamespaceNotSelected
+		}
+	}
+	// the regex applied first decides
+	if permission.AllowFirst && !matches(permissionAllowedRegex(permission), namespace.Name, false) {
+		return NamespaceNotAllowed
+	}
+	if permission.NamespacesDeniedRegex != "" && matches(permission.NamespacesDeniedRegex, namespace.Name, true) {
+		return NamespaceDenied
+	}
+	return NamespaceNotAllowed
+}
+
+// ExplainNamespaceMatch describes which regex, or the NamespaceSelector, decided whether a Permission matches
+// a namespace, following IsNamespaceMatched
+func ExplainNamespaceMatch(permission api.Permission, namespace *corev1.Namespace) string {
+	namespacesAllowedRegex := permissionAllowedRegex(permission)
+	selected := ""
+	if permission.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(permission.NamespaceSelector)
+		if err != nil {
+			return fmt.Sprintf("NamespaceSelector is invalid: %v", err)
+		}
+		if !selector.Matches(labels.Set(namespace.Labels)) {
+			return fmt.Sprintf("labels do not match NamespaceSelector %q", selector)
+		}
+		selected = fmt.Sprintf("labels match NamespaceSelector %q, ", selector)
+	}
+
+	order := "NamespacesDeniedRegex is checked first"
+	if permission.AllowFirst {
+		order = "AllowFirst checks NamespacesAllowedRegex first"
+	}
+	switch NamespaceMismatchReason(permission, namespace) {
+	case "":
+		if permission.NamespacesDeniedRegex == "" {
+			return fmt.Sprintf("%sallowed by NamespacesAllowedRegex %q", selected, namespacesAllowedRegex)
+		}
+		return fmt.Sprintf("%sallowed by NamespacesAllowedRegex %q and not denied by NamespacesDeniedRegex %q", selected, namespacesAllowedRegex, permission.NamespacesDeniedRegex)
+	case NamespaceDenied:
+		return fmt.Sprintf("%sdenied by NamespacesDeniedRegex %q, %s", selected, permission.NamespacesDeniedRegex, order)
+	}
+	return fmt.Sprintf("%snot allowed by NamespacesAllowedRegex %q", selected, namespacesAllowedRegex)
+}
+
+// MatchedNamespaces returns the names of the namespaces, in order, that are matched by IsNamespaceMatched
+func MatchedNamespaces(permission api.Permission, namespaces []corev1.Namespace) []string {
+	var matched []string
+	for i := range namespaces {
+		if IsNamespaceMatched(permission, &namespaces[i]) {
+			matched = append(matched, namespaces[i].Name)
+		}
+	}
+	return matched
+}
+
+// matches checks namespace against regex, returning onError if regex does not compile
+func matches(regex string, namespace string, onError bool) bool {
+	pattern := compile(regex)
+	if pattern == nil {
+		return onError
+	}
+	return pattern.MatchString(namespace)
+}
+
+// compiledRegexes caches the regexes compiled by compile, by regex
+var compiledRegexes sync.Map
+
+// compile compiles each regex of the Permissions once, as every namespace is matched against them on each reconcile.
+// An invalid regex is nil
+func compile(regex string) *regexp.Regexp {
+	if pattern, ok := compiledRegexes.Load(regex); ok {
+		return pattern.(*regexp.Regexp)
+	}
+	pattern, err := regexp.Compile(regex)
+	if err != nil {
+		pattern = nil
+	}
+	compiledRegexes.Store(regex, pattern)
+	return pattern
+}
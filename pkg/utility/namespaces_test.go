@@ -16,6 +16,10 @@ package utility
 
 import (
 	"testing"
+
+	api "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestIsNamespaceAllowed(t *testing.T) {
@@ -75,6 +79,17 @@ func TestIsNamespaceAllowed(t *testing.T) {
 		{".*", "^(default|openshift.*|kube.*)$", false, "openshift-monitoring", false},
 		{".*", "^(default|openshift.*|kube.*)$", false, "somethingelse", true},
 		{".*", "^(default|openshift.*|kube.*)$", false, "customer-openshift", true},
+
+		// regex are not anchored
+		{"openshift", "", false, "customer-openshift-dev", true},
+		{"^openshift$", "", false, "customer-openshift-dev", false},
+		{".*", "kube", true, "my-kube-ns", false},
+
+		// invalid regex grant nothing
+		{"(", "", true, "somethingelse", false},
+		{"(", "", false, "somethingelse", false},
+		{".*", "(", true, "somethingelse", false},
+		{".*", "(", false, "somethingelse", false},
 	}
 	for _, test := range tests {
 		if IsNamespaceAllowed(test.namespacesAllowedRegex, test.namespacesDeniedRegex, test.allowFirst, test.namespace) != test.valid {
@@ -82,3 +97,67 @@ func TestIsNamespaceAllowed(t *testing.T) {
 		}
 	}
 }
+
+func TestNamespaceMismatchReason(t *testing.T) {
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}}
+	var tests = []struct {
+		permission api.Permission
+		labels     map[string]string
+		namespace  string
+		reason     string
+	}{
+		{api.Permission{NamespacesAllowedRegex: "^team-.*"}, nil, "team-a", ""},
+		{api.Permission{NamespacesAllowedRegex: "^team-.*"}, nil, "other", NamespaceNotAllowed},
+		{api.Permission{NamespacesAllowedRegex: "^team-.*", NamespacesDeniedRegex: "-b$"}, nil, "team-b", NamespaceDenied},
+		{api.Permission{NamespacesAllowedRegex: "^team-.*", NamespacesDeniedRegex: "^other", AllowFirst: true}, nil, "other", NamespaceNotAllowed},
+		{api.Permission{NamespaceSelector: selector}, map[string]string{"team": "a"}, "other", ""},
+		{api.Permission{NamespaceSelector: selector}, map[string]string{"team": "b"}, "team-a", NamespaceNotSelected},
+		{api.Permission{NamespacesDeniedRegex: "-b$"}, nil, "team-a", ""},
+		{api.Permission{NamespacesDeniedRegex: "-b$", AllowFirst: true}, nil, "team-b", NamespaceDenied},
+	}
+	for _, test := range tests {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: test.namespace, Labels: test.labels}}
+		if reason := NamespaceMismatchReason(test.permission, namespace); reason != test.reason {
+			t.Errorf("FAILURE: NamespaceMismatchReason(%v, %s) = %q, expected = %q", test.permission, test.namespace, reason, test.reason)
+		}
+	}
+}
+
+func TestExplainNamespaceMatch(t *testing.T) {
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}}
+	var tests = []struct {
+		permission  api.Permission
+		labels      map[string]string
+		namespace   string
+		explanation string
+	}{
+		{api.Permission{NamespacesAllowedRegex: "^team-.*"}, nil, "team-a", `allowed by NamespacesAllowedRegex "^team-.*"`},
+		{api.Permission{NamespacesAllowedRegex: "^team-.*", NamespacesDeniedRegex: "-b$"}, nil, "team-a", `allowed by NamespacesAllowedRegex "^team-.*" and not denied by NamespacesDeniedRegex "-b$"`},
+		{api.Permission{NamespacesAllowedRegex: "^team-.*"}, nil, "other", `not allowed by NamespacesAllowedRegex "^team-.*"`},
+		{api.Permission{NamespacesAllowedRegex: "^team-.*", NamespacesDeniedRegex: "-b$"}, nil, "team-b", `denied by NamespacesDeniedRegex "-b$", NamespacesDeniedRegex is checked first`},
+		{api.Permission{NamespacesAllowedRegex: "^team-.*", NamespacesDeniedRegex: "-b$", AllowFirst: true}, nil, "team-b", `denied by NamespacesDeniedRegex "-b$", AllowFirst checks NamespacesAllowedRegex first`},
+		{api.Permission{NamespacesDeniedRegex: "-b$"}, nil, "team-a", `allowed by NamespacesAllowedRegex ".*" and not denied by NamespacesDeniedRegex "-b$"`},
+		{api.Permission{NamespacesDeniedRegex: "-b$"}, nil, "team-b", `denied by NamespacesDeniedRegex "-b$", NamespacesDeniedRegex is checked first`},
+		{api.Permission{NamespaceSelector: selector}, map[string]string{"team": "a"}, "other", `labels match NamespaceSelector "team=a", allowed by NamespacesAllowedRegex ".*"`},
+		{api.Permission{NamespaceSelector: selector}, map[string]string{"team": "b"}, "team-a", `labels do not match NamespaceSelector "team=a"`},
+	}
+	for _, test := range tests {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: test.namespace, Labels: test.labels}}
+		if explanation := ExplainNamespaceMatch(test.permission, namespace); explanation != test.explanation {
+			t.Errorf("FAILURE: ExplainNamespaceMatch(%v, %s) = %q, expected = %q", test.permission, test.namespace, explanation, test.explanation)
+		}
+	}
+}
+
+// TestCompile tests that each regex is compiled once, an invalid one included
+func TestCompile(t *testing.T) {
+	if compile("^team-.*") != compile("^team-.*") {
+		t.Errorf("FAILURE: compile(%q) compiled the regex again", "^team-.*")
+	}
+	if pattern := compile("("); pattern != nil {
+		t.Errorf("FAILURE: compile(%q) = %v, expected = nil", "(", pattern)
+	}
+	if !matches("(", "team-a", true) || matches("(", "team-a", false) {
+		t.Errorf("FAILURE: matches(%q) did not return onError", "(")
+	}
+}
@@ -15,6 +15,7 @@
 package utility
 
 import (
+	operatorconfig "github.com/openshift/rbac-permissions-operator/config"
 	api "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -31,19 +32,17 @@ func GetRoleBindingsForSubjectPermissions() {
 func GetClusterRoleBindingsForSubjectPermissions(groupPermissions []api.SubjectPermission) []rbacv1.ClusterRoleBinding {
 	var output []rbacv1.ClusterRoleBinding
 
-	for _, groupPermission := range groupPermissions {
-		for _, clusterPermission := range groupPermission.Spec.ClusterPermissions {
+	for i := range groupPermissions {
+		subjects := SubjectsForSubjectPermission(&groupPermissions[i])
+		if len(subjects) == 0 {
+			continue
+		}
+		for _, clusterPermission := range groupPermissions[i].Spec.ClusterPermissions {
 			crb := rbacv1.ClusterRoleBinding{
 				ObjectMeta: metav1.ObjectMeta{
-					Name: groupPermission.Spec.SubjectName + "-" + clusterPermission,
-				},
-				Subjects: []rbacv1.Subject{
-					{
-						APIGroup: "rbac.authorization.k8s.io",
-						Kind:     "Group",
-						Name:     groupPermission.Spec.SubjectName,
-					},
+					Name: subjects[0].Name + "-" + clusterPermission,
 				},
+				Subjects: subjects,
 				RoleRef: rbacv1.RoleRef{
 					APIGroup: "rbac.authorization.k8s.io",
 					Kind:     "ClusterRole",
@@ -56,3 +55,74 @@ func GetClusterRoleBindingsForSubjectPermissions(groupPermissions []api.SubjectP
 
 	return output
 }
+
+// SubjectsForSubjectPermission returns the Subjects granted permissions by a SubjectPermission: the one set by
+// SubjectKind and SubjectName, if any, followed by Subjects. APIGroup is defaulted for each kind and duplicates are dropped.
+func SubjectsForSubjectPermission(subjectPermission *api.SubjectPermission) []rbacv1.Subject {
+	var candidates []rbacv1.Subject
+	if subjectPermission.Spec.SubjectKind != "" || subjectPermission.Spec.SubjectName != "" {
+		candidates = append(candidates, rbacv1.Subject{
+			Kind:      subjectPermission.Spec.SubjectKind,
+			Name:      subjectPermission.Spec.SubjectName,
+			Namespace: subjectPermission.Spec.SubjectNamespace,
+		})
+	}
+	candidates = append(candidates, subjectPermission.Spec.Subjects...)
+
+	var subjects []rbacv1.Subject
+	for _, subject := range candidates {
+		if subject.APIGroup == "" {
+			subject.APIGroup = DefaultSubjectAPIGroup(subject.Kind)
+		}
+		if !containsSubject(subjects, subject) {
+			subjects = append(subjects, subject)
+		}
+	}
+	return subjects
+}
+
+// IsNamespaceRestricted checks if a SubjectPermission may only bind inside its own namespace.
+// Only ClusterSubjectPermissions, which have no namespace, and SubjectPermissions in the
+// operator namespace may grant ClusterPermissions and Permissions in other namespaces.
+func IsNamespaceRestricted(subjectPermission *api.SubjectPermission) bool {
+	return subjectPermission.Namespace != "" && subjectPermission.Namespace != operatorconfig.OperatorNamespace
+}
+
+// SubjectPermissionForCluster returns a SubjectPermission sharing the metadata, spec and status of a
+// ClusterSubjectPermission, so that both kinds are planned, validated and measured the same way
+func SubjectPermissionForCluster(clusterSubjectPermission *api.ClusterSubjectPermission) *api.SubjectPermission {
+	return &api.SubjectPermission{
+		ObjectMeta: clusterSubjectPermission.ObjectMeta,
+		Spec:       clusterSubjectPermission.Spec,
+		Status:     clusterSubjectPermission.Status,
+	}
+}
+
+// ClusterSubjectPermissionFor returns the ClusterSubjectPermission a SubjectPermission returned by
+// SubjectPermissionForCluster stands for, with its metadata, spec and status
+func ClusterSubjectPermissionFor(subjectPermission *api.SubjectPermission) *api.ClusterSubjectPermission {
+	return &api.ClusterSubjectPermission{
+		ObjectMeta: subjectPermission.ObjectMeta,
+		Spec:       subjectPermission.Spec,
+		Status:     subjectPermission.Status,
+	}
+}
+
+// DefaultSubjectAPIGroup returns the APIGroup of a Subject of the given kind
+func DefaultSubjectAPIGroup(kind string) string {
+	switch kind {
+	case rbacv1.UserKind, rbacv1.GroupKind:
+		return rbacv1.GroupName
+	}
+	return ""
+}
+
+// containsSubject checks if subject is in subjects
+func containsSubject(subjects []rbacv1.Subject, subject rbacv1.Subject) bool {
+	for _, s := range subjects {
+		if s == subject {
+			return true
+		}
+	}
+	return false
+}
@@ -15,9 +15,11 @@
 package utility
 
 import (
+	"reflect"
 	"testing"
 
 	api "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	rbacv1 "k8s.io/api/rbac/v1"
 )
 
 func TestGetClusterRoleBindingsForSubjectPermissions(t *testing.T) {
@@ -60,3 +62,37 @@ func TestGetClusterRoleBindingsForSubjectPermissions(t *testing.T) {
 		}
 	}
 }
+
+func TestSubjectsForSubjectPermission(t *testing.T) {
+	var tests = []struct {
+		label    string
+		spec     api.SubjectPermissionSpec
+		expected []rbacv1.Subject
+	}{
+		{"single subject", api.SubjectPermissionSpec{SubjectKind: "Group", SubjectName: "sre-admins"},
+			[]rbacv1.Subject{{APIGroup: "rbac.authorization.k8s.io", Kind: "Group", Name: "sre-admins"}}},
+		{"single ServiceAccount", api.SubjectPermissionSpec{SubjectKind: "ServiceAccount", SubjectName: "robot", SubjectNamespace: "ci"},
+			[]rbacv1.Subject{{Kind: "ServiceAccount", Name: "robot", Namespace: "ci"}}},
+		{"single subject and subjects", api.SubjectPermissionSpec{
+			SubjectKind: "Group",
+			SubjectName: "sre-admins",
+			Subjects: []rbacv1.Subject{
+				{Kind: "User", Name: "admin"},
+				{APIGroup: "rbac.authorization.k8s.io", Kind: "Group", Name: "sre-admins"},
+				{Kind: "ServiceAccount", Name: "robot", Namespace: "ci"},
+			},
+		}, []rbacv1.Subject{
+			{APIGroup: "rbac.authorization.k8s.io", Kind: "Group", Name: "sre-admins"},
+			{APIGroup: "rbac.authorization.k8s.io", Kind: "User", Name: "admin"},
+			{Kind: "ServiceAccount", Name: "robot", Namespace: "ci"},
+		}},
+		{"no subject", api.SubjectPermissionSpec{}, nil},
+	}
+
+	for _, test := range tests {
+		subjects := SubjectsForSubjectPermission(&api.SubjectPermission{Spec: test.spec})
+		if !reflect.DeepEqual(subjects, test.expected) {
+			t.Errorf("%s: got %v, want %v", test.label, subjects, test.expected)
+		}
+	}
+}
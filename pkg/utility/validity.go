@@ -0,0 +1,118 @@
+// Copyright 2019 RedHat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utility
+
+import (
+	"time"
+
+	api "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+)
+
+// ValidityWindow returns the start and end of a Validity, nil when the window is unbounded on that side.
+// Duration counts from NotBefore, or from created when NotBefore is unset
+func ValidityWindow(validity api.Validity, created time.Time) (start, end *time.Time) {
+	if validity.NotBefore != nil {
+		notBefore := validity.NotBefore.Time
+		start = &notBefore
+	}
+
+	switch {
+	case validity.NotAfter != nil:
+		notAfter := validity.NotAfter.Time
+		end = &notAfter
+	case validity.Duration != nil:
+		from := created
+		if start != nil {
+			from = *start
+		}
+		expiry := from.Add(validity.Duration.Duration)
+		end = &expiry
+	}
+
+	return start, end
+}
+
+// IsValidityActive checks if now is inside the window of a Validity, the start is inclusive and the end exclusive
+func IsValidityActive(validity api.Validity, created, now time.Time) bool {
+	start, end := ValidityWindow(validity, created)
+	if start != nil && now.Before(*start) {
+		return false
+	}
+	if end != nil && !now.Before(*end) {
+		return false
+	}
+	return true
+}
+
+// IsPermissionActive checks if the Permission at permissionIndex is granted at now,
+// both the SubjectPermission and the Permission must be inside their window
+func IsPermissionActive(subjectPermission *api.SubjectPermission, permissionIndex int, now time.Time) bool {
+	created := subjectPermission.CreationTimestamp.Time
+	return IsValidityActive(subjectPermission.Spec.Validity, created, now) &&
+		IsValidityActive(subjectPermission.Spec.Permissions[permissionIndex].Validity, created, now)
+}
+
+// ValidityBoundaries returns the starts and ends of the windows of a SubjectPermission and its Permissions
+func ValidityBoundaries(subjectPermission *api.SubjectPermission) []time.Time {
+	created := subjectPermission.CreationTimestamp.Time
+	validities := []api.Validity{subjectPermission.Spec.Validity}
+	for _, permission := range subjectPermission.Spec.Permissions {
+		validities = append(validities, permission.Validity)
+	}
+
+	var boundaries []time.Time
+	for _, validity := range validities {
+		start, end := ValidityWindow(validity, created)
+		if start != nil {
+			boundaries = append(boundaries, *start)
+		}
+		if end != nil {
+			boundaries = append(boundaries, *end)
+		}
+	}
+	return boundaries
+}
+
+// ValidityExpiry returns the time the SubjectPermission expired at when its window is over, otherwise the
+// earliest end after now of the windows of the SubjectPermission and its Permissions. It is nil when nothing expires
+func ValidityExpiry(subjectPermission *api.SubjectPermission, now time.Time) *time.Time {
+	created := subjectPermission.CreationTimestamp.Time
+	_, end := ValidityWindow(subjectPermission.Spec.Validity, created)
+	if end != nil && !now.Before(*end) {
+		return end
+	}
+
+	var ends []time.Time
+	if end != nil {
+		ends = append(ends, *end)
+	}
+	for _, permission := range subjectPermission.Spec.Permissions {
+		if _, permissionEnd := ValidityWindow(permission.Validity, created); permissionEnd != nil {
+			ends = append(ends, *permissionEnd)
+		}
+	}
+	return NextTime(ends, now)
+}
+
+// NextTime returns the earliest of times that is after now, or nil
+func NextTime(times []time.Time, now time.Time) *time.Time {
+	var next *time.Time
+	for i := range times {
+		if times[i].After(now) && (next == nil || times[i].Before(*next)) {
+			next = &times[i]
+		}
+	}
+	return next
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 RedHat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utility
+
+import (
+	"testing"
+	"time"
+
+	api "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsValidityActive(t *testing.T) {
+	created := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hours int) time.Time { return created.Add(time.Duration(hours) * time.Hour) }
+	notBefore := &metav1.Time{Time: at(2)}
+	notAfter := &metav1.Time{Time: at(4)}
+	duration := &metav1.Duration{Duration: time.Hour}
+
+	var tests = []struct {
+		label    string
+		validity api.Validity
+		now      time.Time
+		expected bool
+	}{
+		{"unbounded", api.Validity{}, at(100), true},
+		{"before notBefore", api.Validity{NotBefore: notBefore}, at(1), false},
+		{"at notBefore", api.Validity{NotBefore: notBefore}, at(2), true},
+		{"before notAfter", api.Validity{NotAfter: notAfter}, at(3), true},
+		{"at notAfter", api.Validity{NotAfter: notAfter}, at(4), false},
+		{"duration from creation", api.Validity{Duration: duration}, at(0).Add(59 * time.Minute), true},
+		{"duration from creation expired", api.Validity{Duration: duration}, at(1), false},
+		{"duration from notBefore", api.Validity{NotBefore: notBefore, Duration: duration}, at(2).Add(59 * time.Minute), true},
+		{"duration from notBefore expired", api.Validity{NotBefore: notBefore, Duration: duration}, at(3), false},
+	}
+
+	for _, test := range tests {
+		if got := IsValidityActive(test.validity, created, test.now); got != test.expected {
+			t.Errorf("%s: got %t, want %t", test.label, got, test.expected)
+		}
+	}
+}
+
+func TestValidityExpiry(t *testing.T) {
+	created := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hours int) time.Time { return created.Add(time.Duration(hours) * time.Hour) }
+	subjectPermission := &api.SubjectPermission{
+		ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.Time{Time: created}},
+		Spec: api.SubjectPermissionSpec{
+			Validity: api.Validity{NotAfter: &metav1.Time{Time: at(4)}},
+			Permissions: []api.Permission{
+				{Validity: api.Validity{Duration: &metav1.Duration{Duration: 2 * time.Hour}}},
+			},
+		},
+	}
+
+	var tests = []struct {
+		now      time.Time
+		expected *time.Time
+	}{
+		{at(1), timePtr(at(2))},
+		{at(3), timePtr(at(4))},
+		{at(5), timePtr(at(4))},
+	}
+
+	for _, test := range tests {
+		got := ValidityExpiry(subjectPermission, test.now)
+		if got == nil || !got.Equal(*test.expected) {
+			t.Errorf("at %s: got %v, want %v", test.now, got, test.expected)
+		}
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
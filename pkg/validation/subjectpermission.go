@@ -0,0 +1,242 @@
+// Copyright 2019 RedHat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package validation checks SubjectPermissions before the operator acts on them.
+// It is shared by the admission webhook, the controllers and the command line tool.
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	managedv1alpha1 "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	"github.com/openshift/rbac-permissions-operator/pkg/policy"
+	"github.com/openshift/rbac-permissions-operator/pkg/utility"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// SupportedSubjectKinds are the Subject kinds a SubjectPermission can grant permissions to
+var SupportedSubjectKinds = []string{rbacv1.UserKind, rbacv1.GroupKind, rbacv1.ServiceAccountKind}
+
+// ValidateSubjectPermission returns every problem found in the spec of a SubjectPermission
+func ValidateSubjectPermission(subjectPermission *managedv1alpha1.SubjectPermission) field.ErrorList {
+	specPath := field.NewPath("spec")
+	spec := subjectPermission.Spec
+
+	allErrs := field.ErrorList{}
+
+	// the single Subject kept for compatibility, and the Subjects list
+	if spec.SubjectKind != "" || spec.SubjectName != "" {
+		allErrs = append(allErrs, validateSubject(spec.SubjectKind, spec.SubjectName, spec.SubjectNamespace, specPath.Child("subjectKind"), specPath.Child("subjectName"), specPath.Child("subjectNamespace"))...)
+	} else if len(spec.Subjects) == 0 {
+		allErrs = append(allErrs, field.Required(specPath.Child("subjects"), "at least one subject is required"))
+	}
+
+	subjectsPath := specPath.Child("subjects")
+	var subjects []string
+	for i, subject := range spec.Subjects {
+		subjectPath := subjectsPath.Index(i)
+		allErrs = append(allErrs, validateSubject(subject.Kind, subject.Name, subject.Namespace, subjectPath.Child("kind"), subjectPath.Child("name"), subjectPath.Child("namespace"))...)
+		allErrs = append(allErrs, validateSubjectAPIGroup(subject, subjectPath.Child("apiGroup"))...)
+
+		key := subject.Kind + "/" + subject.Namespace + "/" + subject.Name
+		for _, seen := range subjects {
+			if seen == key {
+				allErrs = append(allErrs, field.Duplicate(subjectPath, subject))
+				break
+			}
+		}
+		subjects = append(subjects, key)
+	}
+
+	clusterPermissionsPath := specPath.Child("clusterPermissions")
+	var clusterPermissions []string
+	for i, clusterRoleName := range spec.ClusterPermissions {
+		allErrs = append(allErrs, validateClusterRoleName(clusterRoleName, clusterPermissions, clusterPermissionsPath.Index(i))...)
+		clusterPermissions = append(clusterPermissions, clusterRoleName)
+	}
+
+	permissionsPath := specPath.Child("permissions")
+	var permissions []string
+	for i, permission := range spec.Permissions {
+		permissionPath := permissionsPath.Index(i)
+		if len(permission.Rules) > 0 {
+			// the ClusterRole of Rules is generated, it cannot also name one
+			if permission.ClusterRoleName != "" {
+				allErrs = append(allErrs, field.Forbidden(permissionPath.Child("clusterRoleName"), "must be empty when rules are set"))
+			}
+			allErrs = append(allErrs, validateRules(permission.Rules, permissionPath.Child("rules"))...)
+		} else {
+			allErrs = append(allErrs, validateClusterRoleName(permission.ClusterRoleName, permissions, permissionPath.Child("clusterRoleName"))...)
+			permissions = append(permissions, permission.ClusterRoleName)
+		}
+		allErrs = append(allErrs, validateRegex(permission.NamespacesAllowedRegex, permissionPath.Child("namespacesAllowedRegex"))...)
+		allErrs = append(allErrs, validateRegex(permission.NamespacesDeniedRegex, permissionPath.Child("namespacesDeniedRegex"))...)
+		allErrs = append(allErrs, metav1validation.ValidateLabelSelector(permission.NamespaceSelector, permissionPath.Child("namespaceSelector"))...)
+		allErrs = append(allErrs, validateValidity(permission.Validity, permissionPath)...)
+	}
+
+	allErrs = append(allErrs, validateValidity(spec.Validity, specPath)...)
+
+	return allErrs
+}
+
+// ValidateAdmission returns every error the admission webhook rejects a SubjectPermission for: an invalid spec,
+// grants outside of its own namespace and the entries bindingPolicy does not allow, see policy.Policy.Validate.
+// A nil bindingPolicy restricts nothing
+func ValidateAdmission(subjectPermission *managedv1alpha1.SubjectPermission, bindingPolicy *policy.Policy, clusterRoles []rbacv1.ClusterRole) field.ErrorList {
+	allErrs := ValidateSubjectPermission(subjectPermission)
+	allErrs = append(allErrs, ValidateSubjectPermissionScope(subjectPermission)...)
+	allErrs = append(allErrs, bindingPolicy.Validate(subjectPermission, clusterRoles)...)
+	return allErrs
+}
+
+// ValidateAdmissionUpdate is ValidateAdmission for an update of old to subjectPermission, only the entries the
+// update adds or changes are checked against bindingPolicy, see policy.Policy.ValidateUpdate
+func ValidateAdmissionUpdate(subjectPermission, old *managedv1alpha1.SubjectPermission, bindingPolicy *policy.Policy, clusterRoles []rbacv1.ClusterRole) field.ErrorList {
+	allErrs := ValidateSubjectPermission(subjectPermission)
+	allErrs = append(allErrs, ValidateSubjectPermissionScope(subjectPermission)...)
+	allErrs = append(allErrs, bindingPolicy.ValidateUpdate(subjectPermission, old, clusterRoles)...)
+	return allErrs
+}
+
+// ValidateSubjectPermissionScope returns the grants of a SubjectPermission that reach outside of its own namespace.
+// They are rejected at admission, the operator ignores them for SubjectPermissions that already exist,
+// see utility.IsNamespaceRestricted
+func ValidateSubjectPermissionScope(subjectPermission *managedv1alpha1.SubjectPermission) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if !utility.IsNamespaceRestricted(subjectPermission) {
+		return allErrs
+	}
+	if len(subjectPermission.Spec.ClusterPermissions) > 0 {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "clusterPermissions"), fmt.Sprintf("a SubjectPermission in namespace %s can only bind inside its own namespace, use a ClusterSubjectPermission instead", subjectPermission.Namespace)))
+	}
+
+	return allErrs
+}
+
+// validateSubject checks the kind, name and namespace of a Subject
+func validateSubject(kind, name, namespace string, kindPath, namePath, namespacePath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	switch kind {
+	case "":
+		allErrs = append(allErrs, field.Required(kindPath, "must be one of User, Group or ServiceAccount"))
+	case rbacv1.ServiceAccountKind:
+		if namespace == "" {
+			allErrs = append(allErrs, field.Required(namespacePath, "required for ServiceAccount subjects"))
+		}
+	case rbacv1.UserKind, rbacv1.GroupKind:
+	default:
+		allErrs = append(allErrs, field.NotSupported(kindPath, kind, SupportedSubjectKinds))
+	}
+
+	if name == "" {
+		allErrs = append(allErrs, field.Required(namePath, ""))
+	}
+
+	return allErrs
+}
+
+// validateSubjectAPIGroup checks that the APIGroup of a Subject, when set, is the one of its kind
+func validateSubjectAPIGroup(subject rbacv1.Subject, path *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if subject.APIGroup == "" {
+		return allErrs
+	}
+	switch subject.Kind {
+	case rbacv1.UserKind, rbacv1.GroupKind:
+		if subject.APIGroup != rbacv1.GroupName {
+			allErrs = append(allErrs, field.NotSupported(path, subject.APIGroup, []string{rbacv1.GroupName}))
+		}
+	case rbacv1.ServiceAccountKind:
+		allErrs = append(allErrs, field.Forbidden(path, "must be empty for ServiceAccount subjects"))
+	}
+
+	return allErrs
+}
+
+// validateClusterRoleName checks that a ClusterRole name is set and not already in seen
+func validateClusterRoleName(clusterRoleName string, seen []string, path *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if clusterRoleName == "" {
+		return append(allErrs, field.Required(path, ""))
+	}
+	for _, name := range seen {
+		if name == clusterRoleName {
+			return append(allErrs, field.Duplicate(path, clusterRoleName))
+		}
+	}
+
+	return allErrs
+}
+
+// validateRules checks that every PolicyRule has verbs and applies to either resources or non-resource URLs
+func validateRules(rules []rbacv1.PolicyRule, path *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	for i, rule := range rules {
+		rulePath := path.Index(i)
+		if len(rule.Verbs) == 0 {
+			allErrs = append(allErrs, field.Required(rulePath.Child("verbs"), ""))
+		}
+		switch {
+		case len(rule.NonResourceURLs) > 0 && (len(rule.Resources) > 0 || len(rule.APIGroups) > 0 || len(rule.ResourceNames) > 0):
+			allErrs = append(allErrs, field.Invalid(rulePath.Child("nonResourceURLs"), rule.NonResourceURLs, "cannot be combined with apiGroups, resources or resourceNames"))
+		case len(rule.NonResourceURLs) == 0 && len(rule.Resources) == 0:
+			allErrs = append(allErrs, field.Required(rulePath.Child("resources"), "resources or nonResourceURLs are required"))
+		case len(rule.Resources) > 0 && len(rule.APIGroups) == 0:
+			allErrs = append(allErrs, field.Required(rulePath.Child("apiGroups"), "use \"\" for the core API group"))
+		}
+	}
+
+	return allErrs
+}
+
+// validateValidity checks that the window of a Validity, under path, is not empty
+func validateValidity(validity managedv1alpha1.Validity, path *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if validity.NotAfter != nil && validity.Duration != nil {
+		allErrs = append(allErrs, field.Forbidden(path.Child("duration"), "cannot be set together with notAfter"))
+	}
+	if validity.Duration != nil && validity.Duration.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("duration"), validity.Duration.Duration.String(), "must be positive"))
+	}
+	if validity.NotBefore != nil && validity.NotAfter != nil && !validity.NotAfter.After(validity.NotBefore.Time) {
+		allErrs = append(allErrs, field.Invalid(path.Child("notAfter"), validity.NotAfter.UTC().Format(time.RFC3339), "must be after notBefore"))
+	}
+
+	return allErrs
+}
+
+// validateRegex checks that a namespace regex compiles, an empty regex is unset
+func validateRegex(regex string, path *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if regex == "" {
+		return allErrs
+	}
+	if _, err := regexp.Compile(regex); err != nil {
+		allErrs = append(allErrs, field.Invalid(path, regex, err.Error()))
+	}
+
+	return allErrs
+}
@@ -0,0 +1,136 @@
+package validation
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	managedv1alpha1 "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func mockSubjectPermission() *managedv1alpha1.SubjectPermission {
+	return &managedv1alpha1.SubjectPermission{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "testSubjectPermission",
+			Namespace: "openshift-rbac-permissions-operator",
+		},
+		Spec: managedv1alpha1.SubjectPermissionSpec{
+			SubjectName:        "exampleSubjectName",
+			SubjectKind:        "Group",
+			ClusterPermissions: []string{"exampleClusterRoleName", "exampleClusterRoleNameTwo"},
+			Permissions: []managedv1alpha1.Permission{
+				{
+					ClusterRoleName:        "exampleClusterRoleName",
+					NamespacesAllowedRegex: "^example-.*",
+					NamespacesDeniedRegex:  "^example-denied$",
+				},
+			},
+		},
+	}
+}
+
+// TestValidateSubjectPermission tests the errors reported for a SubjectPermission
+// given: a valid SubjectPermission changed by each test case
+// expected: the field paths of the errors found, none for the valid SubjectPermission
+func TestValidateSubjectPermission(t *testing.T) {
+	var tests = []struct {
+		label    string
+		mutate   func(*managedv1alpha1.SubjectPermission)
+		expected []string
+	}{
+		{"valid", func(sp *managedv1alpha1.SubjectPermission) {}, nil},
+		{"unknown subject kind", func(sp *managedv1alpha1.SubjectPermission) { sp.Spec.SubjectKind = "Robot" }, []string{"spec.subjectKind"}},
+		{"empty subject kind", func(sp *managedv1alpha1.SubjectPermission) { sp.Spec.SubjectKind = "" }, []string{"spec.subjectKind"}},
+		{"empty subject name", func(sp *managedv1alpha1.SubjectPermission) { sp.Spec.SubjectName = "" }, []string{"spec.subjectName"}},
+		{"ServiceAccount without namespace", func(sp *managedv1alpha1.SubjectPermission) { sp.Spec.SubjectKind = "ServiceAccount" }, []string{"spec.subjectNamespace"}},
+		{"ServiceAccount with namespace", func(sp *managedv1alpha1.SubjectPermission) {
+			sp.Spec.SubjectKind = "ServiceAccount"
+			sp.Spec.SubjectNamespace = "example"
+		}, nil},
+		{"no subject", func(sp *managedv1alpha1.SubjectPermission) {
+			sp.Spec.SubjectKind = ""
+			sp.Spec.SubjectName = ""
+		}, []string{"spec.subjects"}},
+		{"subjects only", func(sp *managedv1alpha1.SubjectPermission) {
+			sp.Spec.SubjectKind = ""
+			sp.Spec.SubjectName = ""
+			sp.Spec.Subjects = []rbacv1.Subject{
+				{Kind: "Group", Name: "dev"},
+				{APIGroup: "rbac.authorization.k8s.io", Kind: "User", Name: "admin"},
+				{Kind: "ServiceAccount", Namespace: "example", Name: "robot"},
+			}
+		}, nil},
+		{"invalid subjects", func(sp *managedv1alpha1.SubjectPermission) {
+			sp.Spec.Subjects = []rbacv1.Subject{
+				{Kind: "Robot", Name: "dev"},
+				{Kind: "ServiceAccount", Name: "robot"},
+				{APIGroup: "example.com", Kind: "Group", Name: "dev"},
+				{Kind: "Group", Name: "dev"},
+				{Kind: "User"},
+			}
+		}, []string{"spec.subjects[0].kind", "spec.subjects[1].namespace", "spec.subjects[2].apiGroup", "spec.subjects[3]", "spec.subjects[4].name"}},
+		{"duplicate cluster permission", func(sp *managedv1alpha1.SubjectPermission) {
+			sp.Spec.ClusterPermissions = append(sp.Spec.ClusterPermissions, "exampleClusterRoleName")
+		}, []string{"spec.clusterPermissions[2]"}},
+		{"empty cluster permission", func(sp *managedv1alpha1.SubjectPermission) { sp.Spec.ClusterPermissions[1] = "" }, []string{"spec.clusterPermissions[1]"}},
+		{"duplicate permission", func(sp *managedv1alpha1.SubjectPermission) {
+			sp.Spec.Permissions = append(sp.Spec.Permissions, managedv1alpha1.Permission{ClusterRoleName: "exampleClusterRoleName", NamespacesAllowedRegex: ".*"})
+		}, []string{"spec.permissions[1].clusterRoleName"}},
+		{"empty allowed regex", func(sp *managedv1alpha1.SubjectPermission) {
+			sp.Spec.Permissions[0].NamespacesAllowedRegex = ""
+		}, nil},
+		{"bad regex", func(sp *managedv1alpha1.SubjectPermission) {
+			sp.Spec.Permissions[0].NamespacesAllowedRegex = "("
+			sp.Spec.Permissions[0].NamespacesDeniedRegex = "[a-"
+		}, []string{"spec.permissions[0].namespacesAllowedRegex", "spec.permissions[0].namespacesDeniedRegex"}},
+		{"rules", func(sp *managedv1alpha1.SubjectPermission) {
+			sp.Spec.Permissions[0].ClusterRoleName = ""
+			sp.Spec.Permissions[0].Rules = []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}},
+				{NonResourceURLs: []string{"/healthz"}, Verbs: []string{"get"}},
+			}
+		}, nil},
+		{"invalid rules", func(sp *managedv1alpha1.SubjectPermission) {
+			sp.Spec.Permissions[0].Rules = []rbacv1.PolicyRule{
+				{Resources: []string{"configmaps"}},
+				{Verbs: []string{"get"}},
+				{APIGroups: []string{""}, NonResourceURLs: []string{"/healthz"}, Verbs: []string{"get"}},
+			}
+		}, []string{"spec.permissions[0].clusterRoleName", "spec.permissions[0].rules[0].verbs", "spec.permissions[0].rules[0].apiGroups", "spec.permissions[0].rules[1].resources", "spec.permissions[0].rules[2].nonResourceURLs"}},
+		{"validity", func(sp *managedv1alpha1.SubjectPermission) {
+			sp.Spec.NotBefore = &metav1.Time{Time: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)}
+			sp.Spec.Duration = &metav1.Duration{Duration: time.Hour}
+			sp.Spec.Permissions[0].NotAfter = &metav1.Time{Time: time.Date(2019, 1, 1, 0, 30, 0, 0, time.UTC)}
+		}, nil},
+		{"invalid validity", func(sp *managedv1alpha1.SubjectPermission) {
+			sp.Spec.NotAfter = &metav1.Time{Time: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)}
+			sp.Spec.Duration = &metav1.Duration{Duration: -time.Hour}
+			sp.Spec.Permissions[0].NotBefore = &metav1.Time{Time: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)}
+			sp.Spec.Permissions[0].NotAfter = &metav1.Time{Time: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)}
+		}, []string{"spec.permissions[0].notAfter", "spec.duration", "spec.duration"}},
+		{"namespace selector", func(sp *managedv1alpha1.SubjectPermission) {
+			sp.Spec.Permissions[0].NamespacesAllowedRegex = ""
+			sp.Spec.Permissions[0].NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"team": "example"}}
+		}, nil},
+		{"bad namespace selector", func(sp *managedv1alpha1.SubjectPermission) {
+			sp.Spec.Permissions[0].NamespaceSelector = &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "team", Operator: metav1.LabelSelectorOpIn}},
+			}
+		}, []string{"spec.permissions[0].namespaceSelector.matchExpressions[0].values"}},
+	}
+
+	for _, test := range tests {
+		subjectPermission := mockSubjectPermission()
+		test.mutate(subjectPermission)
+
+		var fields []string
+		for _, err := range ValidateSubjectPermission(subjectPermission) {
+			fields = append(fields, err.Field)
+		}
+		if !reflect.DeepEqual(fields, test.expected) {
+			t.Errorf("%s: got errors for %v, want %v", test.label, fields, test.expected)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+
+	managedv1alpha1 "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	"github.com/openshift/rbac-permissions-operator/pkg/policy"
+	"github.com/openshift/rbac-permissions-operator/pkg/utility"
+	"github.com/openshift/rbac-permissions-operator/pkg/validation"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission/types"
+)
+
+var log = logf.Log.WithName("webhook_subjectpermission")
+
+const (
+	// subjectPermissionKind is the kind of SubjectPermissions in admission requests
+	subjectPermissionKind = "SubjectPermission"
+	// clusterSubjectPermissionKind is the kind of ClusterSubjectPermissions in admission requests
+	clusterSubjectPermissionKind = "ClusterSubjectPermission"
+)
+
+// subjectPermissionValidator rejects SubjectPermissions and ClusterSubjectPermissions the operator cannot act on
+type subjectPermissionValidator struct {
+	decoder types.Decoder
+	// client reads the policy and the ClusterRoles it checks, the policy is not checked without it
+	client client.Client
+}
+
+// blank assignments to verify that subjectPermissionValidator implements admission.Handler and gets a Decoder
+// and a Client injected
+var _ admission.Handler = &subjectPermissionValidator{}
+var _ inject.Decoder = &subjectPermissionValidator{}
+var _ inject.Client = &subjectPermissionValidator{}
+
+// Handle validates the SubjectPermission or ClusterSubjectPermission of an admission request. A SubjectPermission
+// being deleted, or updated without changing its spec, is always allowed so its finalizer can be removed and
+// its metadata and status edited even when it no longer passes validation
+func (v *subjectPermissionValidator) Handle(ctx context.Context, req types.Request) types.Response {
+	kind := subjectPermissionKind
+	if req.AdmissionRequest.Kind.Kind == clusterSubjectPermissionKind {
+		kind = clusterSubjectPermissionKind
+	}
+	subjectPermission, err := v.decode(req, kind)
+	if err != nil {
+		return admission.ErrorResponse(http.StatusBadRequest, err)
+	}
+	if subjectPermission.DeletionTimestamp != nil {
+		return admission.ValidationResponse(true, "")
+	}
+
+	var old *managedv1alpha1.SubjectPermission
+	if len(req.AdmissionRequest.OldObject.Raw) > 0 {
+		old, err = v.decode(types.Request{AdmissionRequest: &admissionv1beta1.AdmissionRequest{Object: req.AdmissionRequest.OldObject}}, kind)
+		if err != nil {
+			return admission.ErrorResponse(http.StatusBadRequest, err)
+		}
+		if reflect.DeepEqual(old.Spec, subjectPermission.Spec) {
+			return admission.ValidationResponse(true, "")
+		}
+	}
+
+	return v.validationResponse(ctx, subjectPermission, old, kind)
+}
+
+// decode returns the object of an admission request of kind, a ClusterSubjectPermission is returned
+// as a SubjectPermission without namespace
+func (v *subjectPermissionValidator) decode(req types.Request, kind string) (*managedv1alpha1.SubjectPermission, error) {
+	if kind == clusterSubjectPermissionKind {
+		clusterSubjectPermission := &managedv1alpha1.ClusterSubjectPermission{}
+		err := v.decoder.Decode(req, clusterSubjectPermission)
+		if err != nil {
+			return nil, err
+		}
+		return utility.SubjectPermissionForCluster(clusterSubjectPermission), nil
+	}
+
+	subjectPermission := &managedv1alpha1.SubjectPermission{}
+	err := v.decoder.Decode(req, subjectPermission)
+	if err != nil {
+		return nil, err
+	}
+	return subjectPermission, nil
+}
+
+// InjectDecoder implements inject.Decoder
+func (v *subjectPermissionValidator) InjectDecoder(d types.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// InjectClient implements inject.Client
+func (v *subjectPermissionValidator) InjectClient(c client.Client) error {
+	v.client = c
+	return nil
+}
+
+// validationResponse admits a valid SubjectPermission, or rejects it with an Invalid status
+// listing the field path of every error. old is the SubjectPermission an update replaces, nil on create,
+// and kind is the kind of the admitted object
+func (v *subjectPermissionValidator) validationResponse(ctx context.Context, subjectPermission, old *managedv1alpha1.SubjectPermission, kind string) types.Response {
+	bindingPolicy, clusterRoles, err := v.getPolicy(ctx)
+	if err != nil {
+		log.Error(err, "Failed to check the policy", "Namespace", subjectPermission.Namespace, "Name", subjectPermission.Name)
+		return admission.ErrorResponse(http.StatusInternalServerError, err)
+	}
+	allErrs := validation.ValidateAdmission(subjectPermission, bindingPolicy, clusterRoles)
+	if old != nil {
+		allErrs = validation.ValidateAdmissionUpdate(subjectPermission, old, bindingPolicy, clusterRoles)
+	}
+	if len(allErrs) == 0 {
+		return admission.ValidationResponse(true, "")
+	}
+
+	log.Info("Rejecting "+kind, "Namespace", subjectPermission.Namespace, "Name", subjectPermission.Name, "Errors", allErrs.ToAggregate().Error())
+	status := errors.NewInvalid(managedv1alpha1.SchemeGroupVersion.WithKind(kind).GroupKind(), subjectPermission.Name, allErrs).ErrStatus
+	response := admission.ValidationResponse(false, string(status.Reason))
+	response.Response.Result = &status
+	return response
+}
+
+// getPolicy returns the policy of the operator ConfigMap and the ClusterRoles it checks, see policy.Policy.
+// Nothing is restricted without a client or a policy
+func (v *subjectPermissionValidator) getPolicy(ctx context.Context) (*policy.Policy, []rbacv1.ClusterRole, error) {
+	if v.client == nil {
+		return nil, nil, nil
+	}
+	bindingPolicy, err := policy.Get(ctx, v.client)
+	if err != nil || bindingPolicy == nil {
+		return nil, nil, err
+	}
+
+	clusterRoleList := &rbacv1.ClusterRoleList{}
+	err = v.client.List(ctx, &client.ListOptions{}, clusterRoleList)
+	if err != nil {
+		return nil, nil, err
+	}
+	return bindingPolicy, clusterRoleList.Items, nil
+}
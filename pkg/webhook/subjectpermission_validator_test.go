@@ -0,0 +1,280 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	operatorconfig "github.com/openshift/rbac-permissions-operator/config"
+	"github.com/openshift/rbac-permissions-operator/pkg/apis"
+	managedv1alpha1 "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	"github.com/openshift/rbac-permissions-operator/pkg/policy"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission/types"
+)
+
+// TestSubjectPermissionValidator tests the admission of SubjectPermissions
+// given: admission requests for a valid and an invalid SubjectPermission
+// expected: the valid one is allowed, the invalid one is rejected with the field path of each error
+func TestSubjectPermissionValidator(t *testing.T) {
+	if err := apis.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("Unable to add apis scheme: (%v)", err)
+	}
+	decoder, err := admission.NewDecoder(scheme.Scheme)
+	if err != nil {
+		t.Fatalf("Unable to create decoder: (%v)", err)
+	}
+	validator := &subjectPermissionValidator{}
+	if err := validator.InjectDecoder(decoder); err != nil {
+		t.Fatalf("Unable to inject decoder: (%v)", err)
+	}
+
+	var tests = []struct {
+		label   string
+		spec    managedv1alpha1.SubjectPermissionSpec
+		allowed bool
+		causes  []string
+	}{
+		{"valid", managedv1alpha1.SubjectPermissionSpec{
+			SubjectKind: "Group",
+			SubjectName: "exampleSubjectName",
+			Permissions: []managedv1alpha1.Permission{{ClusterRoleName: "exampleClusterRoleName", NamespacesAllowedRegex: ".*"}},
+		}, true, nil},
+		{"invalid", managedv1alpha1.SubjectPermissionSpec{
+			SubjectKind: "ServiceAccount",
+			SubjectName: "exampleSubjectName",
+			Permissions: []managedv1alpha1.Permission{{ClusterRoleName: "exampleClusterRoleName", NamespacesAllowedRegex: "("}},
+		}, false, []string{"spec.subjectNamespace", "spec.permissions[0].namespacesAllowedRegex"}},
+	}
+
+	for _, test := range tests {
+		subjectPermission := &managedv1alpha1.SubjectPermission{
+			TypeMeta:   metav1.TypeMeta{APIVersion: managedv1alpha1.SchemeGroupVersion.String(), Kind: "SubjectPermission"},
+			ObjectMeta: metav1.ObjectMeta{Name: "testSubjectPermission", Namespace: "openshift-rbac-permissions-operator"},
+			Spec:       test.spec,
+		}
+		raw, err := json.Marshal(subjectPermission)
+		if err != nil {
+			t.Fatalf("Unable to marshal SubjectPermission: (%v)", err)
+		}
+
+		response := validator.Handle(context.TODO(), types.Request{
+			AdmissionRequest: &admissionv1beta1.AdmissionRequest{Object: runtime.RawExtension{Raw: raw}},
+		})
+		if response.Response.Allowed != test.allowed {
+			t.Errorf("%s: got allowed %t, want %t", test.label, response.Response.Allowed, test.allowed)
+		}
+		if test.allowed {
+			continue
+		}
+
+		var causes []string
+		if response.Response.Result != nil && response.Response.Result.Details != nil {
+			for _, cause := range response.Response.Result.Details.Causes {
+				causes = append(causes, cause.Field)
+			}
+		}
+		if len(causes) != len(test.causes) {
+			t.Fatalf("%s: got causes %v, want %v", test.label, causes, test.causes)
+		}
+		for i := range causes {
+			if causes[i] != test.causes[i] {
+				t.Errorf("%s: got causes %v, want %v", test.label, causes, test.causes)
+			}
+		}
+	}
+}
+
+// TestSubjectPermissionValidatorScope tests the admission of cluster-wide grants
+// given: the same ClusterPermissions in a SubjectPermission in a normal namespace, in the operator namespace and in a ClusterSubjectPermission
+// expected: only the SubjectPermission in the normal namespace is rejected
+func TestSubjectPermissionValidatorScope(t *testing.T) {
+	if err := apis.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("Unable to add apis scheme: (%v)", err)
+	}
+	decoder, err := admission.NewDecoder(scheme.Scheme)
+	if err != nil {
+		t.Fatalf("Unable to create decoder: (%v)", err)
+	}
+	validator := &subjectPermissionValidator{}
+	if err := validator.InjectDecoder(decoder); err != nil {
+		t.Fatalf("Unable to inject decoder: (%v)", err)
+	}
+
+	spec := managedv1alpha1.SubjectPermissionSpec{
+		SubjectKind:        "Group",
+		SubjectName:        "exampleSubjectName",
+		ClusterPermissions: []string{"exampleClusterRoleName"},
+	}
+	var tests = []struct {
+		kind      string
+		namespace string
+		allowed   bool
+	}{
+		{subjectPermissionKind, "team-a", false},
+		{subjectPermissionKind, "openshift-rbac-permissions-operator", true},
+		{clusterSubjectPermissionKind, "", true},
+	}
+
+	for _, test := range tests {
+		object := map[string]interface{}{
+			"apiVersion": managedv1alpha1.SchemeGroupVersion.String(),
+			"kind":       test.kind,
+			"metadata":   metav1.ObjectMeta{Name: "testSubjectPermission", Namespace: test.namespace},
+			"spec":       spec,
+		}
+		raw, err := json.Marshal(object)
+		if err != nil {
+			t.Fatalf("Unable to marshal %s: (%v)", test.kind, err)
+		}
+
+		response := validator.Handle(context.TODO(), types.Request{
+			AdmissionRequest: &admissionv1beta1.AdmissionRequest{
+				Kind:   metav1.GroupVersionKind{Group: managedv1alpha1.SchemeGroupVersion.Group, Version: managedv1alpha1.SchemeGroupVersion.Version, Kind: test.kind},
+				Object: runtime.RawExtension{Raw: raw},
+			},
+		})
+		if response.Response.Allowed != test.allowed {
+			t.Errorf("%s in namespace %q: got allowed %t, want %t (%v)", test.kind, test.namespace, response.Response.Allowed, test.allowed, response.Response.Result)
+		}
+	}
+}
+
+// TestSubjectPermissionValidatorPolicy tests the admission of restricted ClusterRoles
+// given: a policy restricting ClusterRoles with wildcard rules, allowlisting the group sre
+// expected: binding the wildcard ClusterRole is rejected for the group dev, naming the rule, and allowed for sre
+func TestSubjectPermissionValidatorPolicy(t *testing.T) {
+	if err := apis.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("Unable to add apis scheme: (%v)", err)
+	}
+	decoder, err := admission.NewDecoder(scheme.Scheme)
+	if err != nil {
+		t.Fatalf("Unable to create decoder: (%v)", err)
+	}
+	validator := &subjectPermissionValidator{}
+	if err := validator.InjectDecoder(decoder); err != nil {
+		t.Fatalf("Unable to inject decoder: (%v)", err)
+	}
+	err = validator.InjectClient(fake.NewFakeClient(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: operatorconfig.OperatorConfigMapName, Namespace: operatorconfig.OperatorNamespace},
+			Data:       map[string]string{policy.RestrictedRulesKey: policy.RuleWildcard, policy.AllowlistedSubjectsKey: "Group:sre"},
+		},
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "everything"},
+			Rules:      []rbacv1.PolicyRule{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}},
+		},
+	))
+	if err != nil {
+		t.Fatalf("Unable to inject client: (%v)", err)
+	}
+
+	var tests = []struct {
+		subjectName string
+		allowed     bool
+		message     string
+	}{
+		{"dev", false, "spec.clusterPermissions[0]: Forbidden: rule 0 of ClusterRole everything matches the restricted pattern Wildcard"},
+		{"sre", true, ""},
+	}
+
+	for _, test := range tests {
+		subjectPermission := &managedv1alpha1.SubjectPermission{
+			TypeMeta:   metav1.TypeMeta{APIVersion: managedv1alpha1.SchemeGroupVersion.String(), Kind: "SubjectPermission"},
+			ObjectMeta: metav1.ObjectMeta{Name: "testSubjectPermission", Namespace: "openshift-rbac-permissions-operator"},
+			Spec: managedv1alpha1.SubjectPermissionSpec{
+				SubjectKind:        "Group",
+				SubjectName:        test.subjectName,
+				ClusterPermissions: []string{"everything"},
+			},
+		}
+		raw, err := json.Marshal(subjectPermission)
+		if err != nil {
+			t.Fatalf("Unable to marshal SubjectPermission: (%v)", err)
+		}
+
+		response := validator.Handle(context.TODO(), types.Request{
+			AdmissionRequest: &admissionv1beta1.AdmissionRequest{Object: runtime.RawExtension{Raw: raw}},
+		})
+		if response.Response.Allowed != test.allowed {
+			t.Errorf("%s: got allowed %t, want %t", test.subjectName, response.Response.Allowed, test.allowed)
+		}
+		if test.allowed {
+			continue
+		}
+		if response.Response.Result == nil || !strings.Contains(response.Response.Result.Message, test.message) {
+			t.Errorf("%s: got result %v, want it to contain %q", test.subjectName, response.Response.Result, test.message)
+		}
+	}
+}
+
+// TestSubjectPermissionValidatorExemptions tests the admission of SubjectPermissions that no longer pass validation
+// given: an invalid SubjectPermission being deleted, updated without changing its spec, and updated from a valid spec
+// expected: the deletion and the update of the metadata are allowed, changing the spec to an invalid one is rejected
+func TestSubjectPermissionValidatorExemptions(t *testing.T) {
+	if err := apis.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("Unable to add apis scheme: (%v)", err)
+	}
+	decoder, err := admission.NewDecoder(scheme.Scheme)
+	if err != nil {
+		t.Fatalf("Unable to create decoder: (%v)", err)
+	}
+	validator := &subjectPermissionValidator{}
+	if err := validator.InjectDecoder(decoder); err != nil {
+		t.Fatalf("Unable to inject decoder: (%v)", err)
+	}
+
+	invalid := &managedv1alpha1.SubjectPermission{
+		TypeMeta:   metav1.TypeMeta{APIVersion: managedv1alpha1.SchemeGroupVersion.String(), Kind: "SubjectPermission"},
+		ObjectMeta: metav1.ObjectMeta{Name: "testSubjectPermission", Namespace: "openshift-rbac-permissions-operator"},
+		Spec: managedv1alpha1.SubjectPermissionSpec{
+			SubjectKind: "Group",
+			SubjectName: "exampleSubjectName",
+			Permissions: []managedv1alpha1.Permission{{ClusterRoleName: "exampleClusterRoleName", NamespacesAllowedRegex: "("}},
+		},
+	}
+	deleted := invalid.DeepCopy()
+	now := metav1.Now()
+	deleted.DeletionTimestamp = &now
+	relabeled := invalid.DeepCopy()
+	relabeled.Labels = map[string]string{"team": "a"}
+	valid := invalid.DeepCopy()
+	valid.Spec.Permissions[0].NamespacesAllowedRegex = ".*"
+
+	var tests = []struct {
+		label   string
+		object  *managedv1alpha1.SubjectPermission
+		old     *managedv1alpha1.SubjectPermission
+		allowed bool
+	}{
+		{"created", invalid, nil, false},
+		{"deleted", deleted, invalid, true},
+		{"metadata updated", relabeled, invalid, true},
+		{"spec updated", invalid, valid, false},
+	}
+
+	for _, test := range tests {
+		request := &admissionv1beta1.AdmissionRequest{}
+		if request.Object.Raw, err = json.Marshal(test.object); err != nil {
+			t.Fatalf("Unable to marshal SubjectPermission: (%v)", err)
+		}
+		if test.old != nil {
+			if request.OldObject.Raw, err = json.Marshal(test.old); err != nil {
+				t.Fatalf("Unable to marshal SubjectPermission: (%v)", err)
+			}
+		}
+
+		response := validator.Handle(context.TODO(), types.Request{AdmissionRequest: request})
+		if response.Response.Allowed != test.allowed {
+			t.Errorf("%s: got allowed %t, want %t (%v)", test.label, response.Response.Allowed, test.allowed, response.Response.Result)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package webhook
+
+import (
+	operatorconfig "github.com/openshift/rbac-permissions-operator/config"
+	managedv1alpha1 "github.com/openshift/rbac-permissions-operator/pkg/apis/managed/v1alpha1"
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission/builder"
+)
+
+const (
+	// serverName of the admission webhook server
+	serverName = "rbac-permissions-operator-admission-server"
+	// serverPort the admission webhook server listens on
+	serverPort int32 = 9876
+	// certDir holds the serving certificate of the admission webhook server
+	certDir = "/tmp/cert"
+	// serviceName of the Service exposing the admission webhook server
+	serviceName = "rbac-permissions-operator-webhook"
+	// secretName of the Secret holding the serving certificate
+	secretName = "rbac-permissions-operator-webhook-cert"
+	// validatingWebhookConfigName of the ValidatingWebhookConfiguration installed by the server
+	validatingWebhookConfigName = "rbac-permissions-operator"
+)
+
+// AddToManager adds the admission webhook server and its webhooks to the Manager.
+// The server installs its ValidatingWebhookConfiguration, Service and certificate when the Manager is Started.
+func AddToManager(mgr manager.Manager) error {
+	subjectPermissionWebhook, err := builder.NewWebhookBuilder().
+		Name("validate.subjectpermissions.managed.openshift.io").
+		Validating().
+		Operations(admissionregistrationv1beta1.Create, admissionregistrationv1beta1.Update).
+		WithManager(mgr).
+		ForType(&managedv1alpha1.SubjectPermission{}).
+		Handlers(&subjectPermissionValidator{}).
+		Build()
+	if err != nil {
+		return err
+	}
+
+	clusterSubjectPermissionWebhook, err := builder.NewWebhookBuilder().
+		Name("validate.clustersubjectpermissions.managed.openshift.io").
+		Validating().
+		Operations(admissionregistrationv1beta1.Create, admissionregistrationv1beta1.Update).
+		WithManager(mgr).
+		ForType(&managedv1alpha1.ClusterSubjectPermission{}).
+		Handlers(&subjectPermissionValidator{}).
+		Build()
+	if err != nil {
+		return err
+	}
+
+	server, err := webhook.NewServer(serverName, mgr, webhook.ServerOptions{
+		Port:    serverPort,
+		CertDir: certDir,
+		BootstrapOptions: &webhook.BootstrapOptions{
+			ValidatingWebhookConfigName: validatingWebhookConfigName,
+			Secret: &types.NamespacedName{
+				Namespace: operatorconfig.OperatorNamespace,
+				Name:      secretName,
+			},
+			Service: &webhook.Service{
+				Namespace: operatorconfig.OperatorNamespace,
+				Name:      serviceName,
+				Selectors: map[string]string{"name": operatorconfig.OperatorName},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return server.Register(subjectPermissionWebhook, clusterSubjectPermissionWebhook)
+}